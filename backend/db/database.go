@@ -3,6 +3,7 @@ package db
 import (
 	"log"
 	"os"
+	"strings"
 
 	"unilink-backend/models"
 
@@ -34,12 +35,28 @@ func ConnectDB() {
 	err = DB.AutoMigrate(
 		&models.College{},
 		&models.User{},
+		&models.ListingCategory{},
 		&models.MarketplaceListing{},
+		&models.Upload{},
+		&models.ListingImage{},
+		&models.ListingChange{},
+		&models.ListingOffer{},
+		&models.UserRating{},
 		&models.Announcement{},
 		&models.Friendship{},  // Module 3
 		&models.Group{},       // Module 3
 		&models.GroupMember{}, // Module 3
 		&models.Message{},     // Module 3
+		&models.MessageAttachment{},
+		&models.MessageEdit{},
+		&models.MessageReaction{},
+		&models.ConversationRead{},
+		&models.Conversation{},
+		&models.ConversationMember{},
+		&models.Session{},
+		&models.WebSocketTicket{},
+		&models.BackplaneEvent{},
+		&models.HubPresence{},
 	)
 
 	if err != nil {
@@ -48,11 +65,50 @@ func ConnectDB() {
 
 	log.Println("✅ Database migrations completed")
 
+	createIndexes()
+
 	// Seed initial colleges (only if table is empty)
 	seedColleges()
+	seedListingCategories()
+	backfillConversationReads()
 	/* SeedTMSLStudents(DB) */
 }
 
+// createIndexes adds indexes AutoMigrate can't express (expression, GIN and sorted composites)
+func createIndexes() {
+	indexes := []string{
+		// Full-text search over listing title + description
+		`CREATE INDEX IF NOT EXISTS idx_listings_search ON marketplace_listings
+			USING GIN (to_tsvector('english', coalesce(title, '') || ' ' || coalesce(description, '')))`,
+		// Keyset pagination for the marketplace feed (newest / price sorts)
+		`CREATE INDEX IF NOT EXISTS idx_listings_college_status_created ON marketplace_listings
+			(college_id, status, created_at DESC, id DESC) WHERE deleted_at IS NULL`,
+		`CREATE INDEX IF NOT EXISTS idx_listings_college_status_price ON marketplace_listings
+			(college_id, status, price, id) WHERE deleted_at IS NULL`,
+		`CREATE INDEX IF NOT EXISTS idx_listings_seller ON marketplace_listings (seller_id, created_at DESC)`,
+		`CREATE INDEX IF NOT EXISTS idx_listings_college_category ON marketplace_listings
+			(college_id, category_id, status) WHERE deleted_at IS NULL`,
+		// Message history cursors (?before= / ?after=) and sync
+		`CREATE INDEX IF NOT EXISTS idx_messages_conversation_cursor ON messages (conversation_id, id)`,
+		// Edits, deletes and reactions since the last sync
+		`CREATE INDEX IF NOT EXISTS idx_messages_conversation_updated ON messages (conversation_id, updated_at)`,
+		// Conversation list sorted by recent activity
+		`CREATE INDEX IF NOT EXISTS idx_conversation_members_activity ON conversation_members
+			(user_id, last_activity_at DESC NULLS LAST, conversation_id)`,
+		// Full-text search over message content
+		`CREATE INDEX IF NOT EXISTS idx_messages_search ON messages
+			USING GIN (to_tsvector('english', content)) WHERE is_deleted = false`,
+	}
+
+	for _, stmt := range indexes {
+		if err := DB.Exec(stmt).Error; err != nil {
+			log.Fatal("Failed to create index:", err)
+		}
+	}
+
+	log.Println("✅ Database indexes ensured")
+}
+
 // seedColleges adds initial college data to the database
 func seedColleges() {
 	var count int64
@@ -94,6 +150,81 @@ func seedColleges() {
 	log.Printf("✅ Seeded %d colleges\n", len(colleges))
 }
 
+// backfillConversationReads carries the old messages.is_read flag over to per-user read
+// state for DM recipients, so existing conversations don't all show up as unread.
+// Group read state can't be recovered from a single flag and starts fresh.
+// The column is dropped in the same transaction, so this runs once.
+func backfillConversationReads() {
+	if !DB.Migrator().HasColumn("messages", "is_read") {
+		return
+	}
+
+	tx := DB.Begin()
+	if tx.Error != nil {
+		log.Printf("Warning: Failed to backfill conversation read state: %v", tx.Error)
+		return
+	}
+
+	result := tx.Exec(`INSERT INTO conversation_reads (conversation_id, user_id, last_read_message_id, updated_at)
+		SELECT conversation_id, receiver_id, MAX(id), NOW() FROM messages
+		WHERE is_read AND receiver_id IS NOT NULL
+		GROUP BY conversation_id, receiver_id
+		ON CONFLICT (conversation_id, user_id) DO NOTHING`)
+	if result.Error != nil {
+		tx.Rollback()
+		log.Printf("Warning: Failed to backfill conversation read state: %v", result.Error)
+		return
+	}
+	if err := tx.Migrator().DropColumn("messages", "is_read"); err != nil {
+		tx.Rollback()
+		log.Printf("Warning: Failed to drop messages.is_read after backfill: %v", err)
+		return
+	}
+	if err := tx.Commit().Error; err != nil {
+		log.Printf("Warning: Failed to backfill conversation read state: %v", err)
+		return
+	}
+
+	log.Printf("✅ Backfilled read state for %d DM conversations and dropped messages.is_read", result.RowsAffected)
+}
+
+// DefaultListingCategories is the starter taxonomy given to every college
+var DefaultListingCategories = []string{
+	"Books", "Electronics", "Furniture", "Clothing", "Stationery", "Sports", "Other",
+}
+
+// SeedCategoriesForCollege creates the default categories for a college that has none
+func SeedCategoriesForCollege(collegeID uint) error {
+	var count int64
+	DB.Model(&models.ListingCategory{}).Where("college_id = ?", collegeID).Count(&count)
+	if count > 0 {
+		return nil
+	}
+
+	categories := make([]models.ListingCategory, 0, len(DefaultListingCategories))
+	for i, name := range DefaultListingCategories {
+		categories = append(categories, models.ListingCategory{
+			CollegeID: collegeID,
+			Name:      name,
+			Slug:      strings.ToLower(name),
+			SortOrder: i,
+		})
+	}
+	return DB.Create(&categories).Error
+}
+
+// seedListingCategories gives every existing college the default taxonomy (skips colleges that have one)
+func seedListingCategories() {
+	var collegeIDs []uint
+	DB.Model(&models.College{}).Pluck("id", &collegeIDs)
+
+	for _, id := range collegeIDs {
+		if err := SeedCategoriesForCollege(id); err != nil {
+			log.Printf("Warning: Failed to seed listing categories for college %d: %v", id, err)
+		}
+	}
+}
+
 // GetDB returns the database instance
 func GetDB() *gorm.DB {
 	return DB
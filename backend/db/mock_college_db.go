@@ -13,7 +13,7 @@ type StudentInfo struct {
 }
 
 // MockCollegeDatabase simulates external college verification systems
-// In production, this would be replaced with actual API calls to college databases
+// Colleges with VerifierType "mock" are checked against this map (see student_verifier.go)
 var MockCollegeDatabase = map[string]map[string]StudentInfo{
 	"TMSL": {
 		// --- Information Technology (IT) - 35 Students ---
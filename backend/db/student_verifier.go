@@ -0,0 +1,274 @@
+package db
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"unilink-backend/models"
+)
+
+// Supported verifier backends (stored in College.VerifierType)
+const (
+	VerifierMock = "mock" // In-memory MockCollegeDatabase
+	VerifierCSV  = "csv"  // Roster file uploaded by the college
+	VerifierHTTP = "http" // External JSON endpoint run by the college
+)
+
+// StudentVerifier checks a student ID against a college's source of truth
+type StudentVerifier interface {
+	Verify(studentID string) (*StudentInfo, error)
+}
+
+// NewStudentVerifier returns the verifier configured for a college
+func NewStudentVerifier(college *models.College) (StudentVerifier, error) {
+	switch college.VerifierType {
+	case "", VerifierMock:
+		return &MockVerifier{CollegeCode: college.CollegeCode}, nil
+	case VerifierCSV:
+		if college.VerifierConfig == "" {
+			return nil, fmt.Errorf("college %s has no roster file configured", college.CollegeCode)
+		}
+		return &CSVRosterVerifier{Path: college.VerifierConfig}, nil
+	case VerifierHTTP:
+		if college.VerifierConfig == "" {
+			return nil, fmt.Errorf("college %s has no verification endpoint configured", college.CollegeCode)
+		}
+		return &HTTPVerifier{
+			Endpoint: college.VerifierConfig,
+			APIKey:   college.VerifierAPIKey,
+			Client:   &http.Client{Timeout: 10 * time.Second},
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown verifier type %q for college %s", college.VerifierType, college.CollegeCode)
+	}
+}
+
+// IsValidVerifierType reports whether t names a supported backend
+func IsValidVerifierType(t string) bool {
+	return t == VerifierMock || t == VerifierCSV || t == VerifierHTTP
+}
+
+// ============================================
+// MOCK BACKEND
+// ============================================
+
+// MockVerifier looks students up in MockCollegeDatabase
+type MockVerifier struct {
+	CollegeCode string
+}
+
+// Verify implements StudentVerifier
+func (v *MockVerifier) Verify(studentID string) (*StudentInfo, error) {
+	return VerifyStudentID(v.CollegeCode, studentID)
+}
+
+// ============================================
+// CSV ROSTER BACKEND
+// ============================================
+
+// CSVRosterVerifier reads a roster CSV with a header row containing
+// studentId, name, email, department and semester columns
+type CSVRosterVerifier struct {
+	Path string
+}
+
+// rosterCache avoids re-parsing an unchanged roster file on every registration
+var rosterCache = struct {
+	sync.Mutex
+	entries map[string]cachedRoster
+}{entries: make(map[string]cachedRoster)}
+
+type cachedRoster struct {
+	modTime  time.Time
+	students map[string]StudentInfo
+}
+
+// Verify implements StudentVerifier
+func (v *CSVRosterVerifier) Verify(studentID string) (*StudentInfo, error) {
+	students, err := v.load()
+	if err != nil {
+		return nil, err
+	}
+
+	info, exists := students[studentID]
+	if !exists || !info.IsValid {
+		return nil, fmt.Errorf("student ID %s not found in roster", studentID)
+	}
+	return &info, nil
+}
+
+func (v *CSVRosterVerifier) load() (map[string]StudentInfo, error) {
+	stat, err := os.Stat(v.Path)
+	if err != nil {
+		return nil, fmt.Errorf("roster file unavailable: %w", err)
+	}
+
+	rosterCache.Lock()
+	defer rosterCache.Unlock()
+
+	if cached, ok := rosterCache.entries[v.Path]; ok && cached.modTime.Equal(stat.ModTime()) {
+		return cached.students, nil
+	}
+
+	f, err := os.Open(v.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open roster: %w", err)
+	}
+	defer f.Close()
+
+	students, err := ParseRoster(f)
+	if err != nil {
+		return nil, err
+	}
+
+	rosterCache.entries[v.Path] = cachedRoster{modTime: stat.ModTime(), students: students}
+	return students, nil
+}
+
+// ParseRoster reads roster CSV data into a map keyed by student ID.
+// Column names are matched case-insensitively; an optional "isValid" column
+// lets a college keep graduated students in the file without accepting them.
+func ParseRoster(r io.Reader) (map[string]StudentInfo, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read roster header: %w", err)
+	}
+
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, required := range []string{"studentid", "name", "email", "department", "semester"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("roster is missing required column %q", required)
+		}
+	}
+
+	students := make(map[string]StudentInfo)
+	line := 1
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		line++
+		if err != nil {
+			return nil, fmt.Errorf("roster line %d: %w", line, err)
+		}
+
+		semester, err := strconv.Atoi(strings.TrimSpace(record[columns["semester"]]))
+		if err != nil {
+			return nil, fmt.Errorf("roster line %d: invalid semester", line)
+		}
+
+		isValid := true
+		if idx, ok := columns["isvalid"]; ok {
+			if parsed, err := strconv.ParseBool(strings.TrimSpace(record[idx])); err == nil {
+				isValid = parsed
+			}
+		}
+
+		info := StudentInfo{
+			StudentID:  strings.TrimSpace(record[columns["studentid"]]),
+			Name:       strings.TrimSpace(record[columns["name"]]),
+			Email:      strings.TrimSpace(record[columns["email"]]),
+			Department: strings.TrimSpace(record[columns["department"]]),
+			Semester:   semester,
+			IsValid:    isValid,
+		}
+		if info.StudentID == "" {
+			continue
+		}
+		students[info.StudentID] = info
+	}
+
+	return students, nil
+}
+
+// ============================================
+// HTTP BACKEND
+// ============================================
+
+// HTTPVerifier calls GET {Endpoint}?studentId=... and expects a JSON body
+// with studentId, name, email, department, semester and isValid fields.
+// A 404 response means the student does not exist.
+type HTTPVerifier struct {
+	Endpoint string
+	APIKey   string // Sent as a Bearer token when set
+	Client   *http.Client
+}
+
+type httpVerifierResponse struct {
+	StudentID  string `json:"studentId"`
+	Name       string `json:"name"`
+	Email      string `json:"email"`
+	Department string `json:"department"`
+	Semester   int    `json:"semester"`
+	IsValid    bool   `json:"isValid"`
+}
+
+// Verify implements StudentVerifier
+func (v *HTTPVerifier) Verify(studentID string) (*StudentInfo, error) {
+	endpoint, err := url.Parse(v.Endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid verification endpoint: %w", err)
+	}
+	query := endpoint.Query()
+	query.Set("studentId", studentID)
+	endpoint.RawQuery = query.Encode()
+
+	req, err := http.NewRequest(http.MethodGet, endpoint.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if v.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+v.APIKey)
+	}
+
+	client := v.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("verification request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("student ID %s not found", studentID)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("verification endpoint returned status %d", resp.StatusCode)
+	}
+
+	var body httpVerifierResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&body); err != nil {
+		return nil, fmt.Errorf("invalid verification response: %w", err)
+	}
+	if !body.IsValid || body.StudentID != studentID {
+		return nil, fmt.Errorf("student ID %s not found or invalid", studentID)
+	}
+
+	return &StudentInfo{
+		StudentID:  body.StudentID,
+		Name:       body.Name,
+		Email:      body.Email,
+		Department: body.Department,
+		Semester:   body.Semester,
+		IsValid:    body.IsValid,
+	}, nil
+}
@@ -0,0 +1,209 @@
+package db
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"unilink-backend/models"
+)
+
+// newStubVerifier starts a stub college endpoint and returns an HTTPVerifier for it
+func newStubVerifier(t *testing.T, handler http.HandlerFunc) *HTTPVerifier {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return &HTTPVerifier{Endpoint: server.URL + "/verify", APIKey: "secret", Client: server.Client()}
+}
+
+func TestHTTPVerifierFound(t *testing.T) {
+	verifier := newStubVerifier(t, func(w http.ResponseWriter, r *http.Request) {
+		if got := r.URL.Query().Get("studentId"); got != "21BCE1001" {
+			t.Errorf("studentId = %q, want 21BCE1001", got)
+		}
+		if got := r.Header.Get("Authorization"); got != "Bearer secret" {
+			t.Errorf("Authorization = %q, want Bearer secret", got)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"studentId": "21BCE1001", "name": "Asha Rao", "email": "asha@example.edu",
+			"department": "Computer Science", "semester": 4, "isValid": true}`))
+	})
+
+	info, err := verifier.Verify("21BCE1001")
+	if err != nil {
+		t.Fatalf("Verify returned error: %v", err)
+	}
+	want := StudentInfo{
+		StudentID:  "21BCE1001",
+		Name:       "Asha Rao",
+		Email:      "asha@example.edu",
+		Department: "Computer Science",
+		Semester:   4,
+		IsValid:    true,
+	}
+	if *info != want {
+		t.Errorf("Verify = %+v, want %+v", *info, want)
+	}
+}
+
+func TestHTTPVerifierNotFound(t *testing.T) {
+	verifier := newStubVerifier(t, func(w http.ResponseWriter, r *http.Request) {
+		http.NotFound(w, r)
+	})
+
+	if _, err := verifier.Verify("21BCE9999"); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("Verify error = %v, want a not found error", err)
+	}
+}
+
+func TestHTTPVerifierErrorStatus(t *testing.T) {
+	verifier := newStubVerifier(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+
+	if _, err := verifier.Verify("21BCE1001"); err == nil || !strings.Contains(err.Error(), "status 503") {
+		t.Errorf("Verify error = %v, want a status 503 error", err)
+	}
+}
+
+func TestHTTPVerifierMalformedBody(t *testing.T) {
+	verifier := newStubVerifier(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"studentId": "21BCE1001", "semester": "fourth"`))
+	})
+
+	if _, err := verifier.Verify("21BCE1001"); err == nil || !strings.Contains(err.Error(), "invalid verification response") {
+		t.Errorf("Verify error = %v, want an invalid response error", err)
+	}
+}
+
+func TestParseRosterHeader(t *testing.T) {
+	// Columns in any order and case, with extras ignored
+	roster := "Semester,EMAIL,studentId,notes,Name,department\n" +
+		"4, asha@example.edu, 21BCE1001, transfer, Asha Rao, Computer Science\n"
+	students, err := ParseRoster(strings.NewReader(roster))
+	if err != nil {
+		t.Fatalf("ParseRoster returned error: %v", err)
+	}
+	want := StudentInfo{
+		StudentID:  "21BCE1001",
+		Name:       "Asha Rao",
+		Email:      "asha@example.edu",
+		Department: "Computer Science",
+		Semester:   4,
+		IsValid:    true,
+	}
+	if got := students["21BCE1001"]; got != want {
+		t.Errorf("student = %+v, want %+v", got, want)
+	}
+
+	_, err = ParseRoster(strings.NewReader("studentId,name,email,semester\n21BCE1001,Asha,a@example.edu,4\n"))
+	if err == nil || !strings.Contains(err.Error(), `"department"`) {
+		t.Errorf("error = %v, want a missing department column error", err)
+	}
+
+	if _, err := ParseRoster(strings.NewReader("")); err == nil {
+		t.Error("empty roster parsed without error")
+	}
+}
+
+func TestParseRosterRows(t *testing.T) {
+	header := "studentId,name,email,department,semester,isValid\n"
+	students, err := ParseRoster(strings.NewReader(header +
+		"21BCE1001,Asha Rao,asha@example.edu,CS,4,true\n" +
+		"21BCE1002,Ravi Das,ravi@example.edu,CS,8,false\n" +
+		",No ID,none@example.edu,CS,2,true\n"))
+	if err != nil {
+		t.Fatalf("ParseRoster returned error: %v", err)
+	}
+	if len(students) != 2 {
+		t.Errorf("got %d students, want 2 (the row without an ID is skipped)", len(students))
+	}
+	if students["21BCE1002"].IsValid {
+		t.Error("isValid=false was not applied")
+	}
+
+	malformed := map[string]string{
+		"invalid semester": header + "21BCE1001,Asha Rao,asha@example.edu,CS,4,true\n21BCE1002,Ravi Das,ravi@example.edu,CS,eighth,true\n",
+		"missing field":    header + "21BCE1001,Asha Rao,asha@example.edu,CS,4,true\n21BCE1002,Ravi Das,ravi@example.edu,CS\n",
+	}
+	for name, roster := range malformed {
+		if _, err := ParseRoster(strings.NewReader(roster)); err == nil || !strings.Contains(err.Error(), "line 3") {
+			t.Errorf("%s: error = %v, want an error on line 3", name, err)
+		}
+	}
+}
+
+func TestCSVRosterVerifierReloadsChangedFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "roster.csv")
+	header := "studentId,name,email,department,semester\n"
+	writeRoster := func(rows string, modTime time.Time) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(header+rows), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	verifier := &CSVRosterVerifier{Path: path}
+	first := time.Now().Add(-time.Hour)
+
+	writeRoster("21BCE1001,Asha Rao,asha@example.edu,CS,4\n", first)
+	if _, err := verifier.Verify("21BCE1001"); err != nil {
+		t.Fatalf("Verify returned error: %v", err)
+	}
+	if _, err := verifier.Verify("21BCE1002"); err == nil {
+		t.Error("student missing from the roster was verified")
+	}
+
+	// Same modification time: the cached roster is still used
+	writeRoster("21BCE1002,Ravi Das,ravi@example.edu,CS,8\n", first)
+	if _, err := verifier.Verify("21BCE1001"); err != nil {
+		t.Errorf("cached roster was not used: %v", err)
+	}
+
+	// A newer file is parsed again
+	writeRoster("21BCE1002,Ravi Das,ravi@example.edu,CS,8\n", first.Add(time.Minute))
+	if _, err := verifier.Verify("21BCE1002"); err != nil {
+		t.Errorf("updated roster was not reloaded: %v", err)
+	}
+	if _, err := verifier.Verify("21BCE1001"); err == nil {
+		t.Error("student removed from the roster is still verified")
+	}
+
+	os.Remove(path)
+	if _, err := verifier.Verify("21BCE1002"); err == nil || !strings.Contains(err.Error(), "unavailable") {
+		t.Errorf("error = %v, want a roster unavailable error", err)
+	}
+}
+
+func TestMockVerifier(t *testing.T) {
+	verifier, err := NewStudentVerifier(&models.College{CollegeCode: "TMSL"})
+	if err != nil {
+		t.Fatalf("NewStudentVerifier returned error: %v", err)
+	}
+	if _, ok := verifier.(*MockVerifier); !ok {
+		t.Fatalf("verifier = %T, want the mock verifier for an unset type", verifier)
+	}
+
+	info, err := verifier.Verify("TMSL23IT001")
+	if err != nil {
+		t.Fatalf("Verify returned error: %v", err)
+	}
+	if info.Name != "Aarav Sharma" {
+		t.Errorf("Name = %q, want Aarav Sharma", info.Name)
+	}
+	if _, err := verifier.Verify("TMSL99XX999"); err == nil {
+		t.Error("unknown student was verified")
+	}
+
+	unknown := &MockVerifier{CollegeCode: "NOPE"}
+	if _, err := unknown.Verify("TMSL23IT001"); err == nil || !strings.Contains(err.Error(), "college NOPE") {
+		t.Errorf("error = %v, want an unknown college error", err)
+	}
+}
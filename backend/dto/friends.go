@@ -0,0 +1,12 @@
+package dto
+
+// FriendProfileData contains friend's basic info
+type FriendProfileData struct {
+	ID             uint   `json:"id"`
+	Name           string `json:"name"`
+	Email          string `json:"email"` // Consider removing email if not needed on frontend for this
+	StudentID      string `json:"studentId"`
+	ProfilePicture string `json:"profilePicture"`
+	Department     string `json:"department"`
+	Semester       int    `json:"semester"`
+}
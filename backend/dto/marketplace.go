@@ -0,0 +1,73 @@
+package dto
+
+// ListingResponse includes seller info for display
+type ListingResponse struct {
+	ID          uint               `json:"id"`
+	Title       string             `json:"title"`
+	Description string             `json:"description"`
+	Price       float64            `json:"price"`
+	ImageURL    string             `json:"imageUrl"` // Cover image (first upload, or the legacy URL)
+	Images      []ListingImageInfo `json:"images"`
+	Status      string             `json:"status"`
+	Category    *CategoryInfo      `json:"category"`
+	Condition   string             `json:"condition,omitempty"`
+	Seller      SellerInfo         `json:"seller"`
+	CreatedAt   string             `json:"createdAt"`
+	// *** NEW Fields for Reservation ***
+	Buyer         *SellerInfo `json:"buyer,omitempty"`         // Use SellerInfo for buyer details
+	ReservedUntil *string     `json:"reservedUntil,omitempty"` // String for JSON response
+	AgreedPrice   *float64    `json:"agreedPrice,omitempty"`   // Price agreed through an offer
+}
+
+// SellerInfo contains safe seller data (also used for Buyer info)
+type SellerInfo struct {
+	ID          uint    `json:"id"`
+	Name        string  `json:"name"`
+	StudentID   string  `json:"studentId"`
+	Rating      float64 `json:"rating"`      // Average score, 0 when unrated
+	RatingCount int     `json:"ratingCount"` // Number of visible ratings
+}
+
+// ListingImageInfo is one photo in a listing response
+type ListingImageInfo struct {
+	UploadID     uint   `json:"uploadId"`
+	URL          string `json:"url"`
+	ThumbnailURL string `json:"thumbnailUrl"`
+	Position     int    `json:"position"`
+}
+
+// CategoryInfo is the category data shown on listings
+type CategoryInfo struct {
+	ID   uint   `json:"id"`
+	Name string `json:"name"`
+	Slug string `json:"slug"`
+}
+
+// OfferResponse is an offer with the listing and buyer details needed to display it
+type OfferResponse struct {
+	ID         uint       `json:"id"`
+	ListingID  uint       `json:"listingId"`
+	Title      string     `json:"title"`
+	ListPrice  float64    `json:"listPrice"`
+	Buyer      SellerInfo `json:"buyer"`
+	SellerID   uint       `json:"sellerId"`
+	Amount     float64    `json:"amount"`
+	ProposedBy uint       `json:"proposedBy"`
+	Message    string     `json:"message"`
+	Status     string     `json:"status"`
+	CreatedAt  string     `json:"createdAt"`
+	UpdatedAt  string     `json:"updatedAt"`
+}
+
+// RatingResponse is a rating with the rater's public details
+type RatingResponse struct {
+	ID        uint       `json:"id"`
+	ListingID uint       `json:"listingId"`
+	Rater     SellerInfo `json:"rater"`
+	RateeID   uint       `json:"rateeId"`
+	RateeRole string     `json:"rateeRole"`
+	Score     int        `json:"score"`
+	Review    string     `json:"review"`
+	CreatedAt string     `json:"createdAt"`
+	RemovedAt *string    `json:"removedAt,omitempty"` // Only shown to college admins
+}
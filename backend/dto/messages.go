@@ -0,0 +1,62 @@
+// Package dto holds the response types that handlers return and WebSocket events
+// carry, so both share one definition (and the event schema is typed).
+package dto
+
+// MessageResponse contains message data
+type MessageResponse struct {
+	ID               uint              `json:"id"`
+	Content          string            `json:"content"`
+	Type             string            `json:"type"`
+	ConversationType string            `json:"conversationType"`
+	ConversationID   string            `json:"conversationId"`
+	Sender           MessageSenderData `json:"sender"`
+	IsRead           bool              `json:"isRead"`           // Own messages: read by someone else. Others' messages: read by you
+	ReadBy           []uint            `json:"readBy,omitempty"` // Own messages only: who has read it
+	CreatedAt        string            `json:"createdAt"`
+	// Edits
+	Edited   bool    `json:"edited"`
+	EditedAt *string `json:"editedAt,omitempty"`
+	// Reactions (aggregated per emoji)
+	Reactions []ReactionSummary `json:"reactions,omitempty"`
+	// Threads
+	ReplyTo    *QuotedMessage `json:"replyTo,omitempty"`    // Preview of the parent message
+	ReplyCount int            `json:"replyCount,omitempty"` // Replies to this message
+	// Files
+	Attachments []AttachmentInfo `json:"attachments,omitempty"`
+}
+
+// QuotedMessage is the preview of a replied-to message shown above a reply
+type QuotedMessage struct {
+	ID         uint   `json:"id"`
+	SenderID   uint   `json:"senderId"`
+	SenderName string `json:"senderName"`
+	Content    string `json:"content"` // Truncated for the preview
+	Type       string `json:"type"`
+	IsDeleted  bool   `json:"isDeleted"`
+}
+
+// MessageSenderData contains sender info
+type MessageSenderData struct {
+	ID             uint   `json:"id"`
+	Name           string `json:"name"`
+	ProfilePicture string `json:"profilePicture"`
+}
+
+// ReactionSummary is the aggregated count for one emoji on a message
+type ReactionSummary struct {
+	Emoji   string `json:"emoji"`
+	Count   int    `json:"count"`
+	Reacted bool   `json:"reacted"` // Whether the viewer is one of them
+}
+
+// AttachmentInfo is one file in a message response
+type AttachmentInfo struct {
+	ID           uint   `json:"id"`
+	FileName     string `json:"fileName"`
+	ContentType  string `json:"contentType"`
+	Size         int64  `json:"size"`
+	Width        int    `json:"width,omitempty"`
+	Height       int    `json:"height,omitempty"`
+	URL          string `json:"url"`
+	ThumbnailURL string `json:"thumbnailUrl,omitempty"` // Images only
+}
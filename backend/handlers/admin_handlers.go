@@ -1,18 +1,29 @@
 package handlers
 
 import (
+	"bytes"
 	"encoding/json"
+	"fmt"
+	"io"
+	"log"
 	"net/http"
+	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
 	"unilink-backend/db"
 	"unilink-backend/models"
 	"unilink-backend/utils"
+	"unilink-backend/websocket"
 
 	"github.com/gorilla/mux"
 )
 
+// maxRosterSize caps uploaded roster CSVs at 10 MB
+const maxRosterSize = 10 << 20
+
 // ============================================
 // SETUP FUNCTION (For creating first platform admin)
 // ============================================
@@ -125,7 +136,7 @@ func LoginAdmin(w http.ResponseWriter, r *http.Request) {
 	result := db.DB.Preload("College").
 		Where("email = ? AND (role = ? OR role = ?)", req.Email, "college_admin", "platform_admin").
 		First(&user)
-	
+
 	if result.Error != nil {
 		respondWithError(w, http.StatusUnauthorized, "Invalid credentials or not an admin")
 		return
@@ -137,8 +148,13 @@ func LoginAdmin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Generate JWT token
-	token, err := utils.GenerateJWT(&user)
+	if err := utils.EnsureUserActive(&user); err != nil {
+		respondWithSuspension(w, &user, err)
+		return
+	}
+
+	// Start a session and generate the token pair
+	token, refreshToken, err := utils.CreateSession(&user, r)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Failed to generate token")
 		return
@@ -158,8 +174,10 @@ func LoginAdmin(w http.ResponseWriter, r *http.Request) {
 	}
 
 	response := LoginResponse{
-		Token: token,
-		User:  userData,
+		Token:        token,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int(utils.AccessTokenTTL().Seconds()),
+		User:         userData,
 	}
 
 	respondWithJSON(w, http.StatusOK, response)
@@ -193,17 +211,152 @@ func GetCollegeStudents(w http.ResponseWriter, r *http.Request) {
 	var response []map[string]interface{}
 	for _, user := range users {
 		response = append(response, map[string]interface{}{
-			"id":        user.ID,
-			"name":      user.Name,
-			"email":     user.Email,
-			"studentId": user.StudentID,
-			"createdAt": user.CreatedAt.Format("2006-01-02 15:04:05"),
+			"id":               user.ID,
+			"name":             user.Name,
+			"email":            user.Email,
+			"studentId":        user.StudentID,
+			"status":           user.Status,
+			"suspensionReason": user.SuspensionReason,
+			"suspendedAt":      user.SuspendedAt,
+			"suspendedUntil":   user.SuspendedUntil,
+			"suspendedBy":      user.SuspendedBy,
+			"createdAt":        user.CreatedAt.Format("2006-01-02 15:04:05"),
 		})
 	}
 
 	respondWithJSON(w, http.StatusOK, response)
 }
 
+// SuspendStudent locks a student out of the platform immediately.
+// New logins and refreshes are refused, existing sessions are revoked and open
+// WebSocket connections are closed. An optional "until" ends the suspension automatically.
+func SuspendStudent(w http.ResponseWriter, r *http.Request) {
+	claims, ok := utils.GetUserClaims(r)
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "User claims not found")
+		return
+	}
+
+	vars := mux.Vars(r)
+	studentID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid student ID")
+		return
+	}
+
+	var req struct {
+		Reason string  `json:"reason"`
+		Until  *string `json:"until"` // RFC3339, optional
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	req.Reason = strings.TrimSpace(req.Reason)
+	if req.Reason == "" {
+		respondWithError(w, http.StatusBadRequest, "Suspension reason is required")
+		return
+	}
+
+	var until *time.Time
+	if req.Until != nil && strings.TrimSpace(*req.Until) != "" {
+		parsed, err := time.Parse(time.RFC3339, strings.TrimSpace(*req.Until))
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid end date, expected RFC3339 format")
+			return
+		}
+		if !parsed.After(time.Now()) {
+			respondWithError(w, http.StatusBadRequest, "Suspension end date must be in the future")
+			return
+		}
+		until = &parsed
+	}
+
+	// Only students of the admin's own college can be suspended
+	var student models.User
+	result := db.DB.Where("id = ? AND college_id = ? AND role = ?", studentID, claims.CollegeID, "student").First(&student)
+	if result.Error != nil {
+		respondWithError(w, http.StatusNotFound, "Student not found in your college")
+		return
+	}
+
+	now := time.Now()
+	if err := db.DB.Model(&student).Updates(map[string]interface{}{
+		"status":            "suspended",
+		"suspension_reason": req.Reason,
+		"suspended_at":      now,
+		"suspended_until":   until,
+		"suspended_by":      claims.UserID,
+	}).Error; err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to suspend student")
+		return
+	}
+
+	// Revoke existing tokens and drop live connections
+	if _, err := utils.RevokeAllSessions(student.ID); err != nil {
+		log.Printf("Error revoking sessions for suspended user %d: %v", student.ID, err)
+	}
+	if hub, ok := r.Context().Value(utils.HubKey).(*websocket.Hub); ok && hub != nil {
+		hub.Disconnect(student.ID, 0)
+	} else {
+		log.Printf("Warning: Hub not found in context for SuspendStudent. Ok: %v, HubNil: %v", ok, hub == nil)
+	}
+
+	log.Printf("User %d suspended by admin %d (until: %v): %s", student.ID, claims.UserID, until, req.Reason)
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"message":        "Student suspended successfully",
+		"studentId":      student.ID,
+		"suspendedUntil": until,
+	})
+}
+
+// ReinstateStudent lifts a student's suspension
+func ReinstateStudent(w http.ResponseWriter, r *http.Request) {
+	claims, ok := utils.GetUserClaims(r)
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "User claims not found")
+		return
+	}
+
+	vars := mux.Vars(r)
+	studentID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid student ID")
+		return
+	}
+
+	var student models.User
+	result := db.DB.Where("id = ? AND college_id = ? AND role = ?", studentID, claims.CollegeID, "student").First(&student)
+	if result.Error != nil {
+		respondWithError(w, http.StatusNotFound, "Student not found in your college")
+		return
+	}
+
+	if student.Status != "suspended" {
+		respondWithError(w, http.StatusBadRequest, "Student is not suspended")
+		return
+	}
+
+	if err := db.DB.Model(&student).Updates(map[string]interface{}{
+		"status":            "active",
+		"suspension_reason": "",
+		"suspended_at":      nil,
+		"suspended_until":   nil,
+		"suspended_by":      nil,
+	}).Error; err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to reinstate student")
+		return
+	}
+
+	log.Printf("User %d reinstated by admin %d", student.ID, claims.UserID)
+
+	respondWithJSON(w, http.StatusOK, map[string]string{
+		"message": "Student reinstated successfully",
+	})
+}
+
 // GetCollegeListings returns all marketplace listings from the admin's college
 func GetCollegeListings(w http.ResponseWriter, r *http.Request) {
 	claims, ok := utils.GetUserClaims(r)
@@ -213,11 +366,17 @@ func GetCollegeListings(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Get listings from admin's college only
+	query, errMsg := applyCategoryFilters(
+		db.DB.Preload("Seller").Preload("Category").Preload("Images", orderImages).Where("college_id = ?", claims.CollegeID),
+		r.URL.Query(),
+	)
+	if errMsg != "" {
+		respondWithError(w, http.StatusBadRequest, errMsg)
+		return
+	}
+
 	var listings []models.MarketplaceListing
-	result := db.DB.Preload("Seller").
-		Where("college_id = ?", claims.CollegeID).
-		Order("created_at DESC").
-		Find(&listings)
+	result := query.Order("created_at DESC").Find(&listings)
 
 	if result.Error != nil {
 		respondWithError(w, http.StatusInternalServerError, "Failed to fetch listings")
@@ -227,20 +386,7 @@ func GetCollegeListings(w http.ResponseWriter, r *http.Request) {
 	// Transform to response
 	var response []ListingResponse
 	for _, listing := range listings {
-		response = append(response, ListingResponse{
-			ID:          listing.ID,
-			Title:       listing.Title,
-			Description: listing.Description,
-			Price:       listing.Price,
-			ImageURL:    listing.ImageURL,
-			Status:      listing.Status,
-			Seller: SellerInfo{
-				ID:        listing.Seller.ID,
-				Name:      listing.Seller.Name,
-				StudentID: listing.Seller.StudentID,
-			},
-			CreatedAt: listing.CreatedAt.Format("2006-01-02 15:04:05"),
-		})
+		response = append(response, toListingResponse(listing))
 	}
 
 	respondWithJSON(w, http.StatusOK, response)
@@ -265,14 +411,34 @@ func DeleteCollegeListing(w http.ResponseWriter, r *http.Request) {
 	// Find listing and verify it belongs to admin's college
 	var listing models.MarketplaceListing
 	result := db.DB.Where("id = ? AND college_id = ?", listingID, claims.CollegeID).First(&listing)
-	
+
 	if result.Error != nil {
 		respondWithError(w, http.StatusNotFound, "Listing not found in your college")
 		return
 	}
 
-	// Delete listing
-	db.DB.Delete(&listing)
+	// Delete listing and end its open negotiations
+	tx := db.DB.Begin()
+	if tx.Error != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if err := tx.Delete(&listing).Error; err != nil {
+		tx.Rollback()
+		respondWithError(w, http.StatusInternalServerError, "Failed to delete listing")
+		return
+	}
+	closed, err := closeOpenOffers(tx, listing.ID, 0)
+	if err != nil {
+		tx.Rollback()
+		respondWithError(w, http.StatusInternalServerError, "Failed to close open offers")
+		return
+	}
+	if err := tx.Commit().Error; err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to delete listing")
+		return
+	}
+	notifyOffersClosed(r, "DeleteCollegeListing", closed)
 
 	respondWithJSON(w, http.StatusOK, map[string]string{
 		"message": "Listing deleted successfully",
@@ -308,18 +474,216 @@ func GetCollegeStats(w http.ResponseWriter, r *http.Request) {
 		Where("college_id = ? AND status = ?", claims.CollegeID, "available").
 		Count(&activeListings)
 
+	// Count listings per category (uncategorized listings have a null category)
+	type categoryCount struct {
+		CategoryID *uint  `json:"categoryId"`
+		Name       string `json:"name"`
+		Slug       string `json:"slug"`
+		Total      int64  `json:"total"`
+		Active     int64  `json:"active"`
+	}
+	var categoryCounts []categoryCount
+	if err := db.DB.Table("marketplace_listings").
+		Select("marketplace_listings.category_id, COALESCE(listing_categories.name, 'Uncategorized') AS name, "+
+			"COALESCE(listing_categories.slug, '') AS slug, COUNT(*) AS total, "+
+			"COUNT(*) FILTER (WHERE marketplace_listings.status = 'available') AS active").
+		Joins("LEFT JOIN listing_categories ON listing_categories.id = marketplace_listings.category_id").
+		Where("marketplace_listings.college_id = ? AND marketplace_listings.deleted_at IS NULL", claims.CollegeID).
+		Group("marketplace_listings.category_id, listing_categories.name, listing_categories.slug").
+		Order("total DESC").
+		Scan(&categoryCounts).Error; err != nil {
+		log.Printf("Error counting listings per category for college %d: %v", claims.CollegeID, err)
+	}
+
 	stats := map[string]interface{}{
-		"collegeId":      college.ID,
-		"collegeCode":    college.CollegeCode,
-		"collegeName":    college.Name,
-		"totalStudents":  studentCount,
-		"totalListings":  totalListings,
-		"activeListings": activeListings,
+		"collegeId":          college.ID,
+		"collegeCode":        college.CollegeCode,
+		"collegeName":        college.Name,
+		"totalStudents":      studentCount,
+		"totalListings":      totalListings,
+		"activeListings":     activeListings,
+		"listingsByCategory": categoryCounts,
 	}
 
 	respondWithJSON(w, http.StatusOK, stats)
 }
 
+// UploadStudentRoster lets a college admin upload a CSV roster used to verify registrations.
+// The file must have a header row with studentId, name, email, department and semester.
+// A college using another verifier must send switchVerifier=true to move to the roster.
+func UploadStudentRoster(w http.ResponseWriter, r *http.Request) {
+	claims, ok := utils.GetUserClaims(r)
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "User claims not found")
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxRosterSize)
+	if err := r.ParseMultipartForm(maxRosterSize); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Roster file is missing or too large")
+		return
+	}
+
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Roster file is required (form field 'file')")
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Failed to read roster file")
+		return
+	}
+
+	// Validate before replacing the current roster
+	students, err := db.ParseRoster(bytes.NewReader(data))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var college models.College
+	if err := db.DB.First(&college, claims.CollegeID).Error; err != nil {
+		respondWithError(w, http.StatusNotFound, "College not found")
+		return
+	}
+
+	// Replacing another verifier (e.g. a college's HTTP endpoint) has to be asked for
+	if college.VerifierType != db.VerifierCSV && r.FormValue("switchVerifier") != "true" {
+		current := college.VerifierType
+		if current == "" {
+			current = db.VerifierMock
+		}
+		respondWithError(w, http.StatusConflict, fmt.Sprintf(
+			"College verifies students with the %s verifier. Send switchVerifier=true to replace it with this roster.", current))
+		return
+	}
+
+	rosterDir := os.Getenv("ROSTER_DIR")
+	if rosterDir == "" {
+		rosterDir = "rosters"
+	}
+	if err := os.MkdirAll(rosterDir, 0o750); err != nil {
+		log.Printf("Error creating roster directory %s: %v", rosterDir, err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to store roster")
+		return
+	}
+
+	// Write to a temp file and rename so in-flight registrations never see a partial roster.
+	// Each upload gets its own temp file, so concurrent uploads can't interleave.
+	rosterPath := filepath.Join(rosterDir, college.CollegeCode+".csv")
+	tmp, err := os.CreateTemp(rosterDir, college.CollegeCode+"-*.csv.tmp")
+	if err != nil {
+		log.Printf("Error creating temp roster for college %s: %v", college.CollegeCode, err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to store roster")
+		return
+	}
+	defer os.Remove(tmp.Name()) // No-op once renamed
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), 0o640)
+	}
+	if err != nil {
+		log.Printf("Error writing roster for college %s: %v", college.CollegeCode, err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to store roster")
+		return
+	}
+	if err := os.Rename(tmp.Name(), rosterPath); err != nil {
+		log.Printf("Error replacing roster for college %s: %v", college.CollegeCode, err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to store roster")
+		return
+	}
+
+	college.VerifierType = db.VerifierCSV
+	college.VerifierConfig = rosterPath
+	if err := db.DB.Save(&college).Error; err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to update college verifier")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"message":      "Roster uploaded successfully",
+		"studentCount": len(students),
+	})
+}
+
+// CollegeSettingsRequest is the payload for updating college-level settings
+type CollegeSettingsRequest struct {
+	ReservationWindowHours *int `json:"reservationWindowHours"`
+}
+
+// collegeSettingsResponse builds the settings view of a college
+func collegeSettingsResponse(college models.College) map[string]interface{} {
+	return map[string]interface{}{
+		"collegeId":              college.ID,
+		"reservationWindowHours": college.ReservationWindowHours,
+		// Internal paths and URLs, so only admins ever see them
+		"verifierType":   college.VerifierType,
+		"verifierConfig": college.VerifierConfig,
+	}
+}
+
+// GetCollegeSettings returns configurable settings for the admin's college
+func GetCollegeSettings(w http.ResponseWriter, r *http.Request) {
+	claims, ok := utils.GetUserClaims(r)
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "User claims not found")
+		return
+	}
+
+	var college models.College
+	if err := db.DB.First(&college, claims.CollegeID).Error; err != nil {
+		respondWithError(w, http.StatusNotFound, "College not found")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, collegeSettingsResponse(college))
+}
+
+// UpdateCollegeSettings lets a college admin change college-level settings
+func UpdateCollegeSettings(w http.ResponseWriter, r *http.Request) {
+	claims, ok := utils.GetUserClaims(r)
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "User claims not found")
+		return
+	}
+
+	var req CollegeSettingsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	var college models.College
+	if err := db.DB.First(&college, claims.CollegeID).Error; err != nil {
+		respondWithError(w, http.StatusNotFound, "College not found")
+		return
+	}
+
+	if req.ReservationWindowHours != nil {
+		if *req.ReservationWindowHours < 1 || *req.ReservationWindowHours > 168 {
+			respondWithError(w, http.StatusBadRequest, "Reservation window must be between 1 and 168 hours")
+			return
+		}
+		college.ReservationWindowHours = *req.ReservationWindowHours
+	}
+
+	if err := db.DB.Save(&college).Error; err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to update settings")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"message":  "Settings updated successfully",
+		"settings": collegeSettingsResponse(college),
+	})
+}
+
 // ============================================
 // PLATFORM ADMIN FUNCTIONS (Global Access)
 // ============================================
@@ -407,9 +771,12 @@ func CreateCollegeAdmin(w http.ResponseWriter, r *http.Request) {
 // AddCollege allows platform admin to add a new college
 func AddCollege(w http.ResponseWriter, r *http.Request) {
 	var req struct {
-		CollegeCode string `json:"collegeCode"`
-		Name        string `json:"name"`
-		LogoURL     string `json:"logoUrl"`
+		CollegeCode    string `json:"collegeCode"`
+		Name           string `json:"name"`
+		LogoURL        string `json:"logoUrl"`
+		VerifierType   string `json:"verifierType"`   // Optional, defaults to "mock"
+		VerifierConfig string `json:"verifierConfig"` // Endpoint URL for "http"
+		VerifierAPIKey string `json:"verifierApiKey"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -426,6 +793,19 @@ func AddCollege(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	req.VerifierType = strings.ToLower(strings.TrimSpace(req.VerifierType))
+	if req.VerifierType == "" {
+		req.VerifierType = db.VerifierMock
+	}
+	if !db.IsValidVerifierType(req.VerifierType) {
+		respondWithError(w, http.StatusBadRequest, "Verifier type must be 'mock', 'csv', or 'http'")
+		return
+	}
+	if req.VerifierType == db.VerifierHTTP && strings.TrimSpace(req.VerifierConfig) == "" {
+		respondWithError(w, http.StatusBadRequest, "Verification endpoint URL is required for the http verifier")
+		return
+	}
+
 	// Check if college exists
 	var existingCollege models.College
 	db.DB.Where("college_code = ?", req.CollegeCode).First(&existingCollege)
@@ -436,9 +816,12 @@ func AddCollege(w http.ResponseWriter, r *http.Request) {
 
 	// Create college
 	newCollege := models.College{
-		CollegeCode: req.CollegeCode,
-		Name:        req.Name,
-		LogoURL:     req.LogoURL,
+		CollegeCode:    req.CollegeCode,
+		Name:           req.Name,
+		LogoURL:        req.LogoURL,
+		VerifierType:   req.VerifierType,
+		VerifierConfig: strings.TrimSpace(req.VerifierConfig),
+		VerifierAPIKey: req.VerifierAPIKey,
 	}
 
 	result := db.DB.Create(&newCollege)
@@ -447,12 +830,76 @@ func AddCollege(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Give the new college the default marketplace categories
+	if err := db.SeedCategoriesForCollege(newCollege.ID); err != nil {
+		log.Printf("Error seeding categories for college %d: %v", newCollege.ID, err)
+	}
+
 	respondWithJSON(w, http.StatusCreated, map[string]interface{}{
 		"message": "College added successfully",
 		"college": newCollege,
 	})
 }
 
+// UpdateCollegeVerifier lets platform admin switch a college's student verification backend
+func UpdateCollegeVerifier(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	collegeID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid college ID")
+		return
+	}
+
+	var req struct {
+		VerifierType   string  `json:"verifierType"`
+		VerifierConfig string  `json:"verifierConfig"`
+		VerifierAPIKey *string `json:"verifierApiKey"` // nil = keep existing key
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	req.VerifierType = strings.ToLower(strings.TrimSpace(req.VerifierType))
+	req.VerifierConfig = strings.TrimSpace(req.VerifierConfig)
+	if !db.IsValidVerifierType(req.VerifierType) {
+		respondWithError(w, http.StatusBadRequest, "Verifier type must be 'mock', 'csv', or 'http'")
+		return
+	}
+	if req.VerifierType != db.VerifierMock && req.VerifierConfig == "" {
+		respondWithError(w, http.StatusBadRequest, "Verifier config is required for csv and http verifiers")
+		return
+	}
+
+	var college models.College
+	if err := db.DB.First(&college, collegeID).Error; err != nil {
+		respondWithError(w, http.StatusNotFound, "College not found")
+		return
+	}
+
+	college.VerifierType = req.VerifierType
+	college.VerifierConfig = req.VerifierConfig
+	if req.VerifierAPIKey != nil {
+		college.VerifierAPIKey = *req.VerifierAPIKey
+	}
+
+	// Make sure the configuration is usable before saving it
+	if _, err := db.NewStudentVerifier(&college); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := db.DB.Save(&college).Error; err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to update verifier")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"message": "College verifier updated successfully",
+		"college": college,
+	})
+}
+
 // GetAllStudents returns ALL students from ALL colleges (platform admin only)
 func GetAllStudents(w http.ResponseWriter, r *http.Request) {
 	var users []models.User
@@ -486,7 +933,7 @@ func GetAllStudents(w http.ResponseWriter, r *http.Request) {
 // GetAllListingsPlatform returns ALL marketplace listings from ALL colleges (platform admin only)
 func GetAllListingsPlatform(w http.ResponseWriter, r *http.Request) {
 	var listings []models.MarketplaceListing
-	result := db.DB.Preload("Seller").Preload("College").
+	result := db.DB.Preload("Seller").Preload("Category").Preload("Images", orderImages).Preload("College").
 		Order("created_at DESC").
 		Find(&listings)
 
@@ -505,22 +952,9 @@ func GetAllListingsPlatform(w http.ResponseWriter, r *http.Request) {
 	var response []PlatformListingResponse
 	for _, listing := range listings {
 		response = append(response, PlatformListingResponse{
-			ListingResponse: ListingResponse{
-				ID:          listing.ID,
-				Title:       listing.Title,
-				Description: listing.Description,
-				Price:       listing.Price,
-				ImageURL:    listing.ImageURL,
-				Status:      listing.Status,
-				Seller: SellerInfo{
-					ID:        listing.Seller.ID,
-					Name:      listing.Seller.Name,
-					StudentID: listing.Seller.StudentID,
-				},
-				CreatedAt: listing.CreatedAt.Format("2006-01-02 15:04:05"),
-			},
-			CollegeCode: listing.College.CollegeCode,
-			CollegeName: listing.College.Name,
+			ListingResponse: toListingResponse(listing),
+			CollegeCode:     listing.College.CollegeCode,
+			CollegeName:     listing.College.Name,
 		})
 	}
 
@@ -576,11 +1010,23 @@ func GetPlatformStats(w http.ResponseWriter, r *http.Request) {
 	}
 
 	response := map[string]interface{}{
-		"totalColleges":  totalColleges,
-		"totalStudents":  totalStudents,
-		"totalListings":  totalListings,
-		"collegeStats":   collegeStats,
+		"totalColleges": totalColleges,
+		"totalStudents": totalStudents,
+		"totalListings": totalListings,
+		"collegeStats":  collegeStats,
 	}
 
 	respondWithJSON(w, http.StatusOK, response)
-}
\ No newline at end of file
+}
+
+// GetWebSocketMetrics returns the WebSocket hub's connection and delivery counters
+func GetWebSocketMetrics(w http.ResponseWriter, r *http.Request) {
+	hub, hubOk := r.Context().Value(utils.HubKey).(*websocket.Hub)
+	if !hubOk || hub == nil {
+		log.Printf("Warning: Hub not found in context for GetWebSocketMetrics. HubOk: %v, HubNil: %v", hubOk, hub == nil)
+		respondWithError(w, http.StatusServiceUnavailable, "WebSocket hub unavailable")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, hub.Metrics())
+}
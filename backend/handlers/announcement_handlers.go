@@ -109,24 +109,19 @@ func CreateAnnouncement(w http.ResponseWriter, r *http.Request) {
 	// Broadcast the new announcement via Hub
 	hub, ok := r.Context().Value(utils.HubKey).(*websocket.Hub) // Use key from utils
 	if ok && hub != nil {
-		// Create payload including top-level collegeId for easier targeting in hub
-		broadcastPayload := map[string]interface{}{
-			"id":         responsePayload.ID,
-			"title":      responsePayload.Title,
-			"content":    responsePayload.Content,
-			"priority":   responsePayload.Priority,
-			"department": responsePayload.Department, // Can be nil
-			"semester":   responsePayload.Semester,   // Can be nil
-			"authorName": responsePayload.AuthorName,
-			"createdAt":  responsePayload.CreatedAt,
-			"updatedAt":  responsePayload.UpdatedAt,
-			"collegeId":  announcement.CollegeID, // Add required collegeId
-		}
-		wsMsg := &websocket.WSMessage{
-			Type:    "newAnnouncement",
-			Payload: broadcastPayload,
-		}
-		hub.BroadcastJSON(wsMsg)
+		// The event carries collegeId too, which targets it
+		hub.Publish(websocket.NewAnnouncementEvent{
+			ID:         responsePayload.ID,
+			Title:      responsePayload.Title,
+			Content:    responsePayload.Content,
+			Priority:   responsePayload.Priority,
+			Department: responsePayload.Department, // Can be nil
+			Semester:   responsePayload.Semester,   // Can be nil
+			AuthorName: responsePayload.AuthorName,
+			CreatedAt:  responsePayload.CreatedAt,
+			UpdatedAt:  responsePayload.UpdatedAt,
+			CollegeID:  announcement.CollegeID,
+		})
 		log.Printf("DEBUG: Successfully retrieved Hub and broadcasting announcement %d", responsePayload.ID)
 	} else {
 		log.Printf("Warning: Hub not found in context for CreateAnnouncement. Ok: %v, HubNil: %v", ok, hub == nil)
@@ -0,0 +1,318 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"unilink-backend/db"
+	"unilink-backend/models"
+	"unilink-backend/storage"
+	"unilink-backend/utils"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+// defaultMaxAttachmentSize is used when MAX_ATTACHMENT_SIZE (bytes) is not set
+const defaultMaxAttachmentSize = 10 << 20
+
+// maxMessageAttachments caps how many files one message can carry
+const maxMessageAttachments = 10
+
+// allowedAttachmentTypes maps accepted (sniffed) content types to file extensions.
+// Office documents sniff as application/zip.
+var allowedAttachmentTypes = map[string]string{
+	"image/jpeg":      ".jpg",
+	"image/png":       ".png",
+	"image/gif":       ".gif",
+	"application/pdf": ".pdf",
+	"text/plain":      ".txt",
+	"application/zip": ".zip",
+}
+
+func maxAttachmentSize() int64 {
+	if size, err := strconv.ParseInt(os.Getenv("MAX_ATTACHMENT_SIZE"), 10, 64); err == nil && size > 0 {
+		return size
+	}
+	return defaultMaxAttachmentSize
+}
+
+// isImageType reports whether an attachment gets a thumbnail and is shown inline
+func isImageType(contentType string) bool {
+	return strings.HasPrefix(contentType, "image/")
+}
+
+func toAttachmentInfo(attachment models.MessageAttachment) AttachmentInfo {
+	info := AttachmentInfo{
+		ID:          attachment.ID,
+		FileName:    attachment.FileName,
+		ContentType: attachment.ContentType,
+		Size:        attachment.Size,
+		Width:       attachment.Width,
+		Height:      attachment.Height,
+		URL:         fmt.Sprintf("/api/attachments/%d", attachment.ID),
+	}
+	if attachment.ThumbnailKey != "" {
+		info.ThumbnailURL = fmt.Sprintf("/api/attachments/%d/thumbnail", attachment.ID)
+	}
+	return info
+}
+
+// toAttachmentInfos converts preloaded message attachments for responses
+func toAttachmentInfos(attachments []models.MessageAttachment) []AttachmentInfo {
+	if len(attachments) == 0 {
+		return nil
+	}
+	result := make([]AttachmentInfo, 0, len(attachments))
+	for _, attachment := range attachments {
+		result = append(result, toAttachmentInfo(attachment))
+	}
+	return result
+}
+
+// orderAttachments is passed to Preload("Attachments", ...) so files keep upload order
+func orderAttachments(tx *gorm.DB) *gorm.DB {
+	return tx.Order("id ASC")
+}
+
+// UploadAttachment stores a file (multipart field "file") for a conversation the user
+// belongs to. The returned ID is then sent in SendMessageRequest.AttachmentIDs.
+func UploadAttachment(w http.ResponseWriter, r *http.Request) {
+	claims, ok := utils.GetUserClaims(r)
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "User claims not found")
+		return
+	}
+
+	conversationID := mux.Vars(r)["conversationId"]
+	if !userHasAccessToConversation(claims.UserID, conversationID) {
+		respondWithError(w, http.StatusForbidden, "Access denied to this conversation")
+		return
+	}
+
+	limit := maxAttachmentSize()
+	r.Body = http.MaxBytesReader(w, r.Body, limit+1024) // Leave room for the multipart framing
+	if err := r.ParseMultipartForm(limit); err != nil {
+		respondWithError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("File is missing or larger than %d bytes", limit))
+		return
+	}
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "File is required (form field 'file')")
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Failed to read file")
+		return
+	}
+	if int64(len(data)) > limit {
+		respondWithError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("File is larger than %d bytes", limit))
+		return
+	}
+
+	// Trust the bytes, not the client's Content-Type header
+	contentType, _, _ := mime.ParseMediaType(http.DetectContentType(data))
+	ext, allowed := allowedAttachmentTypes[contentType]
+	if !allowed {
+		respondWithError(w, http.StatusUnsupportedMediaType, "Only images, PDFs, text files and documents are allowed")
+		return
+	}
+
+	fileName := strings.TrimSpace(filepath.Base(header.Filename))
+	if fileName == "" || fileName == "." || fileName == string(filepath.Separator) {
+		fileName = "attachment" + ext
+	}
+
+	name, err := randomName()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to store file")
+		return
+	}
+
+	attachment := models.MessageAttachment{
+		ConversationID: conversationID,
+		UploaderID:     claims.UserID,
+		CollegeID:      claims.CollegeID,
+		Key:            fmt.Sprintf("college_%d/chat/%s%s", claims.CollegeID, name, ext),
+		FileName:       fileName,
+		ContentType:    contentType,
+		Size:           int64(len(data)),
+	}
+
+	var thumb []byte
+	if isImageType(contentType) {
+		img, err := storage.DecodeImage(data)
+		if errors.Is(err, storage.ErrImageTooLarge) {
+			respondWithError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("Image is larger than %d pixels", storage.MaxImagePixels))
+			return
+		}
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "File is not a valid image")
+			return
+		}
+		if thumb, err = storage.Thumbnail(img); err != nil {
+			log.Printf("Error generating thumbnail: %v", err)
+			respondWithError(w, http.StatusInternalServerError, "Failed to process image")
+			return
+		}
+		bounds := img.Bounds()
+		attachment.Width, attachment.Height = bounds.Dx(), bounds.Dy()
+		attachment.ThumbnailKey = fmt.Sprintf("college_%d/chat/%s_thumb.jpg", claims.CollegeID, name)
+	}
+
+	if err := storage.Files.Save(attachment.Key, bytes.NewReader(data)); err != nil {
+		log.Printf("Error saving attachment %s: %v", attachment.Key, err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to store file")
+		return
+	}
+	if thumb != nil {
+		if err := storage.Files.Save(attachment.ThumbnailKey, bytes.NewReader(thumb)); err != nil {
+			log.Printf("Error saving thumbnail %s: %v", attachment.ThumbnailKey, err)
+			storage.Files.Delete(attachment.Key)
+			respondWithError(w, http.StatusInternalServerError, "Failed to store file")
+			return
+		}
+	}
+	if err := db.DB.Create(&attachment).Error; err != nil {
+		log.Printf("Error recording attachment %s: %v", attachment.Key, err)
+		deleteAttachmentFiles([]models.MessageAttachment{attachment})
+		respondWithError(w, http.StatusInternalServerError, "Failed to store file")
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, toAttachmentInfo(attachment))
+}
+
+// ServeAttachment streams a chat attachment to participants of its conversation
+func ServeAttachment(w http.ResponseWriter, r *http.Request) {
+	serveAttachment(w, r, false)
+}
+
+// ServeAttachmentThumbnail streams an image attachment's thumbnail
+func ServeAttachmentThumbnail(w http.ResponseWriter, r *http.Request) {
+	serveAttachment(w, r, true)
+}
+
+func serveAttachment(w http.ResponseWriter, r *http.Request, thumbnail bool) {
+	claims, ok := utils.GetUserClaims(r)
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "User claims not found")
+		return
+	}
+
+	vars := mux.Vars(r)
+	attachmentID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid attachment ID")
+		return
+	}
+
+	var attachment models.MessageAttachment
+	if err := db.DB.Where("id = ? AND college_id = ?", attachmentID, claims.CollegeID).First(&attachment).Error; err != nil {
+		respondWithError(w, http.StatusNotFound, "File not found")
+		return
+	}
+
+	// Until it is sent only the uploader may see it; afterwards, current participants
+	if attachment.MessageID == nil {
+		if attachment.UploaderID != claims.UserID {
+			respondWithError(w, http.StatusNotFound, "File not found")
+			return
+		}
+	} else if !userHasAccessToConversation(claims.UserID, attachment.ConversationID) {
+		respondWithError(w, http.StatusForbidden, "Access denied to this conversation")
+		return
+	}
+
+	key, contentType := attachment.Key, attachment.ContentType
+	if thumbnail {
+		if attachment.ThumbnailKey == "" {
+			respondWithError(w, http.StatusNotFound, "Attachment has no thumbnail")
+			return
+		}
+		key, contentType = attachment.ThumbnailKey, "image/jpeg"
+	}
+
+	file, err := storage.Files.Open(key)
+	if err != nil {
+		if !errors.Is(err, storage.ErrNotFound) {
+			log.Printf("Error opening attachment %d (%s): %v", attachment.ID, key, err)
+		}
+		respondWithError(w, http.StatusNotFound, "File not found")
+		return
+	}
+	defer file.Close()
+
+	disposition := "attachment"
+	if isImageType(contentType) {
+		disposition = "inline"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": attachment.FileName}))
+	w.Header().Set("Cache-Control", "private, max-age=86400") // Keys never change, but access is per-user
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+
+	// Local files support range requests; other backends are streamed as-is
+	if seeker, ok := file.(io.ReadSeeker); ok {
+		http.ServeContent(w, r, "", attachment.CreatedAt, seeker)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	io.Copy(w, file)
+}
+
+// claimAttachments links the user's unsent uploads for a conversation to a new message.
+// It returns the message type they imply ("image" if all are images, otherwise "file").
+func claimAttachments(tx *gorm.DB, attachmentIDs []uint, userID uint, conversationID string, messageID uint) (string, error) {
+	result := tx.Model(&models.MessageAttachment{}).
+		Where("id IN ? AND uploader_id = ? AND conversation_id = ? AND message_id IS NULL", attachmentIDs, userID, conversationID).
+		Update("message_id", messageID)
+	if result.Error != nil {
+		return "", result.Error
+	}
+	if int(result.RowsAffected) != len(attachmentIDs) {
+		return "", errAttachmentsUnavailable
+	}
+
+	var fileCount int64
+	if err := tx.Model(&models.MessageAttachment{}).
+		Where("message_id = ? AND content_type NOT LIKE ?", messageID, "image/%").
+		Count(&fileCount).Error; err != nil {
+		return "", err
+	}
+	if fileCount > 0 {
+		return "file", nil
+	}
+	return "image", nil
+}
+
+// errAttachmentsUnavailable means an attachment ID was unknown, already sent, or uploaded elsewhere
+var errAttachmentsUnavailable = errors.New("One or more attachments were not found or were already sent")
+
+// deleteAttachmentFiles removes stored files; failures are only logged
+func deleteAttachmentFiles(attachments []models.MessageAttachment) {
+	for _, attachment := range attachments {
+		keys := []string{attachment.Key}
+		if attachment.ThumbnailKey != "" {
+			keys = append(keys, attachment.ThumbnailKey)
+		}
+		for _, key := range keys {
+			if err := storage.Files.Delete(key); err != nil && !errors.Is(err, storage.ErrNotFound) {
+				log.Printf("Warning: Failed to delete attachment file %s: %v", key, err)
+			}
+		}
+	}
+}
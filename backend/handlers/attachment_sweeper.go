@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"log"
+	"os"
+	"time"
+
+	"unilink-backend/db"
+	"unilink-backend/models"
+)
+
+// Defaults when ATTACHMENT_SWEEP_INTERVAL / UNCLAIMED_ATTACHMENT_MAX_AGE are not set
+const (
+	defaultAttachmentSweepInterval = 10 * time.Minute
+	defaultUnclaimedAttachmentAge  = 24 * time.Hour
+)
+
+// StartAttachmentSweeper periodically deletes attachments that were uploaded but never
+// sent with a message, along with their files. Run it in a goroutine.
+func StartAttachmentSweeper() {
+	interval := defaultAttachmentSweepInterval
+	if d, err := time.ParseDuration(os.Getenv("ATTACHMENT_SWEEP_INTERVAL")); err == nil && d > 0 {
+		interval = d
+	}
+	maxAge := defaultUnclaimedAttachmentAge
+	if d, err := time.ParseDuration(os.Getenv("UNCLAIMED_ATTACHMENT_MAX_AGE")); err == nil && d > 0 {
+		maxAge = d
+	}
+
+	log.Printf("🧹 Attachment sweeper started (every %v, unclaimed for %v)", interval, maxAge)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		sweepUnclaimedAttachments(maxAge)
+	}
+}
+
+// sweepUnclaimedAttachments removes attachments with no message that are older than maxAge
+func sweepUnclaimedAttachments(maxAge time.Duration) {
+	cutoff := time.Now().Add(-maxAge)
+
+	var stale []models.MessageAttachment
+	result := db.DB.Where("message_id IS NULL AND created_at < ?", cutoff).Find(&stale)
+	if result.Error != nil {
+		log.Printf("Error finding unclaimed attachments: %v", result.Error)
+		return
+	}
+
+	removed := make([]models.MessageAttachment, 0, len(stale))
+	for _, attachment := range stale {
+		// Conditional delete: skip attachments a message claimed meanwhile
+		del := db.DB.Where("id = ? AND message_id IS NULL", attachment.ID).Delete(&models.MessageAttachment{})
+		if del.Error != nil {
+			log.Printf("Error deleting unclaimed attachment %d: %v", attachment.ID, del.Error)
+			continue
+		}
+		if del.RowsAffected == 0 {
+			continue
+		}
+		removed = append(removed, attachment)
+	}
+
+	// Rows go first, so a file is never deleted while a message still points at it
+	deleteAttachmentFiles(removed)
+	if len(removed) > 0 {
+		log.Printf("Deleted %d unclaimed attachments", len(removed))
+	}
+}
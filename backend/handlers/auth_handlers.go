@@ -2,7 +2,9 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
 	"net/http"
 	"strings"
 	"time"
@@ -10,6 +12,7 @@ import (
 	"unilink-backend/db"
 	"unilink-backend/models"
 	"unilink-backend/utils"
+	"unilink-backend/websocket"
 )
 
 // CheckCollegeRequest is the payload for college verification
@@ -38,8 +41,15 @@ type LoginRequest struct {
 
 // LoginResponse returns JWT token and user data
 type LoginResponse struct {
-	Token string           `json:"token"`
-	User  UserResponseData `json:"user"`
+	Token        string           `json:"token"`        // Short-lived access token
+	RefreshToken string           `json:"refreshToken"` // Exchange at /api/auth/refresh for a new pair
+	ExpiresIn    int              `json:"expiresIn"`    // Access token lifetime in seconds
+	User         UserResponseData `json:"user"`
+}
+
+// RefreshRequest is the payload for refreshing or logging out a session
+type RefreshRequest struct {
+	RefreshToken string `json:"refreshToken"`
 }
 
 // UserResponseData contains safe user data for frontend
@@ -115,14 +125,7 @@ func RegisterStudent(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Step 1: Verify student ID with mock college database
-	studentInfo, err := db.VerifyStudentID(req.CollegeCode, req.StudentID)
-	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Invalid student ID for this college")
-		return
-	}
-
-	// Step 2: Find the college's internal ID
+	// Step 1: Find the college and its configured verification backend
 	var college models.College
 	result := db.DB.Where("college_code = ?", req.CollegeCode).First(&college)
 	if result.Error != nil {
@@ -130,6 +133,21 @@ func RegisterStudent(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	verifier, err := db.NewStudentVerifier(&college)
+	if err != nil {
+		log.Printf("Error building student verifier for college %s: %v", college.CollegeCode, err)
+		respondWithError(w, http.StatusServiceUnavailable, "Student verification is not available for this college")
+		return
+	}
+
+	// Step 2: Verify student ID with the college's source of truth
+	studentInfo, err := verifier.Verify(req.StudentID)
+	if err != nil {
+		log.Printf("Student verification failed (College: %s, StudentID: %s): %v", college.CollegeCode, req.StudentID, err)
+		respondWithError(w, http.StatusUnauthorized, "Invalid student ID for this college")
+		return
+	}
+
 	// Step 3: Check if student already registered
 	var existingUser models.User
 	db.DB.Where("student_id = ?", req.StudentID).First(&existingUser)
@@ -153,8 +171,8 @@ func RegisterStudent(w http.ResponseWriter, r *http.Request) {
 		Role:         "student", // Default role
 		StudentID:    req.StudentID,
 		CollegeID:    college.ID,             // Link to college
-		Department:   studentInfo.Department, // NEW: From mock DB
-		Semester:     studentInfo.Semester,   // NEW: From mock DB
+		Department:   studentInfo.Department, // From the college's verifier
+		Semester:     studentInfo.Semester,   // From the college's verifier
 	}
 
 	result = db.DB.Create(&newUser)
@@ -200,8 +218,14 @@ func Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Step 3: Generate JWT token
-	token, err := utils.GenerateJWT(&user)
+	// Suspended accounts can't obtain new tokens
+	if err := utils.EnsureUserActive(&user); err != nil {
+		respondWithSuspension(w, &user, err)
+		return
+	}
+
+	// Step 3: Start a session and generate the token pair
+	token, refreshToken, err := utils.CreateSession(&user, r)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Failed to generate token")
 		return
@@ -226,13 +250,115 @@ func Login(w http.ResponseWriter, r *http.Request) {
 	}
 
 	response := LoginResponse{
-		Token: token,
-		User:  userData,
+		Token:        token,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int(utils.AccessTokenTTL().Seconds()),
+		User:         userData,
 	}
 
 	respondWithJSON(w, http.StatusOK, response)
 }
 
+// RefreshToken exchanges a refresh token for a new access/refresh pair (rotation)
+func RefreshToken(w http.ResponseWriter, r *http.Request) {
+	var req RefreshRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.RefreshToken == "" {
+		respondWithError(w, http.StatusBadRequest, "Refresh token is required")
+		return
+	}
+
+	token, refreshToken, user, err := utils.RotateSession(req.RefreshToken)
+	if err != nil {
+		if errors.Is(err, utils.ErrUserSuspended) {
+			respondWithSuspension(w, user, err)
+			return
+		}
+		if errors.Is(err, utils.ErrRefreshTokenReused) {
+			log.Printf("Warning: Refresh token reuse detected, session revoked")
+		}
+		respondWithError(w, http.StatusUnauthorized, "Invalid or expired refresh token")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"token":        token,
+		"refreshToken": refreshToken,
+		"expiresIn":    int(utils.AccessTokenTTL().Seconds()),
+		"userId":       user.ID,
+	})
+}
+
+// Logout revokes the session the current access token belongs to
+func Logout(w http.ResponseWriter, r *http.Request) {
+	claims, ok := utils.GetUserClaims(r)
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "User claims not found")
+		return
+	}
+
+	if err := utils.RevokeSession(claims.UserID, claims.SessionID); err != nil {
+		log.Printf("Error revoking session %d for user %d: %v", claims.SessionID, claims.UserID, err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to log out")
+		return
+	}
+
+	// Close any WebSocket opened with this session
+	if hub, ok := r.Context().Value(utils.HubKey).(*websocket.Hub); ok && hub != nil {
+		hub.Disconnect(claims.UserID, claims.SessionID)
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]string{
+		"message": "Logged out successfully",
+	})
+}
+
+// LogoutAll revokes every session for the current user ("log out all devices")
+func LogoutAll(w http.ResponseWriter, r *http.Request) {
+	claims, ok := utils.GetUserClaims(r)
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "User claims not found")
+		return
+	}
+
+	revoked, err := utils.RevokeAllSessions(claims.UserID)
+	if err != nil {
+		log.Printf("Error revoking sessions for user %d: %v", claims.UserID, err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to log out of all devices")
+		return
+	}
+
+	if hub, ok := r.Context().Value(utils.HubKey).(*websocket.Hub); ok && hub != nil {
+		hub.Disconnect(claims.UserID, 0) // 0 = every session
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"message":         "Logged out of all devices",
+		"revokedSessions": revoked,
+	})
+}
+
+// CreateWebSocketTicket issues a short-lived, one-time ticket for opening /ws?ticket=...
+// under the current session, so the access token never goes in the URL
+func CreateWebSocketTicket(w http.ResponseWriter, r *http.Request) {
+	claims, ok := utils.GetUserClaims(r)
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "User claims not found")
+		return
+	}
+
+	ticket, err := utils.IssueWebSocketTicket(claims)
+	if err != nil {
+		log.Printf("Error issuing WebSocket ticket for user %d: %v", claims.UserID, err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to create WebSocket ticket")
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, map[string]interface{}{
+		"ticket":    ticket,
+		"expiresIn": int(utils.WebSocketTicketTTL().Seconds()), // Seconds to open the connection
+	})
+}
+
 // Helper function to send JSON responses
 func respondWithJSON(w http.ResponseWriter, code int, payload interface{}) {
 	response, _ := json.Marshal(payload)
@@ -246,6 +372,23 @@ func respondWithError(w http.ResponseWriter, code int, message string) {
 	respondWithJSON(w, code, map[string]string{"error": message})
 }
 
+// respondWithSuspension reports why a user can't sign in
+func respondWithSuspension(w http.ResponseWriter, user *models.User, err error) {
+	if !errors.Is(err, utils.ErrUserSuspended) {
+		respondWithError(w, http.StatusInternalServerError, "Failed to check account status")
+		return
+	}
+
+	message := "Your account has been suspended"
+	if user.SuspensionReason != "" {
+		message += ": " + user.SuspensionReason
+	}
+	if user.SuspendedUntil != nil {
+		message += fmt.Sprintf(" (until %s)", user.SuspendedUntil.Format("2006-01-02 15:04"))
+	}
+	respondWithError(w, http.StatusForbidden, message)
+}
+
 // autoJoinGroups automatically adds a new student to their department and semester groups
 func autoJoinGroups(user *models.User) {
 	// Only auto-join for students
@@ -280,6 +423,9 @@ func autoJoinGroups(user *models.User) {
 		Role:     "member",
 		JoinedAt: time.Now(),
 	})
+	if err := addConversationMembers(db.DB, groupConversationID(deptGroup.ID), user.ID); err != nil {
+		log.Printf("Error adding group conversation %d for user %d: %v", deptGroup.ID, user.ID, err)
+	}
 
 	// 2. Find or create department + semester group (e.g., "CSE - Semester 4")
 	semGroupName := fmt.Sprintf("%s - Semester %d", user.Department, user.Semester)
@@ -308,4 +454,7 @@ func autoJoinGroups(user *models.User) {
 		Role:     "member",
 		JoinedAt: time.Now(),
 	})
+	if err := addConversationMembers(db.DB, groupConversationID(semGroup.ID), user.ID); err != nil {
+		log.Printf("Error adding group conversation %d for user %d: %v", semGroup.ID, user.ID, err)
+	}
 }
@@ -0,0 +1,334 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"unilink-backend/db"
+	"unilink-backend/models"
+	"unilink-backend/utils"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+// CategoryRequest is the payload for creating or updating a listing category
+type CategoryRequest struct {
+	Name      string `json:"name"`
+	SortOrder *int   `json:"sortOrder"`
+}
+
+// validConditions lists the accepted values for MarketplaceListing.Condition
+var validConditions = map[string]bool{
+	"new": true, "like_new": true, "good": true, "fair": true, "poor": true,
+}
+
+var slugInvalidChars = regexp.MustCompile(`[^a-z0-9]+`)
+
+// slugify turns "Lab Equipment" into "lab-equipment"
+func slugify(name string) string {
+	return strings.Trim(slugInvalidChars.ReplaceAllString(strings.ToLower(name), "-"), "-")
+}
+
+// GetCategories returns the marketplace categories for the user's college
+func GetCategories(w http.ResponseWriter, r *http.Request) {
+	claims, ok := utils.GetUserClaims(r)
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "User claims not found")
+		return
+	}
+
+	listCategories(w, claims.CollegeID)
+}
+
+// ============================================
+// COLLEGE ADMIN FUNCTIONS (Own college taxonomy)
+// ============================================
+
+// CreateCollegeCategory adds a category to the admin's college
+func CreateCollegeCategory(w http.ResponseWriter, r *http.Request) {
+	claims, ok := utils.GetUserClaims(r)
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "User claims not found")
+		return
+	}
+
+	createCategory(w, r, claims.CollegeID)
+}
+
+// UpdateCollegeCategory renames or reorders a category in the admin's college
+func UpdateCollegeCategory(w http.ResponseWriter, r *http.Request) {
+	claims, ok := utils.GetUserClaims(r)
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "User claims not found")
+		return
+	}
+
+	collegeID := claims.CollegeID
+	updateCategory(w, r, &collegeID)
+}
+
+// DeleteCollegeCategory removes a category from the admin's college
+func DeleteCollegeCategory(w http.ResponseWriter, r *http.Request) {
+	claims, ok := utils.GetUserClaims(r)
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "User claims not found")
+		return
+	}
+
+	collegeID := claims.CollegeID
+	deleteCategory(w, r, &collegeID)
+}
+
+// ============================================
+// PLATFORM ADMIN FUNCTIONS (Any college)
+// ============================================
+
+// GetCollegeCategoriesPlatform lists a college's categories (platform admin only)
+func GetCollegeCategoriesPlatform(w http.ResponseWriter, r *http.Request) {
+	collegeID, ok := collegeIDFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	listCategories(w, collegeID)
+}
+
+// CreateCollegeCategoryPlatform adds a category to any college (platform admin only)
+func CreateCollegeCategoryPlatform(w http.ResponseWriter, r *http.Request) {
+	collegeID, ok := collegeIDFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	createCategory(w, r, collegeID)
+}
+
+// UpdateCategoryPlatform updates any category (platform admin only)
+func UpdateCategoryPlatform(w http.ResponseWriter, r *http.Request) {
+	updateCategory(w, r, nil)
+}
+
+// DeleteCategoryPlatform deletes any category (platform admin only)
+func DeleteCategoryPlatform(w http.ResponseWriter, r *http.Request) {
+	deleteCategory(w, r, nil)
+}
+
+// ============================================
+// SHARED HELPERS
+// ============================================
+
+// collegeIDFromPath reads the {id} college route variable and checks the college exists
+func collegeIDFromPath(w http.ResponseWriter, r *http.Request) (uint, bool) {
+	vars := mux.Vars(r)
+	collegeID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid college ID")
+		return 0, false
+	}
+
+	var college models.College
+	if err := db.DB.First(&college, collegeID).Error; err != nil {
+		respondWithError(w, http.StatusNotFound, "College not found")
+		return 0, false
+	}
+	return college.ID, true
+}
+
+func listCategories(w http.ResponseWriter, collegeID uint) {
+	var categories []models.ListingCategory
+	result := db.DB.Where("college_id = ?", collegeID).
+		Order("sort_order ASC, name ASC").
+		Find(&categories)
+
+	if result.Error != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to fetch categories")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"total":      len(categories),
+		"categories": categories,
+	})
+}
+
+func createCategory(w http.ResponseWriter, r *http.Request, collegeID uint) {
+	var req CategoryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	slug := slugify(req.Name)
+	if req.Name == "" || slug == "" {
+		respondWithError(w, http.StatusBadRequest, "Category name is required")
+		return
+	}
+
+	// Check if category already exists in this college
+	var existing models.ListingCategory
+	db.DB.Where("college_id = ? AND slug = ?", collegeID, slug).First(&existing)
+	if existing.ID != 0 {
+		respondWithError(w, http.StatusConflict, "Category already exists")
+		return
+	}
+
+	category := models.ListingCategory{
+		CollegeID: collegeID,
+		Name:      req.Name,
+		Slug:      slug,
+	}
+	if req.SortOrder != nil {
+		category.SortOrder = *req.SortOrder
+	}
+
+	if err := db.DB.Create(&category).Error; err != nil {
+		log.Printf("Error creating category for college %d: %v", collegeID, err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to create category")
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, map[string]interface{}{
+		"message":  "Category created successfully",
+		"category": category,
+	})
+}
+
+// findCategory loads the {id} category, restricted to collegeID when given
+func findCategory(w http.ResponseWriter, r *http.Request, collegeID *uint) (*models.ListingCategory, bool) {
+	vars := mux.Vars(r)
+	categoryID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid category ID")
+		return nil, false
+	}
+
+	query := db.DB.Where("id = ?", categoryID)
+	if collegeID != nil {
+		query = query.Where("college_id = ?", *collegeID)
+	}
+
+	var category models.ListingCategory
+	if err := query.First(&category).Error; err != nil {
+		respondWithError(w, http.StatusNotFound, "Category not found")
+		return nil, false
+	}
+	return &category, true
+}
+
+func updateCategory(w http.ResponseWriter, r *http.Request, collegeID *uint) {
+	category, ok := findCategory(w, r, collegeID)
+	if !ok {
+		return
+	}
+
+	var req CategoryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	if name := strings.TrimSpace(req.Name); name != "" {
+		slug := slugify(name)
+		if slug == "" {
+			respondWithError(w, http.StatusBadRequest, "Invalid category name")
+			return
+		}
+
+		var existing models.ListingCategory
+		db.DB.Where("college_id = ? AND slug = ? AND id != ?", category.CollegeID, slug, category.ID).First(&existing)
+		if existing.ID != 0 {
+			respondWithError(w, http.StatusConflict, "Category already exists")
+			return
+		}
+
+		category.Name = name
+		category.Slug = slug
+	}
+	if req.SortOrder != nil {
+		category.SortOrder = *req.SortOrder
+	}
+
+	if err := db.DB.Save(category).Error; err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to update category")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"message":  "Category updated successfully",
+		"category": category,
+	})
+}
+
+func deleteCategory(w http.ResponseWriter, r *http.Request, collegeID *uint) {
+	category, ok := findCategory(w, r, collegeID)
+	if !ok {
+		return
+	}
+
+	// Listings in this category become uncategorized rather than disappearing
+	tx := db.DB.Begin()
+	if err := tx.Model(&models.MarketplaceListing{}).
+		Where("category_id = ?", category.ID).
+		Update("category_id", nil).Error; err != nil {
+		tx.Rollback()
+		respondWithError(w, http.StatusInternalServerError, "Failed to delete category")
+		return
+	}
+	if err := tx.Delete(category).Error; err != nil {
+		tx.Rollback()
+		respondWithError(w, http.StatusInternalServerError, "Failed to delete category")
+		return
+	}
+	if err := tx.Commit().Error; err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to delete category")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]string{
+		"message": "Category deleted successfully",
+	})
+}
+
+// applyCategoryFilters narrows a listing query by the categoryId and condition
+// query parameters. It returns an error message for invalid values.
+func applyCategoryFilters(query *gorm.DB, params url.Values) (*gorm.DB, string) {
+	if v := params.Get("categoryId"); v != "" {
+		if v == "none" {
+			query = query.Where("category_id IS NULL")
+		} else {
+			categoryID, err := strconv.Atoi(v)
+			if err != nil || categoryID <= 0 {
+				return nil, "Invalid categoryId"
+			}
+			query = query.Where("category_id = ?", categoryID)
+		}
+	}
+
+	if v := params.Get("condition"); v != "" {
+		if !validConditions[v] {
+			return nil, "Condition must be 'new', 'like_new', 'good', 'fair', or 'poor'"
+		}
+		query = query.Where("condition = ?", v)
+	}
+
+	return query, ""
+}
+
+// toCategoryInfo converts a preloaded category for listing responses
+func toCategoryInfo(category *models.ListingCategory) *CategoryInfo {
+	if category == nil {
+		return nil
+	}
+	return &CategoryInfo{
+		ID:   category.ID,
+		Name: category.Name,
+		Slug: category.Slug,
+	}
+}
@@ -0,0 +1,216 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"unilink-backend/db"
+	"unilink-backend/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// unreadCountExpr recounts a conversation_members row from messages and read state
+// (the same rule as countUnread)
+const unreadCountExpr = `(SELECT COUNT(*) FROM messages m
+	WHERE m.conversation_id = conversation_members.conversation_id
+	AND m.sender_id != conversation_members.user_id
+	AND m.is_deleted = false AND m.deleted_at IS NULL
+	AND m.id > COALESCE((SELECT cr.last_read_message_id FROM conversation_reads cr
+		WHERE cr.conversation_id = conversation_members.conversation_id
+		AND cr.user_id = conversation_members.user_id), 0))`
+
+// recordConversationMessage updates the conversation summary and members' unread
+// counters for a newly sent message
+func recordConversationMessage(tx *gorm.DB, message models.Message) error {
+	conversation := models.Conversation{
+		ID:             message.ConversationID,
+		Type:           message.ConversationType,
+		LastMessageID:  &message.ID,
+		LastActivityAt: &message.CreatedAt,
+	}
+	if err := tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"last_message_id", "last_activity_at", "updated_at"}),
+	}).Create(&conversation).Error; err != nil {
+		return err
+	}
+
+	if err := tx.Model(&models.ConversationMember{}).
+		Where("conversation_id = ? AND user_id != ?", message.ConversationID, message.SenderID).
+		Updates(map[string]interface{}{
+			"unread_count":     gorm.Expr("unread_count + 1"),
+			"last_activity_at": message.CreatedAt,
+		}).Error; err != nil {
+		return err
+	}
+
+	// The sender has read everything up to their own message
+	return tx.Model(&models.ConversationMember{}).
+		Where("conversation_id = ? AND user_id = ?", message.ConversationID, message.SenderID).
+		Updates(map[string]interface{}{
+			"unread_count":     0,
+			"last_activity_at": message.CreatedAt,
+		}).Error
+}
+
+// recountUnread recomputes unread counters for a conversation, for one member when
+// userID is given. Used when read state moves or messages are deleted.
+func recountUnread(tx *gorm.DB, conversationID string, userID *uint) error {
+	query := tx.Model(&models.ConversationMember{}).Where("conversation_id = ?", conversationID)
+	if userID != nil {
+		query = query.Where("user_id = ?", *userID)
+	}
+	return query.Update("unread_count", gorm.Expr(unreadCountExpr)).Error
+}
+
+// addConversationMembers gives users who just gained access to a conversation (an
+// accepted friendship, a joined group) their conversation_members row, with exact counts
+func addConversationMembers(tx *gorm.DB, conversationID string, userIDs ...uint) error {
+	if err := ensureConversations(tx, []string{conversationID}); err != nil {
+		return err
+	}
+
+	members := make([]models.ConversationMember, 0, len(userIDs))
+	for _, userID := range userIDs {
+		members = append(members, models.ConversationMember{ConversationID: conversationID, UserID: userID})
+	}
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&members).Error; err != nil {
+		return err
+	}
+	return tx.Model(&models.ConversationMember{}).
+		Where("conversation_id = ? AND user_id IN ?", conversationID, userIDs).
+		Updates(map[string]interface{}{
+			"unread_count":     gorm.Expr(unreadCountExpr),
+			"last_activity_at": gorm.Expr("(SELECT c.last_activity_at FROM conversations c WHERE c.id = conversation_members.conversation_id)"),
+		}).Error
+}
+
+// removeConversationMembers takes a conversation off the users' lists when they lose
+// access to it, or off everyone's list when no users are given (a deleted group)
+func removeConversationMembers(tx *gorm.DB, conversationID string, userIDs ...uint) error {
+	query := tx.Where("conversation_id = ?", conversationID)
+	if len(userIDs) > 0 {
+		query = query.Where("user_id IN ?", userIDs)
+	}
+	return query.Delete(&models.ConversationMember{}).Error
+}
+
+// refreshLastMessage points the conversation summary at its newest message that is
+// still there, after the previous last message was deleted. Activity time is kept.
+func refreshLastMessage(tx *gorm.DB, conversationID string) error {
+	var lastMessageID *uint
+	var latest []uint
+	if err := tx.Model(&models.Message{}).
+		Where("conversation_id = ? AND is_deleted = ?", conversationID, false).
+		Order("id DESC").
+		Limit(1).
+		Pluck("id", &latest).Error; err != nil {
+		return err
+	}
+	if len(latest) > 0 {
+		lastMessageID = &latest[0]
+	}
+	return tx.Model(&models.Conversation{}).Where("id = ?", conversationID).
+		Update("last_message_id", lastMessageID).Error
+}
+
+// groupConversationID returns the conversation ID of a group chat
+func groupConversationID(groupID uint) string {
+	return fmt.Sprintf("group_%d", groupID)
+}
+
+// BackfillConversationMembers fills conversation lists from existing friendships and
+// group memberships. Membership handlers keep them in sync afterwards, so it only
+// does anything while conversation_members is still empty.
+func BackfillConversationMembers() {
+	var existing int64
+	if err := db.DB.Model(&models.ConversationMember{}).Limit(1).Count(&existing).Error; err != nil || existing > 0 {
+		return
+	}
+
+	tx := db.DB.Begin()
+	result := tx.Exec(`INSERT INTO conversation_members (conversation_id, user_id, unread_count, created_at)
+		SELECT conversation_id, user_id, 0, NOW() FROM (
+			SELECT 'dm_' || LEAST(user_id, friend_id) || '_' || GREATEST(user_id, friend_id) AS conversation_id, user_id
+				FROM friendships WHERE status = 'accepted'
+			UNION SELECT 'dm_' || LEAST(user_id, friend_id) || '_' || GREATEST(user_id, friend_id), friend_id
+				FROM friendships WHERE status = 'accepted'
+			UNION SELECT 'group_' || group_id, user_id FROM group_members
+		) access
+		ON CONFLICT (conversation_id, user_id) DO NOTHING`)
+	if result.Error != nil {
+		tx.Rollback()
+		log.Printf("Warning: Failed to backfill conversation lists: %v", result.Error)
+		return
+	}
+	if result.RowsAffected == 0 {
+		tx.Rollback()
+		return
+	}
+
+	var conversationIDs []string
+	if err := tx.Model(&models.ConversationMember{}).Distinct().Pluck("conversation_id", &conversationIDs).Error; err != nil {
+		tx.Rollback()
+		log.Printf("Warning: Failed to backfill conversation lists: %v", err)
+		return
+	}
+	if err := ensureConversations(tx, conversationIDs); err != nil {
+		tx.Rollback()
+		log.Printf("Warning: Failed to backfill conversation lists: %v", err)
+		return
+	}
+	if err := tx.Model(&models.ConversationMember{}).Where("1 = 1").
+		Updates(map[string]interface{}{
+			"unread_count":     gorm.Expr(unreadCountExpr),
+			"last_activity_at": gorm.Expr("(SELECT c.last_activity_at FROM conversations c WHERE c.id = conversation_members.conversation_id)"),
+		}).Error; err != nil {
+		tx.Rollback()
+		log.Printf("Warning: Failed to backfill conversation lists: %v", err)
+		return
+	}
+	if err := tx.Commit().Error; err != nil {
+		log.Printf("Warning: Failed to backfill conversation lists: %v", err)
+		return
+	}
+
+	log.Printf("✅ Backfilled %d conversation list entries", result.RowsAffected)
+}
+
+// ensureConversations creates summary rows for conversations that don't have one yet,
+// seeded from their existing messages
+func ensureConversations(tx *gorm.DB, conversationIDs []string) error {
+	var latest []struct {
+		ConversationID string
+		LastMessageID  uint
+		LastActivityAt time.Time
+	}
+	if err := tx.Model(&models.Message{}).
+		Select("conversation_id, MAX(id) AS last_message_id, MAX(created_at) AS last_activity_at").
+		Where("conversation_id IN ?", conversationIDs).
+		Group("conversation_id").
+		Scan(&latest).Error; err != nil {
+		return err
+	}
+	byID := make(map[string]int, len(latest))
+	for i, row := range latest {
+		byID[row.ConversationID] = i
+	}
+
+	conversations := make([]models.Conversation, 0, len(conversationIDs))
+	for _, id := range conversationIDs {
+		conversation := models.Conversation{ID: id, Type: "group"}
+		if strings.HasPrefix(id, "dm_") {
+			conversation.Type = "dm"
+		}
+		if i, ok := byID[id]; ok {
+			conversation.LastMessageID = &latest[i].LastMessageID
+			conversation.LastActivityAt = &latest[i].LastActivityAt
+		}
+		conversations = append(conversations, conversation)
+	}
+	return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&conversations).Error
+}
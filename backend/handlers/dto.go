@@ -0,0 +1,20 @@
+package handlers
+
+import "unilink-backend/dto"
+
+// Response types are defined in dto so WebSocket events can carry them typed.
+// Handlers keep referring to them by these names.
+type (
+	MessageResponse   = dto.MessageResponse
+	QuotedMessage     = dto.QuotedMessage
+	MessageSenderData = dto.MessageSenderData
+	ReactionSummary   = dto.ReactionSummary
+	AttachmentInfo    = dto.AttachmentInfo
+	ListingResponse   = dto.ListingResponse
+	SellerInfo        = dto.SellerInfo
+	ListingImageInfo  = dto.ListingImageInfo
+	CategoryInfo      = dto.CategoryInfo
+	OfferResponse     = dto.OfferResponse
+	RatingResponse    = dto.RatingResponse
+	FriendProfileData = dto.FriendProfileData
+)
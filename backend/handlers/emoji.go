@@ -0,0 +1,93 @@
+package handlers
+
+import "unicode/utf8"
+
+// Code points that make up emoji sequences
+const (
+	zeroWidthJoiner    = 0x200D
+	variationSelector  = 0xFE0F // Emoji presentation
+	combiningKeycap    = 0x20E3
+	cancelTag          = 0xE007F
+	regionalIndicatorA = 0x1F1E6
+	regionalIndicatorZ = 0x1F1FF
+	skinToneLight      = 0x1F3FB
+	skinToneDark       = 0x1F3FF
+)
+
+// pictographicRanges approximates Unicode's Extended_Pictographic property: the code
+// points that can start an emoji (regional indicators and skin tones are handled apart)
+var pictographicRanges = [][2]rune{
+	{0x00A9, 0x00A9}, {0x00AE, 0x00AE}, {0x203C, 0x203C}, {0x2049, 0x2049},
+	{0x2122, 0x2122}, {0x2139, 0x2139}, {0x2194, 0x2199}, {0x21A9, 0x21AA},
+	{0x231A, 0x231B}, {0x2328, 0x2328}, {0x23CF, 0x23CF}, {0x23E9, 0x23F3},
+	{0x23F8, 0x23FA}, {0x24C2, 0x24C2}, {0x25AA, 0x25AB}, {0x25B6, 0x25B6},
+	{0x25C0, 0x25C0}, {0x25FB, 0x25FE}, {0x2600, 0x27BF}, {0x2934, 0x2935},
+	{0x2B05, 0x2B07}, {0x2B1B, 0x2B1C}, {0x2B50, 0x2B50}, {0x2B55, 0x2B55},
+	{0x3030, 0x3030}, {0x303D, 0x303D}, {0x3297, 0x3297}, {0x3299, 0x3299},
+	{0x1F000, 0x1F1E5}, {0x1F200, 0x1F3FA}, {0x1F400, 0x1FAFF},
+}
+
+func isPictographic(r rune) bool {
+	for _, span := range pictographicRanges {
+		if r >= span[0] && r <= span[1] {
+			return true
+		}
+	}
+	return false
+}
+
+func isRegionalIndicator(r rune) bool { return r >= regionalIndicatorA && r <= regionalIndicatorZ }
+func isSkinTone(r rune) bool          { return r >= skinToneLight && r <= skinToneDark }
+func isTag(r rune) bool               { return r >= 0xE0020 && r <= cancelTag }
+func isKeycapBase(r rune) bool        { return r == '#' || r == '*' || (r >= '0' && r <= '9') }
+
+// validEmoji accepts exactly one emoji: a flag, a keycap, or pictographs (each with an
+// optional presentation selector, skin tone or subdivision tags) joined by ZWJ
+func validEmoji(emoji string) bool {
+	if emoji == "" || len(emoji) > maxEmojiBytes || !utf8.ValidString(emoji) {
+		return false
+	}
+	runes := []rune(emoji)
+
+	if isRegionalIndicator(runes[0]) {
+		return len(runes) == 2 && isRegionalIndicator(runes[1])
+	}
+	if isKeycapBase(runes[0]) {
+		rest := runes[1:]
+		if len(rest) > 0 && rest[0] == variationSelector {
+			rest = rest[1:]
+		}
+		return len(rest) == 1 && rest[0] == combiningKeycap
+	}
+
+	i := 0
+	for {
+		if i >= len(runes) || !isPictographic(runes[i]) {
+			return false
+		}
+		i++
+		if i < len(runes) && runes[i] == variationSelector {
+			i++
+		}
+		if i < len(runes) && isSkinTone(runes[i]) {
+			i++
+		}
+		if i < len(runes) && isTag(runes[i]) {
+			for i < len(runes) && isTag(runes[i]) && runes[i] != cancelTag {
+				i++
+			}
+			if i >= len(runes) || runes[i] != cancelTag {
+				return false
+			}
+			i++
+		}
+
+		if i == len(runes) {
+			return true
+		}
+		if runes[i] != zeroWidthJoiner {
+			return false
+		}
+		i++
+	}
+}
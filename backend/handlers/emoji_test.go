@@ -0,0 +1,36 @@
+package handlers
+
+import "testing"
+
+func TestValidEmoji(t *testing.T) {
+	tests := []struct {
+		emoji string
+		want  bool
+	}{
+		{"👍", true},
+		{"👍🏽", true},      // Skin tone
+		{"❤️", true},      // Presentation selector
+		{"👩‍💻", true},     // ZWJ sequence
+		{"👨‍👩‍👧‍👦", true}, // Family
+		{"🏳️‍🌈", true},    // Flag ZWJ sequence
+		{"🇮🇳", true},      // Regional indicator pair
+		{"1️⃣", true},     // Keycap
+		{"🏴󠁧󠁢󠁳󠁣󠁴󠁿", true}, // Subdivision flag
+		{"", false},
+		{"a", false},
+		{"ok", false},
+		{"1", false},
+		{"👍👍", false}, // Two emoji
+		{"👍 ", false},
+		{"🇮", false}, // Lone regional indicator
+		{"🇮🇳🇮", false},
+		{"🏽", false},  // Lone skin tone
+		{"👩‍", false}, // Dangling joiner
+		{"<script>", false},
+	}
+	for _, tt := range tests {
+		if got := validEmoji(tt.emoji); got != tt.want {
+			t.Errorf("validEmoji(%q) = %v, want %v", tt.emoji, got, tt.want)
+		}
+	}
+}
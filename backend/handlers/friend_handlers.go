@@ -28,17 +28,6 @@ type FriendshipResponse struct {
 	CreatedAt string            `json:"createdAt"`
 }
 
-// FriendProfileData contains friend's basic info
-type FriendProfileData struct {
-	ID             uint   `json:"id"`
-	Name           string `json:"name"`
-	Email          string `json:"email"` // Consider removing email if not needed on frontend for this
-	StudentID      string `json:"studentId"`
-	ProfilePicture string `json:"profilePicture"`
-	Department     string `json:"department"`
-	Semester       int    `json:"semester"`
-}
-
 // SendFriendRequest allows a student to send friend request to another student
 func SendFriendRequest(w http.ResponseWriter, r *http.Request) {
 	claims, ok := utils.GetUserClaims(r)
@@ -129,13 +118,12 @@ func SendFriendRequest(w http.ResponseWriter, r *http.Request) {
 		var sender models.User
 		db.DB.First(&sender, claims.UserID) // Fetch minimal sender details needed
 
-		// Construct payload for the recipient
-		wsPayload := map[string]interface{}{
-			"id":       friendship.ID, // Friendship record ID
-			"type":     "newFriendRequest",
-			"userId":   friendship.UserID,   // The ID of the person who sent the request
-			"friendId": friendship.FriendID, // The ID of the person receiving the request (TARGET USER)
-			"sender": FriendProfileData{ // Include info about the sender
+		// Notify the recipient
+		hub.Publish(websocket.NewFriendRequestEvent{
+			ID:       friendship.ID,       // Friendship record ID
+			UserID:   friendship.UserID,   // The ID of the person who sent the request
+			FriendID: friendship.FriendID, // The ID of the person receiving the request (TARGET USER)
+			Sender: FriendProfileData{ // Include info about the sender
 				ID:             sender.ID,
 				Name:           sender.Name,
 				StudentID:      sender.StudentID,
@@ -143,14 +131,8 @@ func SendFriendRequest(w http.ResponseWriter, r *http.Request) {
 				Department:     sender.Department,
 				Semester:       sender.Semester,
 			},
-			"createdAt": friendship.CreatedAt.Format("2006-01-02 15:04:05"),
-		}
-
-		wsMsg := &websocket.WSMessage{
-			Type:    "newFriendRequest",
-			Payload: wsPayload,
-		}
-		hub.BroadcastJSON(wsMsg)
+			CreatedAt: friendship.CreatedAt.Format("2006-01-02 15:04:05"),
+		})
 		log.Printf("WS Broadcast: Sent 'newFriendRequest' notification to UserID %d", friendship.FriendID)
 
 	} else {
@@ -237,9 +219,21 @@ func AcceptFriendRequest(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Update status to accepted
+	// Update status to accepted; the DM shows up in both conversation lists
+	tx := db.DB.Begin()
 	friendship.Status = "accepted"
-	if err := db.DB.Save(&friendship).Error; err != nil {
+	if err := tx.Save(&friendship).Error; err != nil {
+		tx.Rollback()
+		respondWithError(w, http.StatusInternalServerError, "Failed to accept request")
+		return
+	}
+	if err := addConversationMembers(tx, dmConversationID(friendship.UserID, friendship.FriendID), friendship.UserID, friendship.FriendID); err != nil {
+		tx.Rollback()
+		log.Printf("Error adding DM conversation for friendship %d: %v", friendship.ID, err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to accept request")
+		return
+	}
+	if err := tx.Commit().Error; err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Failed to accept request")
 		return
 	}
@@ -247,14 +241,13 @@ func AcceptFriendRequest(w http.ResponseWriter, r *http.Request) {
 	// --- Broadcast WebSocket Notification to the original sender ---
 	hub, hubOk := r.Context().Value(utils.HubKey).(*websocket.Hub)
 	if hubOk && hub != nil {
-		// Construct payload for the original sender (friendship.User)
-		wsPayload := map[string]interface{}{
-			"id":       friendship.ID,
-			"type":     "friendRequestUpdate",
-			"userId":   friendship.UserID,   // The ID of the original sender (TARGET USER)
-			"friendId": friendship.FriendID, // The ID of the user who accepted
-			"status":   "accepted",
-			"accepter": FriendProfileData{ // Include info about who accepted
+		// Notify the original sender (friendship.User)
+		hub.Publish(websocket.FriendRequestUpdateEvent{
+			ID:       friendship.ID,
+			UserID:   friendship.UserID,   // The ID of the original sender (TARGET USER)
+			FriendID: friendship.FriendID, // The ID of the user who accepted
+			Status:   "accepted",
+			Accepter: &FriendProfileData{ // Include info about who accepted
 				ID:             friendship.Friend.ID,
 				Name:           friendship.Friend.Name,
 				StudentID:      friendship.Friend.StudentID,
@@ -262,14 +255,8 @@ func AcceptFriendRequest(w http.ResponseWriter, r *http.Request) {
 				Department:     friendship.Friend.Department,
 				Semester:       friendship.Friend.Semester,
 			},
-			"updatedAt": friendship.UpdatedAt.Format("2006-01-02 15:04:05"),
-		}
-
-		wsMsg := &websocket.WSMessage{
-			Type:    "friendRequestUpdate",
-			Payload: wsPayload,
-		}
-		hub.BroadcastJSON(wsMsg)
+			UpdatedAt: friendship.UpdatedAt.Format("2006-01-02 15:04:05"),
+		})
 		log.Printf("WS Broadcast: Sent 'friendRequestUpdate' (accepted) notification to UserID %d", friendship.UserID)
 	} else {
 		log.Printf("Warning: Hub not found in context for AcceptFriendRequest. HubOk: %v, HubNil: %v", hubOk, hub == nil)
@@ -322,22 +309,15 @@ func RejectFriendRequest(w http.ResponseWriter, r *http.Request) {
 	// --- Broadcast WebSocket Notification to the original sender ---
 	hub, hubOk := r.Context().Value(utils.HubKey).(*websocket.Hub)
 	if hubOk && hub != nil {
-		// Construct payload for the original sender (friendship.User)
-		wsPayload := map[string]interface{}{
-			"id":         friendship.ID,
-			"type":       "friendRequestUpdate",
-			"userId":     friendship.UserID,   // The ID of the original sender (TARGET USER)
-			"friendId":   friendship.FriendID, // The ID of the user who rejected
-			"status":     "rejected",
-			"rejecterId": friendship.FriendID, // ID of who rejected
-			"updatedAt":  friendship.UpdatedAt.Format("2006-01-02 15:04:05"),
-		}
-
-		wsMsg := &websocket.WSMessage{
-			Type:    "friendRequestUpdate",
-			Payload: wsPayload,
-		}
-		hub.BroadcastJSON(wsMsg)
+		// Notify the original sender (friendship.User)
+		hub.Publish(websocket.FriendRequestUpdateEvent{
+			ID:         friendship.ID,
+			UserID:     friendship.UserID,   // The ID of the original sender (TARGET USER)
+			FriendID:   friendship.FriendID, // The ID of the user who rejected
+			Status:     "rejected",
+			RejecterID: &friendship.FriendID, // ID of who rejected
+			UpdatedAt:  friendship.UpdatedAt.Format("2006-01-02 15:04:05"),
+		})
 		log.Printf("WS Broadcast: Sent 'friendRequestUpdate' (rejected) notification to UserID %d", friendship.UserID)
 	} else {
 		log.Printf("Warning: Hub not found in context for RejectFriendRequest. HubOk: %v, HubNil: %v", hubOk, hub == nil)
@@ -423,10 +403,22 @@ func RemoveFriend(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Delete the friendship record
-	deleteResult := db.DB.Delete(&friendship)
+	// Delete the friendship record and the DM from both conversation lists
+	tx := db.DB.Begin()
+	deleteResult := tx.Delete(&friendship)
 	if deleteResult.Error != nil || deleteResult.RowsAffected == 0 {
 		// Should not happen if findResult succeeded, but check anyway
+		tx.Rollback()
+		respondWithError(w, http.StatusInternalServerError, "Failed to remove friend")
+		return
+	}
+	if err := removeConversationMembers(tx, dmConversationID(friendship.UserID, friendship.FriendID), friendship.UserID, friendship.FriendID); err != nil {
+		tx.Rollback()
+		log.Printf("Error removing DM conversation for friendship %d: %v", friendship.ID, err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to remove friend")
+		return
+	}
+	if err := tx.Commit().Error; err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Failed to remove friend")
 		return
 	}
@@ -441,21 +433,14 @@ func RemoveFriend(w http.ResponseWriter, r *http.Request) {
 		// The target is the other user involved in the friendship
 		targetUserID := uint(friendUserID)
 
-		// Construct payload for the removed friend
-		wsPayload := map[string]interface{}{
-			"id":          friendship.ID,    // ID of the friendship record that was deleted
-			"type":        "friendRemoved",  // New type
-			"removedById": claims.UserID,    // ID of the user who initiated the removal
-			"removedUser": targetUserID,     // ID of the user who was removed (TARGET USER)
-			"removerName": removerUser.Name, // *** FIX: Use fetched name ***
-			"updatedAt":   time.Now().Format("2006-01-02 15:04:05"),
-		}
-
-		wsMsg := &websocket.WSMessage{
-			Type:    "friendRemoved", // Match payload type
-			Payload: wsPayload,
-		}
-		hub.BroadcastJSON(wsMsg)
+		// Notify the removed friend
+		hub.Publish(websocket.FriendRemovedEvent{
+			ID:          friendship.ID,    // ID of the friendship record that was deleted
+			RemovedByID: claims.UserID,    // ID of the user who initiated the removal
+			RemovedUser: targetUserID,     // ID of the user who was removed (TARGET USER)
+			RemoverName: removerUser.Name, // *** FIX: Use fetched name ***
+			UpdatedAt:   time.Now().Format("2006-01-02 15:04:05"),
+		})
 		log.Printf("WS Broadcast: Sent 'friendRemoved' notification to UserID %d", targetUserID)
 	} else {
 		log.Printf("Warning: Hub not found in context for RemoveFriend. HubOk: %v, HubNil: %v", hubOk, hub == nil)
@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"encoding/json"
+	"log"
 	"net/http"
 	"strconv"
 	"strings"
@@ -18,7 +19,7 @@ import (
 type CreateGroupRequest struct {
 	Name        string `json:"name"`
 	Description string `json:"description"`
-	Avatar      string `json:"avatar"`
+	AvatarID    uint   `json:"avatarId"` // Optional; upload ID from POST /api/uploads (purpose "group")
 }
 
 // GroupResponse contains group data
@@ -169,6 +170,16 @@ func CreatePublicGroup(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	avatar := ""
+	if req.AvatarID != 0 {
+		url, ok := resolveImageUpload(req.AvatarID, claims.UserID, "group")
+		if !ok {
+			respondWithError(w, http.StatusBadRequest, "Group avatar upload not found")
+			return
+		}
+		avatar = url
+	}
+
 	// Check if group name already exists in this college
 	var existingGroup models.Group
 	db.DB.Where("college_id = ? AND name = ? AND type = ?", claims.CollegeID, req.Name, "public").First(&existingGroup)
@@ -182,7 +193,7 @@ func CreatePublicGroup(w http.ResponseWriter, r *http.Request) {
 		Name:        req.Name,
 		Description: req.Description,
 		Type:        "public",
-		Avatar:      req.Avatar,
+		Avatar:      avatar,
 		CollegeID:   claims.CollegeID,
 		CreatedBy:   &claims.UserID,
 	}
@@ -315,7 +326,19 @@ func JoinGroup(w http.ResponseWriter, r *http.Request) {
 		JoinedAt: time.Now(),
 	}
 
-	if err := db.DB.Create(&membership).Error; err != nil {
+	tx := db.DB.Begin()
+	if err := tx.Create(&membership).Error; err != nil {
+		tx.Rollback()
+		respondWithError(w, http.StatusInternalServerError, "Failed to join group")
+		return
+	}
+	if err := addConversationMembers(tx, groupConversationID(group.ID), claims.UserID); err != nil {
+		tx.Rollback()
+		log.Printf("Error adding group conversation %d for user %d: %v", group.ID, claims.UserID, err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to join group")
+		return
+	}
+	if err := tx.Commit().Error; err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Failed to join group")
 		return
 	}
@@ -350,11 +373,23 @@ func LeaveGroup(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Find and delete membership
-	result = db.DB.Where("group_id = ? AND user_id = ?", groupID, claims.UserID).Delete(&models.GroupMember{})
+	tx := db.DB.Begin()
+	result = tx.Where("group_id = ? AND user_id = ?", groupID, claims.UserID).Delete(&models.GroupMember{})
 	if result.Error != nil || result.RowsAffected == 0 {
+		tx.Rollback()
 		respondWithError(w, http.StatusNotFound, "Not a member of this group")
 		return
 	}
+	if err := removeConversationMembers(tx, groupConversationID(group.ID), claims.UserID); err != nil {
+		tx.Rollback()
+		log.Printf("Error removing group conversation %d for user %d: %v", group.ID, claims.UserID, err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to leave group")
+		return
+	}
+	if err := tx.Commit().Error; err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to leave group")
+		return
+	}
 
 	respondWithJSON(w, http.StatusOK, map[string]string{
 		"message": "Successfully left group",
@@ -432,8 +467,11 @@ func DeleteGroup(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Delete all memberships first
+	// Delete all memberships and the group's chat from conversation lists first
 	db.DB.Where("group_id = ?", groupID).Delete(&models.GroupMember{})
+	if err := removeConversationMembers(db.DB, groupConversationID(group.ID)); err != nil {
+		log.Printf("Error removing group conversation %d: %v", group.ID, err)
+	}
 
 	// Delete the group
 	db.DB.Delete(&group)
@@ -1,11 +1,13 @@
 package handlers
 
 import (
+	"encoding/base64"
 	"encoding/json"
 	"fmt" // <-- Make sure fmt is imported
 	"log" // <-- Make sure log is imported
 	"net/http"
 	"strconv"
+	"strings"
 	"time" // <-- Ensure time is imported
 
 	"gorm.io/gorm" // <-- Ensure gorm is imported
@@ -13,10 +15,14 @@ import (
 	"unilink-backend/db"
 	"unilink-backend/models"
 	"unilink-backend/utils"
+	"unilink-backend/websocket"
 
 	"github.com/gorilla/mux"
 )
 
+// defaultReservationWindow is used when a college has no window configured
+const defaultReservationWindow = 24 * time.Hour
+
 // ... (CreateListingRequest, ListingResponse, SellerInfo, toListingResponse functions remain the same) ...
 // CreateListingRequest is the payload for creating a new listing
 type CreateListingRequest struct {
@@ -24,39 +30,28 @@ type CreateListingRequest struct {
 	Description string  `json:"description"`
 	Price       float64 `json:"price"`
 	ImageURL    string  `json:"imageUrl"`
+	CategoryID  *uint   `json:"categoryId"`
+	Condition   string  `json:"condition"`
+	ImageIDs    []uint  `json:"imageIds"` // Upload IDs from POST /api/uploads, cover first
 }
 
-// ListingResponse includes seller info for display
-type ListingResponse struct {
-	ID          uint       `json:"id"`
-	Title       string     `json:"title"`
-	Description string     `json:"description"`
-	Price       float64    `json:"price"`
-	ImageURL    string     `json:"imageUrl"`
-	Status      string     `json:"status"`
-	Seller      SellerInfo `json:"seller"`
-	CreatedAt   string     `json:"createdAt"`
-	// *** NEW Fields for Reservation ***
-	Buyer         *SellerInfo `json:"buyer,omitempty"`         // Use SellerInfo for buyer details
-	ReservedUntil *string     `json:"reservedUntil,omitempty"` // String for JSON response
-}
-
-// SellerInfo contains safe seller data (also used for Buyer info)
-type SellerInfo struct {
-	ID        uint   `json:"id"`
-	Name      string `json:"name"`
-	StudentID string `json:"studentId"`
+// toSellerInfo converts a preloaded user into SellerInfo
+func toSellerInfo(user models.User) SellerInfo {
+	return SellerInfo{
+		ID:          user.ID,
+		Name:        user.Name,
+		StudentID:   user.StudentID,
+		Rating:      user.RatingAverage,
+		RatingCount: user.RatingCount,
+	}
 }
 
 // Function to convert MarketplaceListing to ListingResponse
 func toListingResponse(listing models.MarketplaceListing) ListingResponse {
 	var buyerInfo *SellerInfo
 	if listing.Buyer != nil {
-		buyerInfo = &SellerInfo{
-			ID:        listing.Buyer.ID,
-			Name:      listing.Buyer.Name,
-			StudentID: listing.Buyer.StudentID,
-		}
+		info := toSellerInfo(*listing.Buyer)
+		buyerInfo = &info
 	}
 
 	var reservedUntilStr *string
@@ -65,27 +60,75 @@ func toListingResponse(listing models.MarketplaceListing) ListingResponse {
 		reservedUntilStr = &str
 	}
 
+	images := toListingImages(listing.Images)
+	imageURL := listing.ImageURL
+	if imageURL == "" && len(images) > 0 {
+		imageURL = images[0].URL
+	}
+
 	return ListingResponse{
-		ID:          listing.ID,
-		Title:       listing.Title,
-		Description: listing.Description,
-		Price:       listing.Price,
-		ImageURL:    listing.ImageURL,
-		Status:      listing.Status,
-		Seller: SellerInfo{
-			ID:        listing.Seller.ID,
-			Name:      listing.Seller.Name,
-			StudentID: listing.Seller.StudentID,
-		},
+		ID:            listing.ID,
+		Title:         listing.Title,
+		Description:   listing.Description,
+		Price:         listing.Price,
+		ImageURL:      imageURL,
+		Images:        images,
+		Status:        listing.Status,
+		Category:      toCategoryInfo(listing.Category),
+		Condition:     listing.Condition,
+		Seller:        toSellerInfo(listing.Seller),
 		Buyer:         buyerInfo,
 		ReservedUntil: reservedUntilStr,
+		AgreedPrice:   listing.AgreedPrice,
 		CreatedAt:     listing.CreatedAt.Format("2006-01-02 15:04:05"),
 	}
 }
 
 // ... (GetAllListings, CreateListing, GetListingByID, GetMyListings, DeleteListing handlers remain the same) ...
 
-// GetAllListings returns marketplace listings filtered by user's college
+// Listing search defaults
+const (
+	defaultListingPageSize = 20
+	maxListingPageSize     = 100
+)
+
+// listingSearchExpr is the tsvector expression backed by idx_listings_search (see db.createIndexes)
+const listingSearchExpr = "to_tsvector('english', coalesce(title, '') || ' ' || coalesce(description, ''))"
+
+// ListingPageResponse is a page of listings with the cursor for the next page
+type ListingPageResponse struct {
+	Listings   []ListingResponse `json:"listings"`
+	NextCursor string            `json:"nextCursor,omitempty"` // Empty when there are no more results
+}
+
+// listingCursor marks the last row of a page: its ID plus the sort key (created_at or price)
+type listingCursor struct {
+	Sort  string  `json:"s"`
+	ID    uint    `json:"id"`
+	Time  string  `json:"t,omitempty"`
+	Price float64 `json:"p,omitempty"`
+}
+
+func encodeListingCursor(c listingCursor) string {
+	raw, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func decodeListingCursor(s string) (*listingCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	var c listingCursor
+	if err := json.Unmarshal(raw, &c); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// GetAllListings returns marketplace listings filtered by user's college.
+// Query params: q (full-text), minPrice, maxPrice, status (available|reserved|sold|all,
+// default available), sellerId, sort (newest|price_asc|price_desc), limit, cursor.
 func GetAllListings(w http.ResponseWriter, r *http.Request) {
 	claims, ok := utils.GetUserClaims(r)
 	if !ok {
@@ -93,22 +136,145 @@ func GetAllListings(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var listings []models.MarketplaceListing
-	result := db.DB.
+	params := r.URL.Query()
+	query := db.DB.
 		Preload("Seller").
-		// Preload("Buyer"). // Optional: Preload Buyer info if needed on the main list
-		Where("college_id = ? AND status = ?", claims.CollegeID, "available"). // Only show available by default
-		Order("created_at DESC").
-		Find(&listings)
+		Preload("Category").
+		Preload("Images", orderImages).
+		Where("college_id = ?", claims.CollegeID) // College isolation
+
+	// Status filter (only show available by default)
+	status := params.Get("status")
+	switch status {
+	case "", "available":
+		query = query.Where("status = ?", "available")
+	case "reserved", "sold":
+		query = query.Where("status = ?", status)
+	case "all":
+		query = query.Where("status IN ?", []string{"available", "reserved", "sold"})
+	default:
+		respondWithError(w, http.StatusBadRequest, "Status must be 'available', 'reserved', 'sold', or 'all'")
+		return
+	}
+
+	// Full-text search on title and description
+	if q := strings.TrimSpace(params.Get("q")); q != "" {
+		query = query.Where(listingSearchExpr+" @@ websearch_to_tsquery('english', ?)", q)
+	}
+
+	// Price range
+	if v := params.Get("minPrice"); v != "" {
+		minPrice, err := strconv.ParseFloat(v, 64)
+		if err != nil || minPrice < 0 {
+			respondWithError(w, http.StatusBadRequest, "Invalid minPrice")
+			return
+		}
+		query = query.Where("price >= ?", minPrice)
+	}
+	if v := params.Get("maxPrice"); v != "" {
+		maxPrice, err := strconv.ParseFloat(v, 64)
+		if err != nil || maxPrice < 0 {
+			respondWithError(w, http.StatusBadRequest, "Invalid maxPrice")
+			return
+		}
+		query = query.Where("price <= ?", maxPrice)
+	}
+
+	// Seller filter
+	if v := params.Get("sellerId"); v != "" {
+		sellerID, err := strconv.Atoi(v)
+		if err != nil || sellerID <= 0 {
+			respondWithError(w, http.StatusBadRequest, "Invalid sellerId")
+			return
+		}
+		query = query.Where("seller_id = ?", sellerID)
+	}
+
+	// Category and condition filters
+	query, errMsg := applyCategoryFilters(query, params)
+	if errMsg != "" {
+		respondWithError(w, http.StatusBadRequest, errMsg)
+		return
+	}
+
+	// Page size
+	limit := defaultListingPageSize
+	if v := params.Get("limit"); v != "" {
+		l, err := strconv.Atoi(v)
+		if err != nil || l <= 0 {
+			respondWithError(w, http.StatusBadRequest, "Invalid limit")
+			return
+		}
+		limit = l
+	}
+	if limit > maxListingPageSize {
+		limit = maxListingPageSize
+	}
+
+	// Sort order (id breaks ties so the cursor is stable)
+	sort := params.Get("sort")
+	if sort == "" {
+		sort = "newest"
+	}
+	switch sort {
+	case "newest":
+		query = query.Order("created_at DESC").Order("id DESC")
+	case "price_asc":
+		query = query.Order("price ASC").Order("id ASC")
+	case "price_desc":
+		query = query.Order("price DESC").Order("id DESC")
+	default:
+		respondWithError(w, http.StatusBadRequest, "Sort must be 'newest', 'price_asc', or 'price_desc'")
+		return
+	}
+
+	// Keyset pagination: continue after the last row of the previous page
+	if v := params.Get("cursor"); v != "" {
+		cursor, err := decodeListingCursor(v)
+		if err != nil || cursor.Sort != sort {
+			respondWithError(w, http.StatusBadRequest, "Invalid cursor")
+			return
+		}
+		switch sort {
+		case "newest":
+			cursorTime, err := time.Parse(time.RFC3339Nano, cursor.Time)
+			if err != nil {
+				respondWithError(w, http.StatusBadRequest, "Invalid cursor")
+				return
+			}
+			query = query.Where("(created_at, id) < (?, ?)", cursorTime, cursor.ID)
+		case "price_asc":
+			query = query.Where("(price, id) > (?, ?)", cursor.Price, cursor.ID)
+		case "price_desc":
+			query = query.Where("(price, id) < (?, ?)", cursor.Price, cursor.ID)
+		}
+	}
+
+	// Fetch one extra row to know whether another page exists
+	var listings []models.MarketplaceListing
+	result := query.Limit(limit + 1).Find(&listings)
 
 	if result.Error != nil {
+		log.Printf("Error searching listings for college %d: %v", claims.CollegeID, result.Error)
 		respondWithError(w, http.StatusInternalServerError, "Failed to fetch listings")
 		return
 	}
 
-	var response []ListingResponse
+	response := ListingPageResponse{Listings: []ListingResponse{}}
+	if len(listings) > limit {
+		listings = listings[:limit]
+		last := listings[len(listings)-1]
+		next := listingCursor{Sort: sort, ID: last.ID}
+		if sort == "newest" {
+			next.Time = last.CreatedAt.Format(time.RFC3339Nano)
+		} else {
+			next.Price = last.Price
+		}
+		response.NextCursor = encodeListingCursor(next)
+	}
+
 	for _, listing := range listings {
-		response = append(response, toListingResponse(listing)) // Use helper
+		response.Listings = append(response.Listings, toListingResponse(listing)) // Use helper
 	}
 
 	respondWithJSON(w, http.StatusOK, response)
@@ -133,23 +299,55 @@ func CreateListing(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if req.Condition != "" && !validConditions[req.Condition] {
+		respondWithError(w, http.StatusBadRequest, "Condition must be 'new', 'like_new', 'good', 'fair', or 'poor'")
+		return
+	}
+
+	// Category must belong to the seller's college
+	if req.CategoryID != nil {
+		var category models.ListingCategory
+		if err := db.DB.Where("id = ? AND college_id = ?", *req.CategoryID, claims.CollegeID).First(&category).Error; err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid category")
+			return
+		}
+	}
+
+	if errMsg := validateListingImages(req.ImageIDs, claims.UserID); errMsg != "" {
+		respondWithError(w, http.StatusBadRequest, errMsg)
+		return
+	}
+
 	newListing := models.MarketplaceListing{
 		Title:       req.Title,
 		Description: req.Description,
 		Price:       req.Price,
 		ImageURL:    req.ImageURL,
+		CategoryID:  req.CategoryID,
+		Condition:   req.Condition,
 		Status:      "available",
 		SellerID:    claims.UserID,
 		CollegeID:   claims.CollegeID,
 	}
 
-	result := db.DB.Create(&newListing)
-	if result.Error != nil {
+	tx := db.DB.Begin()
+	if err := tx.Create(&newListing).Error; err != nil {
+		tx.Rollback()
+		respondWithError(w, http.StatusInternalServerError, "Failed to create listing")
+		return
+	}
+	if err := replaceListingImages(tx, newListing.ID, req.ImageIDs); err != nil {
+		tx.Rollback()
+		log.Printf("Error attaching images to listing %d: %v", newListing.ID, err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to create listing")
+		return
+	}
+	if err := tx.Commit().Error; err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Failed to create listing")
 		return
 	}
 
-	db.DB.Preload("Seller").First(&newListing, newListing.ID) // Preload for response
+	db.DB.Preload("Seller").Preload("Category").Preload("Images", orderImages).First(&newListing, newListing.ID) // Preload for response
 
 	respondWithJSON(w, http.StatusCreated, toListingResponse(newListing)) // Use helper
 }
@@ -172,6 +370,8 @@ func GetListingByID(w http.ResponseWriter, r *http.Request) {
 	var listing models.MarketplaceListing
 	result := db.DB.
 		Preload("Seller").
+		Preload("Category").
+		Preload("Images", orderImages).
 		Preload("Buyer"). // *** Preload Buyer info for detail view ***
 		Where("id = ? AND college_id = ?", listingID, claims.CollegeID).
 		First(&listing)
@@ -195,6 +395,8 @@ func GetMyListings(w http.ResponseWriter, r *http.Request) {
 	var listings []models.MarketplaceListing
 	result := db.DB.
 		Preload("Seller").
+		Preload("Category").
+		Preload("Images", orderImages).
 		Preload("Buyer"). // *** Preload Buyer info ***
 		Where("seller_id = ?", claims.UserID).
 		Order("created_at DESC").
@@ -243,17 +445,281 @@ func DeleteListing(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	tx := db.DB.Begin()
+	if tx.Error != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
 	// Soft delete (or hard delete: db.DB.Unscoped().Delete(&listing))
-	if err := db.DB.Delete(&listing).Error; err != nil {
+	if err := tx.Delete(&listing).Error; err != nil {
+		tx.Rollback()
 		respondWithError(w, http.StatusInternalServerError, "Failed to delete listing")
 		return
 	}
+	closed, err := closeOpenOffers(tx, listing.ID, 0)
+	if err != nil {
+		tx.Rollback()
+		respondWithError(w, http.StatusInternalServerError, "Failed to close open offers")
+		return
+	}
+	if err := tx.Commit().Error; err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to delete listing")
+		return
+	}
+	notifyOffersClosed(r, "DeleteListing", closed)
 
 	respondWithJSON(w, http.StatusOK, map[string]string{
 		"message": "Listing deleted successfully",
 	})
 }
 
+// UpdateListingRequest holds the editable listing fields; omitted fields are left unchanged
+type UpdateListingRequest struct {
+	Title       *string  `json:"title"`
+	Description *string  `json:"description"`
+	Price       *float64 `json:"price"`
+	ImageURL    *string  `json:"imageUrl"`
+	CategoryID  *uint    `json:"categoryId"` // 0 = uncategorized
+	Condition   *string  `json:"condition"`
+	ImageIDs    *[]uint  `json:"imageIds"` // Replaces all photos, in order
+}
+
+// UpdateListing allows a user to edit their own listing (if available or cancelled).
+// Every changed field is recorded in the listing's history.
+func UpdateListing(w http.ResponseWriter, r *http.Request) {
+	claims, ok := utils.GetUserClaims(r)
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "User claims not found")
+		return
+	}
+
+	vars := mux.Vars(r)
+	listingID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid listing ID")
+		return
+	}
+
+	var req UpdateListingRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	// Find listing and verify ownership and status (same rules as DeleteListing)
+	var listing models.MarketplaceListing
+	result := db.DB.Where("id = ? AND seller_id = ?", listingID, claims.UserID).First(&listing)
+
+	if result.Error != nil {
+		respondWithError(w, http.StatusNotFound, "Listing not found or you don't own it")
+		return
+	}
+
+	if listing.Status == "reserved" || listing.Status == "sold" {
+		respondWithError(w, http.StatusForbidden, "Cannot edit a reserved or sold listing. Cancel the reservation first if applicable.")
+		return
+	}
+
+	// Collect changed fields
+	updates := map[string]interface{}{}
+	var changes []models.ListingChange
+	record := func(column, field, oldValue, newValue string, value interface{}) {
+		if oldValue == newValue {
+			return
+		}
+		updates[column] = value
+		changes = append(changes, models.ListingChange{
+			ListingID: listing.ID,
+			ChangedBy: claims.UserID,
+			Field:     field,
+			OldValue:  oldValue,
+			NewValue:  newValue,
+		})
+	}
+
+	if req.Title != nil {
+		title := strings.TrimSpace(*req.Title)
+		if title == "" {
+			respondWithError(w, http.StatusBadRequest, "Title cannot be empty")
+			return
+		}
+		record("title", "title", listing.Title, title, title)
+	}
+	if req.Description != nil {
+		record("description", "description", listing.Description, *req.Description, *req.Description)
+	}
+	if req.Price != nil {
+		if *req.Price <= 0 {
+			respondWithError(w, http.StatusBadRequest, "Price must be greater than zero")
+			return
+		}
+		record("price", "price", formatPrice(listing.Price), formatPrice(*req.Price), *req.Price)
+	}
+	if req.ImageURL != nil {
+		record("image_url", "imageUrl", listing.ImageURL, *req.ImageURL, *req.ImageURL)
+	}
+	if req.CategoryID != nil {
+		var newCategoryID *uint
+		if *req.CategoryID != 0 {
+			// Category must belong to the seller's college
+			var category models.ListingCategory
+			if err := db.DB.Where("id = ? AND college_id = ?", *req.CategoryID, claims.CollegeID).First(&category).Error; err != nil {
+				respondWithError(w, http.StatusBadRequest, "Invalid category")
+				return
+			}
+			newCategoryID = req.CategoryID
+		}
+		record("category_id", "categoryId", formatOptionalID(listing.CategoryID), formatOptionalID(newCategoryID), newCategoryID)
+	}
+	if req.Condition != nil {
+		if *req.Condition != "" && !validConditions[*req.Condition] {
+			respondWithError(w, http.StatusBadRequest, "Condition must be 'new', 'like_new', 'good', 'fair', or 'poor'")
+			return
+		}
+		record("condition", "condition", listing.Condition, *req.Condition, *req.Condition)
+	}
+
+	if req.ImageIDs != nil {
+		if errMsg := validateListingImages(*req.ImageIDs, claims.UserID); errMsg != "" {
+			respondWithError(w, http.StatusBadRequest, errMsg)
+			return
+		}
+
+		var current []models.ListingImage
+		db.DB.Where("listing_id = ?", listing.ID).Order("position ASC").Find(&current)
+		currentIDs := make([]uint, 0, len(current))
+		for _, image := range current {
+			currentIDs = append(currentIDs, image.UploadID)
+		}
+		if formatIDList(currentIDs) != formatIDList(*req.ImageIDs) {
+			changes = append(changes, models.ListingChange{
+				ListingID: listing.ID,
+				ChangedBy: claims.UserID,
+				Field:     "imageIds",
+				OldValue:  formatIDList(currentIDs),
+				NewValue:  formatIDList(*req.ImageIDs),
+			})
+		}
+	}
+
+	if len(changes) > 0 {
+		tx := db.DB.Begin()
+		if len(updates) > 0 {
+			if err := tx.Model(&listing).Updates(updates).Error; err != nil {
+				tx.Rollback()
+				log.Printf("Error updating listing %d: %v", listing.ID, err)
+				respondWithError(w, http.StatusInternalServerError, "Failed to update listing")
+				return
+			}
+		}
+		if req.ImageIDs != nil {
+			if err := replaceListingImages(tx, listing.ID, *req.ImageIDs); err != nil {
+				tx.Rollback()
+				log.Printf("Error replacing images for listing %d: %v", listing.ID, err)
+				respondWithError(w, http.StatusInternalServerError, "Failed to update listing")
+				return
+			}
+		}
+		if err := tx.Create(&changes).Error; err != nil {
+			tx.Rollback()
+			log.Printf("Error recording history for listing %d: %v", listing.ID, err)
+			respondWithError(w, http.StatusInternalServerError, "Failed to update listing")
+			return
+		}
+		if err := tx.Commit().Error; err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Failed to update listing")
+			return
+		}
+	}
+
+	db.DB.Preload("Seller").Preload("Category").Preload("Images", orderImages).Preload("Buyer").First(&listing, listing.ID) // Preload for response
+	response := toListingResponse(listing)
+
+	// --- Notify clients in the college so open listing views refresh ---
+	if len(changes) > 0 {
+		hub, hubOk := r.Context().Value(utils.HubKey).(*websocket.Hub)
+		if hubOk && hub != nil {
+			changedFields := make([]string, 0, len(changes))
+			for _, change := range changes {
+				changedFields = append(changedFields, change.Field)
+			}
+			hub.Publish(websocket.ListingUpdatedEvent{
+				ListingID:     listing.ID,
+				CollegeID:     listing.CollegeID,
+				ChangedFields: changedFields,
+				Listing:       response,
+			})
+		} else {
+			log.Printf("Warning: Hub not found in context for UpdateListing. HubOk: %v, HubNil: %v", hubOk, hub == nil)
+		}
+	}
+
+	respondWithJSON(w, http.StatusOK, response)
+}
+
+// GetListingHistory returns the edit history of a listing, newest first.
+// Pass ?field=price to get only price changes.
+func GetListingHistory(w http.ResponseWriter, r *http.Request) {
+	claims, ok := utils.GetUserClaims(r)
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "User claims not found")
+		return
+	}
+
+	vars := mux.Vars(r)
+	listingID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid listing ID")
+		return
+	}
+
+	// College isolation: the listing must be visible to the user
+	var listing models.MarketplaceListing
+	if err := db.DB.Where("id = ? AND college_id = ?", listingID, claims.CollegeID).First(&listing).Error; err != nil {
+		respondWithError(w, http.StatusNotFound, "Listing not found or access denied")
+		return
+	}
+
+	query := db.DB.Where("listing_id = ?", listing.ID)
+	if field := r.URL.Query().Get("field"); field != "" {
+		query = query.Where("field = ?", field)
+	}
+
+	var history []models.ListingChange
+	if err := query.Order("created_at DESC, id DESC").Find(&history).Error; err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to fetch listing history")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"listingId": listing.ID,
+		"history":   history,
+	})
+}
+
+// formatPrice renders a price for the history table
+func formatPrice(price float64) string {
+	return strconv.FormatFloat(price, 'f', 2, 64)
+}
+
+// formatIDList renders an ordered list of IDs for the history table, e.g. "3,1,2"
+func formatIDList(ids []uint) string {
+	parts := make([]string, 0, len(ids))
+	for _, id := range ids {
+		parts = append(parts, strconv.FormatUint(uint64(id), 10))
+	}
+	return strings.Join(parts, ",")
+}
+
+// formatOptionalID renders a nullable ID for the history table ("" = none)
+func formatOptionalID(id *uint) string {
+	if id == nil {
+		return ""
+	}
+	return strconv.FormatUint(uint64(*id), 10)
+}
+
 // *** UPDATED HANDLER: ReserveListing ***
 func ReserveListing(w http.ResponseWriter, r *http.Request) {
 	claims, ok := utils.GetUserClaims(r)
@@ -313,8 +779,9 @@ func ReserveListing(w http.ResponseWriter, r *http.Request) {
 	// Update listing status and buyer ID
 	listing.Status = "reserved"
 	listing.BuyerID = &claims.UserID // Assign buyer ID
-	// expiryTime := time.Now().Add(24 * time.Hour) // Example: 24 hour reservation
-	// listing.ReservedUntil = &expiryTime
+	// Hold expires after the college's reservation window (released by the sweeper)
+	expiryTime := time.Now().Add(reservationWindow(listing.CollegeID))
+	listing.ReservedUntil = &expiryTime
 
 	if err := tx.Save(&listing).Error; err != nil {
 		tx.Rollback()
@@ -322,67 +789,193 @@ func ReserveListing(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// *** NEW CHAT INTEGRATION LOGIC ***
-	// After reserving, check if buyer and seller are already friends.
-	// If not, create an "accepted" friendship to enable DM.
+	// Open negotiations end once the listing is reserved
+	closed, err := closeOpenOffers(tx, listing.ID, 0)
+	if err != nil {
+		tx.Rollback()
+		respondWithError(w, http.StatusInternalServerError, "Failed to close open offers")
+		return
+	}
+
 	buyerID := claims.UserID
 	sellerID := listing.SellerID
+	ensureChatFriendship(tx, listing.ID, buyerID, sellerID, claims.CollegeID)
+
+	// Commit transaction
+	if err := tx.Commit().Error; err != nil {
+		// tx.Rollback() // Rollback is implicitly called by Commit() on error
+		respondWithError(w, http.StatusInternalServerError, "Failed to finalize reservation")
+		return
+	}
+	notifyOffersClosed(r, "ReserveListing", closed)
+
+	// Preload data for response after successful commit
+	db.DB.Preload("Seller").Preload("Category").Preload("Images", orderImages).Preload("Buyer").First(&listing, listing.ID)
+
+	// Create the conversation ID for the frontend to use
+	conversationID := dmConversationID(buyerID, sellerID)
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"message":        "Listing reserved successfully! You can now chat with the seller.",
+		"listing":        toListingResponse(listing),
+		"conversationId": conversationID, // *** Send back the conversation ID ***
+	})
+}
 
+// ensureChatFriendship makes sure buyer and seller can DM each other after a reservation.
+// If they aren't already connected, an "accepted" friendship is created. Failures are
+// logged but never fail the reservation.
+func ensureChatFriendship(tx *gorm.DB, listingID uint, buyerID uint, sellerID uint, collegeID uint) {
 	var existingFriendship models.Friendship
 	// Check for friendship in either direction
-	err = tx.Where("(user_id = ? AND friend_id = ?) OR (user_id = ? AND friend_id = ?)",
+	err := tx.Where("(user_id = ? AND friend_id = ?) OR (user_id = ? AND friend_id = ?)",
 		buyerID, sellerID, sellerID, buyerID).
 		First(&existingFriendship).Error
 
 	if err != nil && err == gorm.ErrRecordNotFound {
 		// No friendship exists, create one
 		newFriendship := models.Friendship{
-			UserID:    buyerID,          // Buyer
-			FriendID:  sellerID,         // Seller
-			Status:    "accepted",       // Auto-accept to enable chat
-			CollegeID: claims.CollegeID, // Both are from the same college
+			UserID:    buyerID,    // Buyer
+			FriendID:  sellerID,   // Seller
+			Status:    "accepted", // Auto-accept to enable chat
+			CollegeID: collegeID,  // Both are from the same college
 			CreatedAt: time.Now(),
 			UpdatedAt: time.Now(),
 		}
 		if err := tx.Create(&newFriendship).Error; err != nil {
 			// Don't fail the whole reservation, just log the error
-			log.Printf("Warning: Failed to auto-create friendship for chat (ListingID: %d): %v", listing.ID, err)
+			log.Printf("Warning: Failed to auto-create friendship for chat (ListingID: %d): %v", listingID, err)
+		} else if err := addConversationMembers(tx, dmConversationID(buyerID, sellerID), buyerID, sellerID); err != nil {
+			log.Printf("Warning: Failed to add DM conversation for chat (ListingID: %d): %v", listingID, err)
 		} else {
-			log.Printf("Info: Auto-created friendship for chat (ListingID: %d) between Buyer %d and Seller %d", listing.ID, buyerID, sellerID)
+			log.Printf("Info: Auto-created friendship for chat (ListingID: %d) between Buyer %d and Seller %d", listingID, buyerID, sellerID)
 		}
 	} else if err != nil {
 		// Database error checking for friendship, log it but don't fail reservation
-		log.Printf("Warning: DB error checking friendship for chat (ListingID: %d): %v", listing.ID, err)
+		log.Printf("Warning: DB error checking friendship for chat (ListingID: %d): %v", listingID, err)
 	}
 	// If err == nil, a friendship (pending, accepted, rejected, blocked) already exists.
 	// We'll assume any existing record is fine and chat will be available or handled by friend logic.
-	// *** END NEW CHAT INTEGRATION LOGIC ***
+}
 
-	// Commit transaction
-	if err := tx.Commit().Error; err != nil {
-		// tx.Rollback() // Rollback is implicitly called by Commit() on error
-		respondWithError(w, http.StatusInternalServerError, "Failed to finalize reservation")
+// dmConversationID returns the conversation ID for a DM between two users
+func dmConversationID(userA uint, userB uint) string {
+	if userA < userB {
+		return fmt.Sprintf("dm_%d_%d", userA, userB)
+	}
+	return fmt.Sprintf("dm_%d_%d", userB, userA)
+}
+
+// ExtendReservationRequest is the payload for extending a hold
+type ExtendReservationRequest struct {
+	Hours int `json:"hours"` // Optional; 0 or omitted means the college's reservation window
+}
+
+// maxReservationExtensionHours caps a single extension at one week
+const maxReservationExtensionHours = 7 * 24
+
+// ExtendReservation lets the seller give the buyer more time before the hold expires
+func ExtendReservation(w http.ResponseWriter, r *http.Request) {
+	claims, ok := utils.GetUserClaims(r)
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "User claims not found")
 		return
 	}
 
-	// Preload data for response after successful commit
-	db.DB.Preload("Seller").Preload("Buyer").First(&listing, listing.ID)
+	vars := mux.Vars(r)
+	listingID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid listing ID")
+		return
+	}
 
-	// Create the conversation ID for the frontend to use
-	var conversationID string
-	if buyerID < sellerID {
-		conversationID = fmt.Sprintf("dm_%d_%d", buyerID, sellerID)
+	var req ExtendReservationRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+			return
+		}
+	}
+	// 0 (or no hours at all) extends by the college's reservation window
+	if req.Hours < 0 || req.Hours > maxReservationExtensionHours {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf(
+			"Extension must be between 1 and %d hours, or 0/omitted for the default window", maxReservationExtensionHours))
+		return
+	}
+
+	var listing models.MarketplaceListing
+	result := db.DB.Where("id = ? AND college_id = ?", listingID, claims.CollegeID).First(&listing)
+	if result.Error != nil {
+		respondWithError(w, http.StatusNotFound, "Listing not found or access denied")
+		return
+	}
+
+	if listing.SellerID != claims.UserID {
+		respondWithError(w, http.StatusForbidden, "Only the seller can extend a reservation")
+		return
+	}
+
+	if listing.Status != "reserved" || listing.BuyerID == nil {
+		respondWithError(w, http.StatusBadRequest, "Listing is not currently reserved")
+		return
+	}
+
+	extension := reservationWindow(listing.CollegeID)
+	if req.Hours > 0 {
+		extension = time.Duration(req.Hours) * time.Hour
+	}
+
+	// Extend from the current expiry, or from now if it already lapsed and the sweeper hasn't run yet
+	base := time.Now()
+	if listing.ReservedUntil != nil && listing.ReservedUntil.After(base) {
+		base = *listing.ReservedUntil
+	}
+	newExpiry := base.Add(extension)
+
+	// Conditional update so a concurrent cancel/expiry isn't overwritten
+	update := db.DB.Model(&models.MarketplaceListing{}).
+		Where("id = ? AND status = ? AND buyer_id = ?", listing.ID, "reserved", *listing.BuyerID).
+		Update("reserved_until", newExpiry)
+	if update.Error != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to extend reservation")
+		return
+	}
+	if update.RowsAffected == 0 {
+		respondWithError(w, http.StatusConflict, "Reservation changed, please refresh")
+		return
+	}
+
+	db.DB.Preload("Seller").Preload("Category").Preload("Images", orderImages).Preload("Buyer").First(&listing, listing.ID)
+
+	// Let the buyer know they have more time
+	hub, hubOk := r.Context().Value(utils.HubKey).(*websocket.Hub)
+	if hubOk && hub != nil {
+		hub.Publish(websocket.ReservationExtendedEvent{
+			ListingID:     listing.ID,
+			Title:         listing.Title,
+			BuyerID:       *listing.BuyerID,
+			SellerID:      listing.SellerID,
+			ReservedUntil: newExpiry.Format(time.RFC3339),
+		})
 	} else {
-		conversationID = fmt.Sprintf("dm_%d_%d", sellerID, buyerID)
+		log.Printf("Warning: Hub not found in context for ExtendReservation. HubOk: %v, HubNil: %v", hubOk, hub == nil)
 	}
 
 	respondWithJSON(w, http.StatusOK, map[string]interface{}{
-		"message":        "Listing reserved successfully! You can now chat with the seller.",
-		"listing":        toListingResponse(listing),
-		"conversationId": conversationID, // *** Send back the conversation ID ***
+		"message": "Reservation extended successfully.",
+		"listing": toListingResponse(listing),
 	})
 }
 
+// reservationWindow returns how long a reservation lasts in the given college
+func reservationWindow(collegeID uint) time.Duration {
+	var college models.College
+	if err := db.DB.Select("reservation_window_hours").First(&college, collegeID).Error; err != nil || college.ReservationWindowHours <= 0 {
+		return defaultReservationWindow
+	}
+	return time.Duration(college.ReservationWindowHours) * time.Hour
+}
+
 // *** NEW HANDLER: CancelReservation ***
 func CancelReservation(w http.ResponseWriter, r *http.Request) {
 	claims, ok := utils.GetUserClaims(r)
@@ -426,19 +1019,21 @@ func CancelReservation(w http.ResponseWriter, r *http.Request) {
 	listing.BuyerID = nil
 	// listing.Buyer = nil // GORM might need help clearing relation, use Update columns
 	listing.ReservedUntil = nil
+	listing.AgreedPrice = nil
 
 	// Use Update columns to correctly set fields to NULL
 	if err := db.DB.Model(&listing).Updates(map[string]interface{}{
 		"status":         "available",
 		"buyer_id":       nil,
 		"reserved_until": nil,
+		"agreed_price":   nil,
 	}).Error; err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Failed to cancel reservation")
 		return
 	}
 
 	// Preload data for response
-	db.DB.Preload("Seller").First(&listing, listing.ID) // Buyer is now nil
+	db.DB.Preload("Seller").Preload("Category").Preload("Images", orderImages).First(&listing, listing.ID) // Buyer is now nil
 
 	respondWithJSON(w, http.StatusOK, map[string]interface{}{
 		"message": "Reservation cancelled successfully.",
@@ -494,13 +1089,30 @@ func MarkListingSold(w http.ResponseWriter, r *http.Request) {
 	listing.Status = "sold"
 	listing.ReservedUntil = nil // Clear reservation time if set
 
-	if err := db.DB.Save(&listing).Error; err != nil {
+	tx := db.DB.Begin()
+	if tx.Error != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if err := tx.Save(&listing).Error; err != nil {
+		tx.Rollback()
+		respondWithError(w, http.StatusInternalServerError, "Failed to mark listing as sold")
+		return
+	}
+	closed, err := closeOpenOffers(tx, listing.ID, 0)
+	if err != nil {
+		tx.Rollback()
+		respondWithError(w, http.StatusInternalServerError, "Failed to close open offers")
+		return
+	}
+	if err := tx.Commit().Error; err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Failed to mark listing as sold")
 		return
 	}
+	notifyOffersClosed(r, "MarkListingSold", closed)
 
 	// Preload data for response
-	db.DB.Preload("Seller").Preload("Buyer").First(&listing, listing.ID)
+	db.DB.Preload("Seller").Preload("Category").Preload("Images", orderImages).Preload("Buyer").First(&listing, listing.ID)
 
 	respondWithJSON(w, http.StatusOK, map[string]interface{}{
 		"message": "Listing marked as sold successfully.",
@@ -520,6 +1132,8 @@ func GetMyReservations(w http.ResponseWriter, r *http.Request) {
 	var listings []models.MarketplaceListing
 	result := db.DB.
 		Preload("Seller").                                               // Preload the Seller info
+		Preload("Category").                                             // Preload the listing category
+		Preload("Images", orderImages).                                  // Preload the listing photos
 		Preload("Buyer").                                                // Preload our own info (Buyer)
 		Where("buyer_id = ? AND status = ?", claims.UserID, "reserved"). // Find items reserved by this user
 		Order("updated_at DESC").                                        // Show most recently reserved first
@@ -2,9 +2,12 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt" // Keep fmt
 	"log"
 	"net/http"
+	"os"
+	"sort"
 	"strconv" // Keep strconv
 	"strings" // Keep strings
 	"time"    // Keep time
@@ -15,10 +18,27 @@ import (
 	"unilink-backend/websocket"
 
 	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // --- REMOVE local key definitions ---
 
+// Message and conversation page sizes
+const (
+	defaultConversationPageSize = 50
+	maxConversationPageSize     = 100
+	defaultMessagePageSize      = 50
+	maxMessagePageSize          = 100
+	maxSyncPageSize             = 500
+)
+
+// quotePreviewLength is how many characters of a parent message a reply quotes
+const quotePreviewLength = 100
+
+// defaultMessageEditWindow is used when MESSAGE_EDIT_WINDOW is not set
+const defaultMessageEditWindow = 15 * time.Minute
+
 // ... (Keep SendMessageRequest, MessageResponse, MessageSenderData, ConversationListItem structs) ...
 // SendMessageRequest is the payload for sending a message
 type SendMessageRequest struct {
@@ -27,25 +47,18 @@ type SendMessageRequest struct {
 	ConversationID   string `json:"conversationId"`       // "dm_{userId1}_{userId2}" or "group_{groupId}"
 	ReceiverID       *uint  `json:"receiverId,omitempty"` // For DMs
 	GroupID          *uint  `json:"groupId,omitempty"`    // For group messages
+	ReplyToID        *uint  `json:"replyToId,omitempty"`  // Message being replied to, in the same conversation
+	AttachmentIDs    []uint `json:"attachmentIds"`        // Uploaded via /conversations/{id}/attachments; content is optional with these
 }
 
-// MessageResponse contains message data
-type MessageResponse struct {
-	ID               uint              `json:"id"`
-	Content          string            `json:"content"`
-	Type             string            `json:"type"`
-	ConversationType string            `json:"conversationType"`
-	ConversationID   string            `json:"conversationId"`
-	Sender           MessageSenderData `json:"sender"`
-	IsRead           bool              `json:"isRead"`
-	CreatedAt        string            `json:"createdAt"`
+// EditMessageRequest is the payload for editing a message
+type EditMessageRequest struct {
+	Content string `json:"content"`
 }
 
-// MessageSenderData contains sender info
-type MessageSenderData struct {
-	ID             uint   `json:"id"`
-	Name           string `json:"name"`
-	ProfilePicture string `json:"profilePicture"`
+// MarkReadRequest is the payload for marking a conversation read
+type MarkReadRequest struct {
+	MessageID uint `json:"messageId"` // Read up to and including this message; 0 = latest
 }
 
 // ConversationListItem represents a conversation in the list
@@ -62,7 +75,9 @@ type ConversationListItem struct {
 }
 
 // ... (Keep GetConversations, GetMessages functions) ...
-// GetConversations returns all conversations (DMs + Groups) for the user
+// GetConversations returns the user's conversations (DMs + Groups), most recently
+// active first (?limit=, ?offset=). It uses the stored conversation summaries, so the
+// number of queries doesn't grow with the number of conversations.
 func GetConversations(w http.ResponseWriter, r *http.Request) {
 	claims, ok := utils.GetUserClaims(r)
 	if !ok {
@@ -70,102 +85,152 @@ func GetConversations(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var conversations []ConversationListItem
-
-	// 1. Get DM conversations (friendships with accepted status)
-	var friendships []models.Friendship
-	db.DB.Preload("User").Preload("Friend").
-		Where("(user_id = ? OR friend_id = ?) AND status = ?", claims.UserID, claims.UserID, "accepted").
-		Find(&friendships)
-
-	for _, f := range friendships {
-		var friend models.User
-		if f.UserID == claims.UserID {
-			friend = f.Friend
-		} else {
-			friend = f.User
+	params := r.URL.Query()
+	limit := messagePageLimit(params.Get("limit"), defaultConversationPageSize, maxConversationPageSize)
+	offset := 0
+	if v := params.Get("offset"); v != "" {
+		o, err := strconv.Atoi(v)
+		if err != nil || o < 0 {
+			respondWithError(w, http.StatusBadRequest, "Invalid offset")
+			return
 		}
+		offset = o
+	}
 
-		var conversationID string
-		if claims.UserID < friend.ID {
-			conversationID = fmt.Sprintf("dm_%d_%d", claims.UserID, friend.ID)
-		} else {
-			conversationID = fmt.Sprintf("dm_%d_%d", friend.ID, claims.UserID)
-		}
+	var total int64
+	db.DB.Model(&models.ConversationMember{}).Where("user_id = ?", claims.UserID).Count(&total)
 
-		var lastMsg models.Message
-		db.DB.Where("conversation_id = ?", conversationID).Order("created_at DESC").First(&lastMsg)
-
-		var unreadCount int64
-		db.DB.Model(&models.Message{}).
-			Where("conversation_id = ? AND sender_id != ? AND is_read = ?", conversationID, claims.UserID, false).
-			Count(&unreadCount)
-
-		conversations = append(conversations, ConversationListItem{
-			ConversationType: "dm",
-			ConversationID:   conversationID,
-			Name:             friend.Name,
-			Avatar:           friend.ProfilePicture,
-			LastMessage:      lastMsg.Content,
-			LastMessageTime:  lastMsg.CreatedAt.Format("2006-01-02 15:04:05"), // Use format
-			UnreadCount:      int(unreadCount),
-			Participant: &MessageSenderData{
-				ID:             friend.ID,
-				Name:           friend.Name,
-				ProfilePicture: friend.ProfilePicture,
-			},
-		})
+	var members []models.ConversationMember
+	if err := db.DB.Where("user_id = ?", claims.UserID).
+		Order("last_activity_at DESC NULLS LAST, conversation_id ASC").
+		Limit(limit).
+		Offset(offset).
+		Find(&members).Error; err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to fetch conversations")
+		return
 	}
 
-	// 2. Get Group conversations (groups user is member of)
-	var memberships []models.GroupMember
-	db.DB.Preload("Group").Where("user_id = ?", claims.UserID).Find(&memberships)
+	// Load everything the page needs in bulk
+	conversationIDs := make([]string, 0, len(members))
+	var friendIDs, groupIDs []uint
+	for _, m := range members {
+		conversationIDs = append(conversationIDs, m.ConversationID)
+		var a, b uint
+		if n, _ := fmt.Sscanf(m.ConversationID, "dm_%d_%d", &a, &b); n == 2 {
+			if a == claims.UserID {
+				friendIDs = append(friendIDs, b)
+			} else {
+				friendIDs = append(friendIDs, a)
+			}
+		} else if n, _ := fmt.Sscanf(m.ConversationID, "group_%d", &a); n == 1 {
+			groupIDs = append(groupIDs, a)
+		}
+	}
 
-	for _, m := range memberships {
-		conversationID := fmt.Sprintf("group_%d", m.Group.ID)
+	conversations := make(map[string]models.Conversation, len(members))
+	lastMessages := make(map[uint]models.Message)
+	friends := make(map[uint]models.User)
+	groups := make(map[uint]models.Group)
+	if len(conversationIDs) > 0 {
+		var rows []models.Conversation
+		db.DB.Where("id IN ?", conversationIDs).Find(&rows)
+		var lastMessageIDs []uint
+		for _, c := range rows {
+			conversations[c.ID] = c
+			if c.LastMessageID != nil {
+				lastMessageIDs = append(lastMessageIDs, *c.LastMessageID)
+			}
+		}
+		if len(lastMessageIDs) > 0 {
+			var messages []models.Message
+			db.DB.Preload("Sender").Where("id IN ?", lastMessageIDs).Find(&messages)
+			for _, msg := range messages {
+				lastMessages[msg.ID] = msg
+			}
+		}
+	}
+	if len(friendIDs) > 0 {
+		var users []models.User
+		db.DB.Where("id IN ?", friendIDs).Find(&users)
+		for _, user := range users {
+			friends[user.ID] = user
+		}
+	}
+	if len(groupIDs) > 0 {
+		var rows []models.Group
+		db.DB.Where("id IN ?", groupIDs).Find(&rows)
+		for _, group := range rows {
+			groups[group.ID] = group
+		}
+	}
 
+	items := make([]ConversationListItem, 0, len(members))
+	for _, m := range members {
 		var lastMsg models.Message
-		db.DB.Preload("Sender").Where("conversation_id = ?", conversationID).Order("created_at DESC").First(&lastMsg)
+		if c := conversations[m.ConversationID]; c.LastMessageID != nil {
+			lastMsg = lastMessages[*c.LastMessageID]
+		}
+
+		var a, b uint
+		if n, _ := fmt.Sscanf(m.ConversationID, "dm_%d_%d", &a, &b); n == 2 {
+			friendID := a
+			if a == claims.UserID {
+				friendID = b
+			}
+			friend := friends[friendID]
+			items = append(items, ConversationListItem{
+				ConversationType: "dm",
+				ConversationID:   m.ConversationID,
+				Name:             friend.Name,
+				Avatar:           friend.ProfilePicture,
+				LastMessage:      messagePreview(lastMsg),
+				LastMessageTime:  lastMsg.CreatedAt.Format("2006-01-02 15:04:05"), // Use format
+				UnreadCount:      m.UnreadCount,
+				Participant: &MessageSenderData{
+					ID:             friend.ID,
+					Name:           friend.Name,
+					ProfilePicture: friend.ProfilePicture,
+				},
+			})
+			continue
+		}
 
-		var unreadCount int64
-		db.DB.Model(&models.Message{}).
-			Where("conversation_id = ? AND sender_id != ? AND is_read = ?", conversationID, claims.UserID, false).
-			Count(&unreadCount)
+		fmt.Sscanf(m.ConversationID, "group_%d", &a)
+		group := groups[a]
 
-		lastMessage := lastMsg.Content
+		lastMessage := messagePreview(lastMsg)
 		if lastMsg.SenderID != 0 {
 			// Ensure sender name isn't empty before prepending
 			senderName := lastMsg.Sender.Name
 			if senderName == "" { // Fallback if sender preload failed or name is empty
 				senderName = "User"
 			}
-			lastMessage = senderName + ": " + lastMsg.Content
+			lastMessage = senderName + ": " + messagePreview(lastMsg)
 		}
 
-		conversations = append(conversations, ConversationListItem{
+		items = append(items, ConversationListItem{
 			ConversationType: "group",
-			ConversationID:   conversationID,
-			Name:             m.Group.Name,
-			Avatar:           m.Group.Avatar,
+			ConversationID:   m.ConversationID,
+			Name:             group.Name,
+			Avatar:           group.Avatar,
 			LastMessage:      lastMessage,
 			LastMessageTime:  lastMsg.CreatedAt.Format("2006-01-02 15:04:05"), // Use format
-			UnreadCount:      int(unreadCount),
-			GroupInfo: &GroupResponse{ // Assuming GroupResponse is defined elsewhere or inline it
-				ID:          m.Group.ID,
-				Name:        m.Group.Name,
-				Description: m.Group.Description,
-				Type:        m.Group.Type,
-				Avatar:      m.Group.Avatar,
+			UnreadCount:      m.UnreadCount,
+			GroupInfo: &GroupResponse{
+				ID:          group.ID,
+				Name:        group.Name,
+				Description: group.Description,
+				Type:        group.Type,
+				Avatar:      group.Avatar,
 				// MemberCount needs separate query if required here
 			},
 		})
 	}
 
-	// TODO: Sort conversations by LastMessageTime DESC before sending
-
 	respondWithJSON(w, http.StatusOK, map[string]interface{}{
-		"total":         len(conversations),
-		"conversations": conversations,
+		"total":         total, // All of the user's conversations
+		"conversations": items,
+		"hasMore":       int64(offset+len(items)) < total,
 	})
 }
 
@@ -190,62 +255,295 @@ func GetMessages(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	limitStr := r.URL.Query().Get("limit")
-	offsetStr := r.URL.Query().Get("offset")
-	limit := 50
-	offset := 0
-	if l, err := strconv.Atoi(limitStr); err == nil && l > 0 {
-		limit = l
+	cursor, ok := parseMessageCursor(w, r)
+	if !ok {
+		return
 	}
-	if o, err := strconv.Atoi(offsetStr); err == nil && o >= 0 {
-		offset = o
+	messages, hasMore, err := cursor.page(db.DB.Where("conversation_id = ? AND is_deleted = ?", conversationID, false))
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to fetch messages")
+		return
+	}
+
+	// Opening a conversation marks it read for this user only
+	if lastRead, advanced, err := markConversationRead(conversationID, claims.UserID, 0); err != nil {
+		log.Printf("Error marking conversation %s read for user %d: %v", conversationID, claims.UserID, err)
+	} else if advanced {
+		hub, _ := r.Context().Value(utils.HubKey).(*websocket.Hub)
+		broadcastMessagesRead(hub, conversationID, claims.UserID, lastRead)
+	}
+
+	response := buildMessageResponses(messages, conversationID, claims.UserID)
+
+	page := map[string]interface{}{
+		"total":    len(response), // Messages in this page
+		"messages": response,
+	}
+	addPageCursors(page, messages, hasMore)
+	respondWithJSON(w, http.StatusOK, page)
+}
+
+// messageCursor is a page request by message ID: ?before=ID pages back, ?after=ID
+// pages forward, neither returns the newest page
+type messageCursor struct {
+	beforeID, afterID uint64
+	limit             int
+}
+
+// parseMessageCursor reads ?before=, ?after= and ?limit=, writing a 400 if they're invalid
+func parseMessageCursor(w http.ResponseWriter, r *http.Request) (messageCursor, bool) {
+	params := r.URL.Query()
+	cursor := messageCursor{limit: messagePageLimit(params.Get("limit"), defaultMessagePageSize, maxMessagePageSize)}
+	var err error
+	if v := params.Get("before"); v != "" {
+		if cursor.beforeID, err = strconv.ParseUint(v, 10, 64); err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid before cursor")
+			return cursor, false
+		}
+	}
+	if v := params.Get("after"); v != "" {
+		if cursor.afterID, err = strconv.ParseUint(v, 10, 64); err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid after cursor")
+			return cursor, false
+		}
+	}
+	if cursor.beforeID != 0 && cursor.afterID != 0 {
+		respondWithError(w, http.StatusBadRequest, "Use either before or after, not both")
+		return cursor, false
+	}
+	return cursor, true
+}
+
+// page loads the requested page of the messages matched by query, oldest first.
+// hasMore reports another page in the same direction.
+func (c messageCursor) page(query *gorm.DB) ([]models.Message, bool, error) {
+	query = query.Preload("Sender").
+		Preload("ReplyTo.Sender").
+		Preload("Attachments", orderAttachments)
+	if c.afterID != 0 {
+		query = query.Where("id > ?", c.afterID).Order("id ASC")
+	} else {
+		if c.beforeID != 0 {
+			query = query.Where("id < ?", c.beforeID)
+		}
+		query = query.Order("id DESC")
 	}
 
 	var messages []models.Message
-	result := db.DB.Preload("Sender").
-		Where("conversation_id = ? AND is_deleted = ?", conversationID, false).
-		Order("created_at ASC"). // Fetch in ascending order for easier display
-		Limit(limit).
-		Offset(offset).
-		Find(&messages)
+	// Fetch one extra row to know if there is another page
+	if err := query.Limit(c.limit + 1).Find(&messages).Error; err != nil {
+		return nil, false, err
+	}
 
-	if result.Error != nil {
-		respondWithError(w, http.StatusInternalServerError, "Failed to fetch messages")
+	hasMore := len(messages) > c.limit
+	if hasMore {
+		messages = messages[:c.limit]
+	}
+	if c.afterID == 0 {
+		// Newest-first from the query; flip for display
+		for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+			messages[i], messages[j] = messages[j], messages[i]
+		}
+	}
+	return messages, hasMore, nil
+}
+
+// addPageCursors adds hasMore and the cursors for the neighbouring pages to a response
+func addPageCursors(page map[string]interface{}, messages []models.Message, hasMore bool) {
+	page["hasMore"] = hasMore // More older messages (before/default) or newer ones (after)
+	if len(messages) > 0 {
+		page["oldestId"] = messages[0].ID               // Pass as ?before= for the previous page
+		page["newestId"] = messages[len(messages)-1].ID // Pass as ?after= for the next page
+	}
+}
+
+// messagePageLimit parses a ?limit= value, falling back to def and capping at max
+func messagePageLimit(v string, def int, max int) int {
+	limit, err := strconv.Atoi(v)
+	if err != nil || limit <= 0 {
+		return def
+	}
+	if limit > max {
+		return max
+	}
+	return limit
+}
+
+// accessibleConversationIDs lists the conversations a user can currently read:
+// DMs with accepted friends and groups they are a member of
+func accessibleConversationIDs(userID uint) []string {
+	var conversationIDs []string
+
+	var friendships []models.Friendship
+	db.DB.Where("(user_id = ? OR friend_id = ?) AND status = ?", userID, userID, "accepted").Find(&friendships)
+	for _, f := range friendships {
+		conversationIDs = append(conversationIDs, dmConversationID(f.UserID, f.FriendID))
+	}
+
+	var groupIDs []uint
+	db.DB.Model(&models.GroupMember{}).Where("user_id = ?", userID).Pluck("group_id", &groupIDs)
+	for _, groupID := range groupIDs {
+		conversationIDs = append(conversationIDs, fmt.Sprintf("group_%d", groupID))
+	}
+
+	return conversationIDs
+}
+
+// SyncMessages returns messages newer than ?since= (a message ID) across all of the
+// user's conversations, oldest first. Clients call it after reconnecting the
+// WebSocket to backfill what they missed; repeat with the returned lastMessageId
+// while hasMore is true.
+//
+// With ?changedSince= (the syncedAt of the previous sync) it also returns messages
+// up to since that were edited, deleted or reacted to in the meantime: edited holds
+// their current state and deletedIds the ones that are gone. Repeat with
+// changedSince=changesUntil while hasMoreChanges is true. Applying a change twice is harmless.
+func SyncMessages(w http.ResponseWriter, r *http.Request) {
+	claims, ok := utils.GetUserClaims(r)
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "User claims not found")
 		return
 	}
 
-	// Mark messages as read (can be done async or after response for perf)
-	go func(convID string, userID uint) {
-		db.DB.Model(&models.Message{}).
-			Where("conversation_id = ? AND sender_id != ? AND is_read = ?", convID, userID, false).
-			Update("is_read", true)
-	}(conversationID, claims.UserID)
+	params := r.URL.Query()
+	sinceID, err := strconv.ParseUint(params.Get("since"), 10, 64)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "since must be the ID of the last message seen")
+		return
+	}
+	limit := messagePageLimit(params.Get("limit"), maxSyncPageSize, maxSyncPageSize)
 
-	var response []MessageResponse
-	// No need to reverse if fetched in ASC order
+	var changedSince *time.Time
+	if v := params.Get("changedSince"); v != "" {
+		t, err := time.Parse(time.RFC3339Nano, v)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "changedSince must be an RFC 3339 timestamp")
+			return
+		}
+		changedSince = &t
+	}
+	// Taken before querying, so nothing that changes during the sync is skipped next time
+	syncedAt := time.Now()
+
+	conversationIDs := accessibleConversationIDs(claims.UserID)
+	if len(conversationIDs) == 0 {
+		respondWithJSON(w, http.StatusOK, map[string]interface{}{
+			"messages":       []MessageResponse{},
+			"conversations":  []map[string]interface{}{},
+			"hasMore":        false,
+			"lastMessageId":  sinceID,
+			"edited":         []MessageResponse{},
+			"deletedIds":     []uint{},
+			"hasMoreChanges": false,
+			"syncedAt":       syncedAt.Format(time.RFC3339Nano),
+		})
+		return
+	}
+
+	var messages []models.Message
+	if err := db.DB.Preload("Sender").
+		Preload("ReplyTo.Sender").
+		Preload("Attachments", orderAttachments).
+		Where("conversation_id IN ? AND id > ? AND is_deleted = ?", conversationIDs, sinceID, false).
+		Order("id ASC").
+		Limit(limit + 1).
+		Find(&messages).Error; err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to sync messages")
+		return
+	}
+
+	hasMore := len(messages) > limit
+	if hasMore {
+		messages = messages[:limit]
+	}
+
+	response := buildSyncResponses(messages, claims.UserID)
+	conversations := make([]map[string]interface{}, 0)
+	seen := make(map[string]bool)
 	for _, msg := range messages {
-		response = append(response, MessageResponse{
-			ID:               msg.ID,
-			Content:          msg.Content,
-			Type:             msg.Type,
-			ConversationType: msg.ConversationType,
-			ConversationID:   msg.ConversationID,
-			Sender: MessageSenderData{
-				ID:             msg.Sender.ID,
-				Name:           msg.Sender.Name,
-				ProfilePicture: msg.Sender.ProfilePicture,
-			},
-			IsRead:    msg.IsRead,
-			CreatedAt: msg.CreatedAt.Format("2006-01-02 15:04:05"), // Use format
+		if seen[msg.ConversationID] {
+			continue
+		}
+		seen[msg.ConversationID] = true
+		conversations = append(conversations, map[string]interface{}{
+			"conversationId": msg.ConversationID,
+			"unreadCount":    countUnread(msg.ConversationID, claims.UserID),
 		})
 	}
 
+	lastMessageID := uint(sinceID)
+	if len(messages) > 0 {
+		lastMessageID = messages[len(messages)-1].ID
+	}
+
+	edited := []MessageResponse{}
+	deletedIDs := []uint{}
+	hasMoreChanges := false
+	var changesUntil *string
+	if changedSince != nil {
+		var changed []models.Message
+		if err := db.DB.Preload("Sender").
+			Preload("ReplyTo.Sender").
+			Preload("Attachments", orderAttachments).
+			Where("conversation_id IN ? AND id <= ? AND updated_at > ?", conversationIDs, sinceID, *changedSince).
+			Order("updated_at ASC, id ASC").
+			Limit(limit + 1).
+			Find(&changed).Error; err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Failed to sync message changes")
+			return
+		}
+
+		hasMoreChanges = len(changed) > limit
+		if hasMoreChanges {
+			changed = changed[:limit]
+			until := changed[len(changed)-1].UpdatedAt.Format(time.RFC3339Nano)
+			changesUntil = &until
+		}
+
+		var current []models.Message
+		for _, msg := range changed {
+			if msg.IsDeleted {
+				deletedIDs = append(deletedIDs, msg.ID)
+			} else {
+				current = append(current, msg)
+			}
+		}
+		edited = buildSyncResponses(current, claims.UserID)
+	}
+
 	respondWithJSON(w, http.StatusOK, map[string]interface{}{
-		"total":    len(response), // This might not be the *total* in DB if paginated
-		"messages": response,
+		"messages":       response,
+		"conversations":  conversations, // Unread counts for conversations with new messages
+		"hasMore":        hasMore,
+		"lastMessageId":  lastMessageID,
+		"edited":         edited,
+		"deletedIds":     deletedIDs,
+		"hasMoreChanges": hasMoreChanges,
+		"changesUntil":   changesUntil,
+		"syncedAt":       syncedAt.Format(time.RFC3339Nano),
 	})
 }
 
+// buildSyncResponses builds responses for messages from several conversations, in ID order.
+// Read state is per conversation, so responses are built conversation by conversation.
+func buildSyncResponses(messages []models.Message, viewerID uint) []MessageResponse {
+	byConversation := make(map[string][]models.Message)
+	var order []string
+	for _, msg := range messages {
+		if _, seen := byConversation[msg.ConversationID]; !seen {
+			order = append(order, msg.ConversationID)
+		}
+		byConversation[msg.ConversationID] = append(byConversation[msg.ConversationID], msg)
+	}
+
+	response := make([]MessageResponse, 0, len(messages))
+	for _, conversationID := range order {
+		response = append(response, buildMessageResponses(byConversation[conversationID], conversationID, viewerID)...)
+	}
+	sort.Slice(response, func(i, j int) bool { return response[i].ID < response[j].ID })
+	return response
+}
+
 func SendMessage(w http.ResponseWriter, r *http.Request) {
 	claims, ok := utils.GetUserClaims(r)
 	if !ok {
@@ -259,41 +557,80 @@ func SendMessage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	req.Content = strings.TrimSpace(req.Content)
-	if req.Content == "" {
-		respondWithError(w, http.StatusBadRequest, "Message content is required")
+	responsePayload, status, err := createMessage(claims.UserID, claims.CollegeID, req)
+	if err != nil {
+		respondWithError(w, status, err.Error())
 		return
 	}
+
+	// --- Broadcast the new message via Hub ---
+	hub, ok := r.Context().Value(utils.HubKey).(*websocket.Hub) // Use key from utils
+	if ok && hub != nil {
+		publishNewMessage(hub, responsePayload)
+	} else {
+		log.Printf("Warning: Hub not found in context for SendMessage. Ok: %v, HubNil: %v", ok, hub == nil)
+	}
+	// --- End Broadcast ---
+
+	// Send HTTP response
+	respondWithJSON(w, http.StatusCreated, map[string]interface{}{
+		"message": responsePayload, // Send back the created message object
+	})
+}
+
+// createMessage validates and stores a message from userID. It is shared by the REST
+// endpoint and the WebSocket "sendMessage" action. On failure it returns the HTTP
+// status and a client-facing error.
+func createMessage(userID uint, collegeID uint, req SendMessageRequest) (MessageResponse, int, error) {
+	req.Content = strings.TrimSpace(req.Content)
+	if req.Content == "" && len(req.AttachmentIDs) == 0 {
+		return MessageResponse{}, http.StatusBadRequest, errors.New("Message content is required")
+	}
+	if len(req.AttachmentIDs) > maxMessageAttachments {
+		return MessageResponse{}, http.StatusBadRequest, fmt.Errorf("A message can have at most %d attachments", maxMessageAttachments)
+	}
+	seenAttachments := make(map[uint]bool, len(req.AttachmentIDs))
+	for _, id := range req.AttachmentIDs {
+		if seenAttachments[id] {
+			return MessageResponse{}, http.StatusBadRequest, errors.New("Duplicate attachment in list")
+		}
+		seenAttachments[id] = true
+	}
 	if req.ConversationType != "dm" && req.ConversationType != "group" {
-		respondWithError(w, http.StatusBadRequest, "Invalid conversation type")
-		return
+		return MessageResponse{}, http.StatusBadRequest, errors.New("Invalid conversation type")
 	}
 
 	// Re-verify or generate conversation ID
 	if req.ConversationType == "dm" {
 		if req.ReceiverID == nil || *req.ReceiverID == 0 {
-			respondWithError(w, http.StatusBadRequest, "Missing receiverId for DM")
-			return
+			return MessageResponse{}, http.StatusBadRequest, errors.New("Missing receiverId for DM")
 		}
-		if claims.UserID < *req.ReceiverID {
-			req.ConversationID = fmt.Sprintf("dm_%d_%d", claims.UserID, *req.ReceiverID)
+		if userID < *req.ReceiverID {
+			req.ConversationID = fmt.Sprintf("dm_%d_%d", userID, *req.ReceiverID)
 		} else {
-			req.ConversationID = fmt.Sprintf("dm_%d_%d", *req.ReceiverID, claims.UserID)
+			req.ConversationID = fmt.Sprintf("dm_%d_%d", *req.ReceiverID, userID)
 		}
 		req.GroupID = nil // Ensure GroupID is nil for DMs
 	} else { // group
 		if req.GroupID == nil || *req.GroupID == 0 {
-			respondWithError(w, http.StatusBadRequest, "Missing groupId for group message")
-			return
+			return MessageResponse{}, http.StatusBadRequest, errors.New("Missing groupId for group message")
 		}
 		req.ConversationID = fmt.Sprintf("group_%d", *req.GroupID)
 		req.ReceiverID = nil // Ensure ReceiverID is nil for group messages
 	}
 
 	// Verify user has access
-	if !userHasAccessToConversation(claims.UserID, req.ConversationID) {
-		respondWithError(w, http.StatusForbidden, "Access denied to this conversation")
-		return
+	if !userHasAccessToConversation(userID, req.ConversationID) {
+		return MessageResponse{}, http.StatusForbidden, errors.New("Access denied to this conversation")
+	}
+
+	// A reply must point at a live message in the same conversation
+	if req.ReplyToID != nil {
+		var parent models.Message
+		result := db.DB.Where("id = ? AND conversation_id = ? AND is_deleted = ?", *req.ReplyToID, req.ConversationID, false).First(&parent)
+		if result.Error != nil {
+			return MessageResponse{}, http.StatusBadRequest, errors.New("Message being replied to was not found in this conversation")
+		}
 	}
 
 	// Create message
@@ -302,28 +639,66 @@ func SendMessage(w http.ResponseWriter, r *http.Request) {
 		Type:             "text",
 		ConversationType: req.ConversationType,
 		ConversationID:   req.ConversationID,
-		SenderID:         claims.UserID,
+		SenderID:         userID,
 		ReceiverID:       req.ReceiverID, // Will be nil for group messages
 		GroupID:          req.GroupID,    // Will be nil for DMs
-		CollegeID:        claims.CollegeID,
-		IsRead:           false, // Initially unread for others
+		ReplyToID:        req.ReplyToID,
+		CollegeID:        collegeID,
 		IsDeleted:        false,
 		CreatedAt:        time.Now(), // Explicitly set creation time
 		UpdatedAt:        time.Now(),
 	}
 
-	// Save to DB
-	if err := db.DB.Create(&message).Error; err != nil {
+	// Save to DB (with its attachments, if any)
+	tx := db.DB.Begin()
+	if err := tx.Create(&message).Error; err != nil {
+		tx.Rollback()
 		log.Printf("Error creating message in DB: %v", err) // Log DB error
-		respondWithError(w, http.StatusInternalServerError, "Failed to send message")
-		return
+		return MessageResponse{}, http.StatusInternalServerError, errors.New("Failed to send message")
+	}
+	if len(req.AttachmentIDs) > 0 {
+		messageType, err := claimAttachments(tx, req.AttachmentIDs, userID, message.ConversationID, message.ID)
+		if err != nil {
+			tx.Rollback()
+			if errors.Is(err, errAttachmentsUnavailable) {
+				return MessageResponse{}, http.StatusBadRequest, err
+			}
+			log.Printf("Error attaching files to message %d: %v", message.ID, err)
+			return MessageResponse{}, http.StatusInternalServerError, errors.New("Failed to send message")
+		}
+		if err := tx.Model(&message).Update("type", messageType).Error; err != nil {
+			tx.Rollback()
+			return MessageResponse{}, http.StatusInternalServerError, errors.New("Failed to send message")
+		}
+	}
+	if err := recordConversationMessage(tx, message); err != nil {
+		tx.Rollback()
+		log.Printf("Error updating conversation %s for message %d: %v", message.ConversationID, message.ID, err)
+		return MessageResponse{}, http.StatusInternalServerError, errors.New("Failed to send message")
+	}
+	if err := tx.Commit().Error; err != nil {
+		return MessageResponse{}, http.StatusInternalServerError, errors.New("Failed to send message")
+	}
+
+	// The sender has obviously read everything up to their own message
+	if _, _, err := markConversationRead(message.ConversationID, userID, message.ID); err != nil {
+		log.Printf("Error advancing read state for sender %d in %s: %v", userID, message.ConversationID, err)
 	}
 
 	// Preload sender for response/broadcast (even though we have claims, this ensures consistency)
-	db.DB.Preload("Sender").First(&message, message.ID)
+	db.DB.Preload("Sender").
+		Preload("ReplyTo.Sender").
+		Preload("Attachments", orderAttachments).
+		First(&message, message.ID)
+
+	// Prepare response payload (IsRead stays false: nobody else has seen it yet)
+	return toMessageResponse(message), http.StatusCreated, nil
+}
 
-	// Prepare response payload
-	responsePayload := MessageResponse{
+// toMessageResponse converts a message with its Sender preloaded. Read state is
+// per viewer and is filled in by the caller.
+func toMessageResponse(message models.Message) MessageResponse {
+	response := MessageResponse{
 		ID:               message.ID,
 		Content:          message.Content,
 		Type:             message.Type,
@@ -334,30 +709,300 @@ func SendMessage(w http.ResponseWriter, r *http.Request) {
 			Name:           message.Sender.Name,
 			ProfilePicture: message.Sender.ProfilePicture,
 		},
-		IsRead:    message.IsRead,
 		CreatedAt: message.CreatedAt.Format("2006-01-02 15:04:05"),
+		Edited:    message.EditedAt != nil,
 	}
+	if message.EditedAt != nil {
+		editedAt := message.EditedAt.Format("2006-01-02 15:04:05")
+		response.EditedAt = &editedAt
+	}
+	if message.ReplyTo != nil {
+		response.ReplyTo = toQuotedMessage(*message.ReplyTo)
+	}
+	response.Attachments = toAttachmentInfos(message.Attachments)
+	return response
+}
 
-	// --- Broadcast the new message via Hub ---
-	hub, ok := r.Context().Value(utils.HubKey).(*websocket.Hub) // Use key from utils
-	if ok && hub != nil {
-		wsMsg := &websocket.WSMessage{
-			Type:    "newMessage",
-			Payload: responsePayload,
+// messagePreview is the text shown for a message in conversation lists and quotes
+func messagePreview(message models.Message) string {
+	if message.Content != "" || message.IsDeleted {
+		return message.Content
+	}
+	switch message.Type {
+	case "image":
+		return "📷 Photo"
+	case "file":
+		return "📎 File"
+	}
+	return message.Content
+}
+
+// toQuotedMessage builds a reply's preview of its parent (Sender preloaded)
+func toQuotedMessage(parent models.Message) *QuotedMessage {
+	content := []rune(messagePreview(parent))
+	if len(content) > quotePreviewLength {
+		content = append(content[:quotePreviewLength], '…')
+	}
+	return &QuotedMessage{
+		ID:         parent.ID,
+		SenderID:   parent.SenderID,
+		SenderName: parent.Sender.Name,
+		Content:    string(content),
+		Type:       parent.Type,
+		IsDeleted:  parent.IsDeleted,
+	}
+}
+
+// buildMessageResponses converts messages of one conversation for viewerID, with
+// read state, reactions and reply counts
+func buildMessageResponses(messages []models.Message, conversationID string, viewerID uint) []MessageResponse {
+	reads := conversationReadState(conversationID)
+
+	messageIDs := make([]uint, 0, len(messages))
+	for _, msg := range messages {
+		messageIDs = append(messageIDs, msg.ID)
+	}
+	reactions := messageReactions(messageIDs, viewerID)
+	replyCounts := messageReplyCounts(messageIDs)
+
+	var response []MessageResponse
+	for _, msg := range messages {
+		item := toMessageResponse(msg)
+		item.IsRead, item.ReadBy = messageReadStatus(msg, viewerID, reads)
+		item.Reactions = reactions[msg.ID]
+		item.ReplyCount = replyCounts[msg.ID]
+		response = append(response, item)
+	}
+	return response
+}
+
+// messageReplyCounts counts the live replies to each message
+func messageReplyCounts(messageIDs []uint) map[uint]int {
+	counts := make(map[uint]int)
+	if len(messageIDs) == 0 {
+		return counts
+	}
+
+	var rows []struct {
+		ReplyToID uint
+		Count     int
+	}
+	if err := db.DB.Model(&models.Message{}).
+		Select("reply_to_id, COUNT(*) AS count").
+		Where("reply_to_id IN ? AND is_deleted = ?", messageIDs, false).
+		Group("reply_to_id").
+		Scan(&rows).Error; err != nil {
+		log.Printf("Error counting replies: %v", err)
+		return counts
+	}
+
+	for _, row := range rows {
+		counts[row.ReplyToID] = row.Count
+	}
+	return counts
+}
+
+// GetThread returns a message and a page of its replies, oldest first (?before=, ?after=, ?limit=)
+func GetThread(w http.ResponseWriter, r *http.Request) {
+	claims, ok := utils.GetUserClaims(r)
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "User claims not found")
+		return
+	}
+
+	vars := mux.Vars(r)
+	messageID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid message ID")
+		return
+	}
+
+	var parent models.Message
+	result := db.DB.Preload("Sender").
+		Preload("ReplyTo.Sender").
+		Preload("Attachments", orderAttachments).
+		Where("id = ? AND college_id = ?", messageID, claims.CollegeID).
+		First(&parent)
+	if result.Error != nil {
+		respondWithError(w, http.StatusNotFound, "Message not found")
+		return
+	}
+	if !userHasAccessToConversation(claims.UserID, parent.ConversationID) {
+		respondWithError(w, http.StatusForbidden, "Access denied to this conversation")
+		return
+	}
+
+	// Replies page like conversation history (see GetMessages)
+	cursor, ok := parseMessageCursor(w, r)
+	if !ok {
+		return
+	}
+	replies, hasMore, err := cursor.page(db.DB.Where("reply_to_id = ? AND is_deleted = ?", parent.ID, false))
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to fetch replies")
+		return
+	}
+
+	// The parent stays visible (as deleted) so the thread keeps its context
+	parentResponse := buildMessageResponses([]models.Message{parent}, parent.ConversationID, claims.UserID)[0]
+	response := buildMessageResponses(replies, parent.ConversationID, claims.UserID)
+
+	page := map[string]interface{}{
+		"parent":     parentResponse,
+		"replyCount": parentResponse.ReplyCount, // All replies, not just this page
+		"replies":    response,
+	}
+	addPageCursors(page, replies, hasMore)
+	respondWithJSON(w, http.StatusOK, page)
+}
+
+// publishNewMessage pushes a stored message to the other participants
+func publishNewMessage(hub *websocket.Hub, message MessageResponse) {
+	hub.Publish(websocket.NewMessageEvent{
+		ConversationID: message.ConversationID,
+		SenderID:       message.Sender.ID,
+		Message:        message,
+	})
+}
+
+// MarkConversationRead records that the user has read a conversation up to a message
+// and tells the other participants
+func MarkConversationRead(w http.ResponseWriter, r *http.Request) {
+	claims, ok := utils.GetUserClaims(r)
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "User claims not found")
+		return
+	}
+
+	vars := mux.Vars(r)
+	conversationID := vars["conversationId"]
+
+	if !userHasAccessToConversation(claims.UserID, conversationID) {
+		respondWithError(w, http.StatusForbidden, "Access denied to this conversation")
+		return
+	}
+
+	var req MarkReadRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+			return
 		}
-		hub.BroadcastJSON(wsMsg)
-		log.Printf("DEBUG: Successfully retrieved Hub and broadcasting message for conv %s", responsePayload.ConversationID) // Success Log
-	} else {
-		log.Printf("Warning: Hub not found in context for SendMessage. Ok: %v, HubNil: %v", ok, hub == nil)
 	}
-	// --- End Broadcast ---
 
-	// Send HTTP response
-	respondWithJSON(w, http.StatusCreated, map[string]interface{}{
-		"message": responsePayload, // Send back the created message object
+	lastRead, advanced, err := markConversationRead(conversationID, claims.UserID, req.MessageID)
+	if err != nil {
+		log.Printf("Error marking conversation %s read for user %d: %v", conversationID, claims.UserID, err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to mark conversation as read")
+		return
+	}
+	if advanced {
+		hub, _ := r.Context().Value(utils.HubKey).(*websocket.Hub)
+		broadcastMessagesRead(hub, conversationID, claims.UserID, lastRead)
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"conversationId":    conversationID,
+		"lastReadMessageId": lastRead,
+		"unreadCount":       countUnread(conversationID, claims.UserID),
 	})
 }
 
+// countUnread counts messages from others after the user's read pointer
+func countUnread(conversationID string, userID uint) int64 {
+	var count int64
+	db.DB.Model(&models.Message{}).
+		Where("conversation_id = ? AND sender_id != ? AND is_deleted = ?", conversationID, userID, false).
+		Where("id > COALESCE((SELECT last_read_message_id FROM conversation_reads WHERE conversation_id = ? AND user_id = ?), 0)",
+			conversationID, userID).
+		Count(&count)
+	return count
+}
+
+// markConversationRead moves the user's read pointer forward to upToID (0 = latest message).
+// It never moves backwards. Returns the resulting pointer and whether it advanced.
+func markConversationRead(conversationID string, userID uint, upToID uint) (uint, bool, error) {
+	// Clamp to a message that actually exists in this conversation
+	var latestID uint
+	query := db.DB.Model(&models.Message{}).Where("conversation_id = ?", conversationID)
+	if upToID != 0 {
+		query = query.Where("id <= ?", upToID)
+	}
+	if err := query.Select("COALESCE(MAX(id), 0)").Scan(&latestID).Error; err != nil {
+		return 0, false, err
+	}
+
+	var current models.ConversationRead
+	db.DB.Where("conversation_id = ? AND user_id = ?", conversationID, userID).First(&current)
+	if latestID == 0 || current.LastReadMessageID >= latestID {
+		return current.LastReadMessageID, false, nil
+	}
+
+	// Upsert; GREATEST keeps a concurrent, further-ahead update from being undone
+	read := models.ConversationRead{
+		ConversationID:    conversationID,
+		UserID:            userID,
+		LastReadMessageID: latestID,
+		UpdatedAt:         time.Now(),
+	}
+	err := db.DB.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "conversation_id"}, {Name: "user_id"}},
+		DoUpdates: clause.Set{
+			{Column: clause.Column{Name: "last_read_message_id"}, Value: gorm.Expr("GREATEST(conversation_reads.last_read_message_id, EXCLUDED.last_read_message_id)")},
+			{Column: clause.Column{Name: "updated_at"}, Value: read.UpdatedAt},
+		},
+	}).Create(&read).Error
+	if err != nil {
+		return 0, false, err
+	}
+	if err := recountUnread(db.DB, conversationID, &userID); err != nil {
+		log.Printf("Error updating unread count for user %d in %s: %v", userID, conversationID, err)
+	}
+	return latestID, true, nil
+}
+
+// conversationReadState returns each member's read pointer for a conversation
+func conversationReadState(conversationID string) map[uint]uint {
+	var reads []models.ConversationRead
+	db.DB.Where("conversation_id = ?", conversationID).Find(&reads)
+
+	state := make(map[uint]uint, len(reads))
+	for _, read := range reads {
+		state[read.UserID] = read.LastReadMessageID
+	}
+	return state
+}
+
+// messageReadStatus works out IsRead/ReadBy for one message as seen by viewerID
+func messageReadStatus(msg models.Message, viewerID uint, reads map[uint]uint) (bool, []uint) {
+	if msg.SenderID != viewerID {
+		return reads[viewerID] >= msg.ID, nil
+	}
+
+	var readBy []uint
+	for userID, lastRead := range reads {
+		if userID != viewerID && lastRead >= msg.ID {
+			readBy = append(readBy, userID)
+		}
+	}
+	sort.Slice(readBy, func(i, j int) bool { return readBy[i] < readBy[j] })
+	return len(readBy) > 0, readBy
+}
+
+// broadcastMessagesRead tells the other participants how far userID has read
+func broadcastMessagesRead(hub *websocket.Hub, conversationID string, userID uint, lastRead uint) {
+	if hub != nil {
+		hub.Publish(websocket.MessagesReadEvent{
+			ConversationID:    conversationID,
+			UserID:            userID,
+			LastReadMessageID: lastRead,
+			ReadAt:            time.Now().Format("2006-01-02 15:04:05"),
+		})
+	} else {
+		log.Printf("Warning: Hub not available for messagesRead (conversation %s)", conversationID)
+	}
+}
+
 // ... (Keep DeleteMessage, userHasAccessToConversation functions) ...
 // DeleteMessage allows user to delete their own message
 func DeleteMessage(w http.ResponseWriter, r *http.Request) {
@@ -386,27 +1031,216 @@ func DeleteMessage(w http.ResponseWriter, r *http.Request) {
 	message.IsDeleted = true
 	message.Content = "This message was deleted." // Optionally clear/replace content
 	message.UpdatedAt = time.Now()                // Update timestamp
-	if err := db.DB.Save(&message).Error; err != nil {
+
+	var attachments []models.MessageAttachment
+	db.DB.Where("message_id = ?", message.ID).Find(&attachments)
+
+	// Earlier versions and attached files go too, so deleted content can't be recovered
+	tx := db.DB.Begin()
+	if err := tx.Save(&message).Error; err != nil {
+		tx.Rollback()
 		log.Printf("Error soft deleting message %d: %v", messageID, err)
 		respondWithError(w, http.StatusInternalServerError, "Failed to delete message")
 		return
 	}
+	if err := tx.Where("message_id = ?", message.ID).Delete(&models.MessageEdit{}).Error; err != nil {
+		tx.Rollback()
+		log.Printf("Error deleting edit history of message %d: %v", messageID, err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to delete message")
+		return
+	}
+	if err := tx.Where("message_id = ?", message.ID).Delete(&models.MessageAttachment{}).Error; err != nil {
+		tx.Rollback()
+		log.Printf("Error deleting attachments of message %d: %v", messageID, err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to delete message")
+		return
+	}
+	if err := recountUnread(tx, message.ConversationID, nil); err != nil {
+		tx.Rollback()
+		log.Printf("Error updating unread counts for %s: %v", message.ConversationID, err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to delete message")
+		return
+	}
+	if err := refreshLastMessage(tx, message.ConversationID); err != nil {
+		tx.Rollback()
+		log.Printf("Error updating last message for %s: %v", message.ConversationID, err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to delete message")
+		return
+	}
+	if err := tx.Commit().Error; err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to delete message")
+		return
+	}
+	deleteAttachmentFiles(attachments)
 
-	// TODO: Optionally broadcast a "messageDeleted" event via WebSocket
-	// hub, ok := r.Context().Value(utils.HubKey).(*websocket.Hub)
-	// if ok && hub != nil {
-	//  wsMsg := &websocket.WSMessage{
-	//      Type: "messageDeleted",
-	//      Payload: map[string]interface{}{"messageId": messageID, "conversationId": message.ConversationID},
-	//  }
-	//  hub.BroadcastJSON(wsMsg) // Need targeting logic here too
-	// }
+	// Let open chats drop the message
+	hub, hubOk := r.Context().Value(utils.HubKey).(*websocket.Hub)
+	if hubOk && hub != nil {
+		hub.Publish(websocket.MessageDeletedEvent{
+			MessageID:      message.ID,
+			ConversationID: message.ConversationID,
+			SenderID:       message.SenderID,
+		})
+	} else {
+		log.Printf("Warning: Hub not found in context for DeleteMessage. HubOk: %v, HubNil: %v", hubOk, hub == nil)
+	}
 
 	respondWithJSON(w, http.StatusOK, map[string]string{
 		"message": "Message deleted successfully",
 	})
 }
 
+// messageEditWindow is how long after sending a message can be edited (MESSAGE_EDIT_WINDOW, default 15m)
+func messageEditWindow() time.Duration {
+	if d, err := time.ParseDuration(os.Getenv("MESSAGE_EDIT_WINDOW")); err == nil && d > 0 {
+		return d
+	}
+	return defaultMessageEditWindow
+}
+
+// EditMessage lets the sender change a message's text within the edit window.
+// The previous text is kept in the edit history.
+func EditMessage(w http.ResponseWriter, r *http.Request) {
+	claims, ok := utils.GetUserClaims(r)
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "User claims not found")
+		return
+	}
+
+	vars := mux.Vars(r)
+	messageID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid message ID")
+		return
+	}
+
+	var req EditMessageRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	req.Content = strings.TrimSpace(req.Content)
+	if req.Content == "" {
+		respondWithError(w, http.StatusBadRequest, "Message content is required")
+		return
+	}
+
+	var message models.Message
+	result := db.DB.Preload("Sender").
+		Preload("ReplyTo.Sender").
+		Preload("Attachments", orderAttachments).
+		Where("id = ? AND sender_id = ? AND is_deleted = ?", messageID, claims.UserID, false).
+		First(&message)
+	if result.Error != nil {
+		respondWithError(w, http.StatusNotFound, "Message not found or already deleted")
+		return
+	}
+
+	if window := messageEditWindow(); time.Since(message.CreatedAt) > window {
+		respondWithError(w, http.StatusForbidden, fmt.Sprintf("Messages can only be edited within %v of sending", window))
+		return
+	}
+	if req.Content == message.Content {
+		respondWithJSON(w, http.StatusOK, map[string]interface{}{
+			"message": toMessageResponse(message),
+		})
+		return
+	}
+
+	now := time.Now()
+	tx := db.DB.Begin()
+	if err := tx.Create(&models.MessageEdit{
+		MessageID: message.ID,
+		Content:   message.Content,
+		EditedBy:  claims.UserID,
+		CreatedAt: now,
+	}).Error; err != nil {
+		tx.Rollback()
+		log.Printf("Error saving edit history for message %d: %v", message.ID, err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to edit message")
+		return
+	}
+	// Only update if it wasn't deleted in the meantime
+	update := tx.Model(&models.Message{}).
+		Where("id = ? AND is_deleted = ?", message.ID, false).
+		Updates(map[string]interface{}{
+			"content":    req.Content,
+			"edited_at":  now,
+			"updated_at": now,
+		})
+	if update.Error != nil || update.RowsAffected == 0 {
+		tx.Rollback()
+		if update.Error != nil {
+			log.Printf("Error editing message %d: %v", message.ID, update.Error)
+			respondWithError(w, http.StatusInternalServerError, "Failed to edit message")
+		} else {
+			respondWithError(w, http.StatusNotFound, "Message not found or already deleted")
+		}
+		return
+	}
+	if err := tx.Commit().Error; err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to edit message")
+		return
+	}
+
+	message.Content = req.Content
+	message.EditedAt = &now
+	response := toMessageResponse(message)
+
+	// --- Update open chats ---
+	hub, hubOk := r.Context().Value(utils.HubKey).(*websocket.Hub)
+	if hubOk && hub != nil {
+		hub.Publish(websocket.MessageEditedEvent{
+			MessageID:      message.ID,
+			ConversationID: message.ConversationID,
+			SenderID:       message.SenderID,
+			Message:        response,
+		})
+	} else {
+		log.Printf("Warning: Hub not found in context for EditMessage. HubOk: %v, HubNil: %v", hubOk, hub == nil)
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"message": response,
+	})
+}
+
+// GetMessageEdits returns a message's earlier versions, oldest first, to conversation participants
+func GetMessageEdits(w http.ResponseWriter, r *http.Request) {
+	claims, ok := utils.GetUserClaims(r)
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "User claims not found")
+		return
+	}
+
+	vars := mux.Vars(r)
+	messageID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid message ID")
+		return
+	}
+
+	var message models.Message
+	result := db.DB.Where("id = ? AND college_id = ? AND is_deleted = ?", messageID, claims.CollegeID, false).First(&message)
+	if result.Error != nil || !userHasAccessToConversation(claims.UserID, message.ConversationID) {
+		respondWithError(w, http.StatusNotFound, "Message not found")
+		return
+	}
+
+	var edits []models.MessageEdit
+	if err := db.DB.Where("message_id = ?", message.ID).Order("created_at ASC, id ASC").Find(&edits).Error; err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to fetch edit history")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"messageId": message.ID,
+		"content":   message.Content,
+		"total":     len(edits),
+		"edits":     edits,
+	})
+}
+
 // userHasAccessToConversation checks if user can access a conversation
 func userHasAccessToConversation(userID uint, conversationID string) bool {
 	if strings.HasPrefix(conversationID, "dm_") {
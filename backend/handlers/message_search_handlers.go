@@ -0,0 +1,205 @@
+package handlers
+
+import (
+	"fmt"
+	"html"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"unilink-backend/db"
+	"unilink-backend/models"
+	"unilink-backend/utils"
+)
+
+// messageSearchExpr is the tsvector expression backed by idx_messages_search (see db.createIndexes)
+const messageSearchExpr = "to_tsvector('english', content)"
+
+// Search page sizes
+const (
+	defaultMessageSearchSize = 20
+	maxMessageSearchSize     = 50
+)
+
+// ts_headline wraps matches in these control characters; the snippet is HTML-escaped
+// afterwards and they become <mark> tags, so message text can never inject markup
+const (
+	highlightStart = "\x02"
+	highlightStop  = "\x03"
+)
+
+// MessageSearchResult is one matching message with what the client needs to open it
+type MessageSearchResult struct {
+	MessageID        uint              `json:"messageId"`
+	ConversationID   string            `json:"conversationId"`
+	ConversationType string            `json:"conversationType"`
+	ConversationName string            `json:"conversationName"` // Friend name or group name
+	Sender           MessageSenderData `json:"sender"`
+	Snippet          string            `json:"snippet"` // HTML-escaped, matches wrapped in <mark>
+	CreatedAt        string            `json:"createdAt"`
+	// Open the conversation with GET /conversations/{conversationId}/messages?before={before}
+	// to get the page ending at this message
+	Before uint `json:"before"`
+}
+
+// SearchMessages runs a full-text search over messages in conversations the user can
+// currently access (?q=, optional conversationId, limit, offset). Best matches first.
+func SearchMessages(w http.ResponseWriter, r *http.Request) {
+	claims, ok := utils.GetUserClaims(r)
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "User claims not found")
+		return
+	}
+
+	params := r.URL.Query()
+	q := strings.TrimSpace(params.Get("q"))
+	if q == "" {
+		respondWithError(w, http.StatusBadRequest, "Search query is required")
+		return
+	}
+	limit := messagePageLimit(params.Get("limit"), defaultMessageSearchSize, maxMessageSearchSize)
+	offset := 0
+	if v := params.Get("offset"); v != "" {
+		o, err := strconv.Atoi(v)
+		if err != nil || o < 0 {
+			respondWithError(w, http.StatusBadRequest, "Invalid offset")
+			return
+		}
+		offset = o
+	}
+
+	// Only conversations the user is in right now (not ones they have left)
+	conversationIDs := accessibleConversationIDs(claims.UserID)
+	if v := params.Get("conversationId"); v != "" {
+		allowed := false
+		for _, id := range conversationIDs {
+			if id == v {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			respondWithError(w, http.StatusForbidden, "Access denied to this conversation")
+			return
+		}
+		conversationIDs = []string{v}
+	}
+	if len(conversationIDs) == 0 {
+		respondWithJSON(w, http.StatusOK, map[string]interface{}{
+			"total":   0,
+			"results": []MessageSearchResult{},
+		})
+		return
+	}
+
+	var rows []struct {
+		models.Message
+		Snippet string
+		Rank    float64
+	}
+	// Rank and paginate first: ts_headline re-parses the whole message, so it only runs
+	// for the page. is_deleted is compared to a literal so idx_messages_search (a partial
+	// index) applies.
+	page := db.DB.Model(&models.Message{}).
+		Select("messages.*, ts_rank("+messageSearchExpr+", websearch_to_tsquery('english', ?)) AS rank", q).
+		Where("conversation_id IN ? AND is_deleted = false", conversationIDs).
+		Where(messageSearchExpr+" @@ websearch_to_tsquery('english', ?)", q).
+		Order("rank DESC, id DESC").
+		Limit(limit).
+		Offset(offset)
+
+	headlineOptions := "StartSel=" + highlightStart + ", StopSel=" + highlightStop + ", MaxFragments=2, MaxWords=20, MinWords=5"
+	err := db.DB.Table("(?) AS page", page).
+		Select("page.*, ts_headline('english', page.content, websearch_to_tsquery('english', ?), ?) AS snippet", q, headlineOptions).
+		Order("page.rank DESC, page.id DESC").
+		Scan(&rows).Error
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to search messages")
+		return
+	}
+
+	// Load senders and conversation names in bulk
+	senderIDs := make([]uint, 0, len(rows))
+	hitConversations := make([]string, 0, len(rows))
+	for _, row := range rows {
+		senderIDs = append(senderIDs, row.SenderID)
+		hitConversations = append(hitConversations, row.ConversationID)
+	}
+	names := conversationNames(claims.UserID, hitConversations)
+	senders := make(map[uint]models.User)
+	if len(senderIDs) > 0 {
+		var users []models.User
+		db.DB.Where("id IN ?", senderIDs).Find(&users)
+		for _, user := range users {
+			senders[user.ID] = user
+		}
+	}
+
+	results := make([]MessageSearchResult, 0, len(rows))
+	for _, row := range rows {
+		sender := senders[row.SenderID]
+		results = append(results, MessageSearchResult{
+			MessageID:        row.ID,
+			ConversationID:   row.ConversationID,
+			ConversationType: row.ConversationType,
+			ConversationName: names[row.ConversationID],
+			Sender: MessageSenderData{
+				ID:             sender.ID,
+				Name:           sender.Name,
+				ProfilePicture: sender.ProfilePicture,
+			},
+			Snippet:   highlightSnippet(row.Snippet),
+			CreatedAt: row.CreatedAt.Format("2006-01-02 15:04:05"),
+			Before:    row.ID + 1,
+		})
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"total":   len(results), // Results in this page
+		"results": results,
+	})
+}
+
+// highlightSnippet escapes a ts_headline snippet and turns the highlight markers into <mark> tags
+func highlightSnippet(snippet string) string {
+	escaped := html.EscapeString(snippet)
+	escaped = strings.ReplaceAll(escaped, highlightStart, "<mark>")
+	return strings.ReplaceAll(escaped, highlightStop, "</mark>")
+}
+
+// conversationNames maps conversation IDs to what the user sees as their name:
+// the other person's name for DMs, the group name for groups
+func conversationNames(userID uint, conversationIDs []string) map[string]string {
+	names := make(map[string]string, len(conversationIDs))
+
+	var friendIDs, groupIDs []uint
+	for _, id := range conversationIDs {
+		var a, b uint
+		if n, _ := fmt.Sscanf(id, "dm_%d_%d", &a, &b); n == 2 {
+			if a == userID {
+				friendIDs = append(friendIDs, b)
+			} else {
+				friendIDs = append(friendIDs, a)
+			}
+		} else if n, _ := fmt.Sscanf(id, "group_%d", &a); n == 1 {
+			groupIDs = append(groupIDs, a)
+		}
+	}
+
+	if len(friendIDs) > 0 {
+		var users []models.User
+		db.DB.Where("id IN ?", friendIDs).Find(&users)
+		for _, user := range users {
+			names[dmConversationID(userID, user.ID)] = user.Name
+		}
+	}
+	if len(groupIDs) > 0 {
+		var groups []models.Group
+		db.DB.Where("id IN ?", groupIDs).Find(&groups)
+		for _, group := range groups {
+			names[fmt.Sprintf("group_%d", group.ID)] = group.Name
+		}
+	}
+
+	return names
+}
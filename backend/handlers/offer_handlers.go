@@ -0,0 +1,488 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"unilink-backend/db"
+	"unilink-backend/models"
+	"unilink-backend/utils"
+	"unilink-backend/websocket"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+// OfferRequest is the payload for making or countering an offer
+type OfferRequest struct {
+	Amount  float64 `json:"amount"`
+	Message string  `json:"message"`
+}
+
+func toOfferResponse(offer models.ListingOffer) OfferResponse {
+	return OfferResponse{
+		ID:         offer.ID,
+		ListingID:  offer.ListingID,
+		Title:      offer.Listing.Title,
+		ListPrice:  offer.Listing.Price,
+		Buyer:      toSellerInfo(offer.Buyer),
+		SellerID:   offer.SellerID,
+		Amount:     offer.Amount,
+		ProposedBy: offer.ProposedBy,
+		Message:    offer.Message,
+		Status:     offer.Status,
+		CreatedAt:  offer.CreatedAt.Format("2006-01-02 15:04:05"),
+		UpdatedAt:  offer.UpdatedAt.Format("2006-01-02 15:04:05"),
+	}
+}
+
+// CreateOffer lets a buyer propose a price for an available listing
+func CreateOffer(w http.ResponseWriter, r *http.Request) {
+	claims, ok := utils.GetUserClaims(r)
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "User claims not found")
+		return
+	}
+
+	vars := mux.Vars(r)
+	listingID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid listing ID")
+		return
+	}
+
+	var req OfferRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	if req.Amount <= 0 {
+		respondWithError(w, http.StatusBadRequest, "Offer amount must be greater than zero")
+		return
+	}
+
+	var listing models.MarketplaceListing
+	result := db.DB.Where("id = ? AND college_id = ?", listingID, claims.CollegeID).First(&listing)
+	if result.Error != nil {
+		respondWithError(w, http.StatusNotFound, "Listing not found or access denied")
+		return
+	}
+
+	if listing.SellerID == claims.UserID {
+		respondWithError(w, http.StatusBadRequest, "You cannot make an offer on your own listing")
+		return
+	}
+	if listing.Status != "available" {
+		respondWithError(w, http.StatusConflict, "Listing is no longer available")
+		return
+	}
+
+	// One open negotiation per buyer per listing
+	var existing models.ListingOffer
+	db.DB.Where("listing_id = ? AND buyer_id = ? AND status = ?", listing.ID, claims.UserID, "pending").First(&existing)
+	if existing.ID != 0 {
+		respondWithError(w, http.StatusConflict, "You already have an open offer on this listing")
+		return
+	}
+
+	offer := models.ListingOffer{
+		ListingID:  listing.ID,
+		BuyerID:    claims.UserID,
+		SellerID:   listing.SellerID,
+		Amount:     req.Amount,
+		ProposedBy: claims.UserID,
+		Message:    req.Message,
+		Status:     "pending",
+	}
+	if err := db.DB.Create(&offer).Error; err != nil {
+		log.Printf("Error creating offer on listing %d: %v", listing.ID, err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to create offer")
+		return
+	}
+
+	db.DB.Preload("Listing").Preload("Buyer").First(&offer, offer.ID)
+	response := toOfferResponse(offer)
+
+	// --- Notify the seller ---
+	hub, hubOk := r.Context().Value(utils.HubKey).(*websocket.Hub)
+	if hubOk && hub != nil {
+		hub.Publish(websocket.NewOfferEvent{
+			RecipientID: offer.SellerID,
+			Offer:       response,
+		})
+	} else {
+		log.Printf("Warning: Hub not found in context for CreateOffer. HubOk: %v, HubNil: %v", hubOk, hub == nil)
+	}
+
+	respondWithJSON(w, http.StatusCreated, response)
+}
+
+// GetListingOffers returns the offers on a listing. The seller sees every offer,
+// anyone else only sees their own.
+func GetListingOffers(w http.ResponseWriter, r *http.Request) {
+	claims, ok := utils.GetUserClaims(r)
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "User claims not found")
+		return
+	}
+
+	vars := mux.Vars(r)
+	listingID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid listing ID")
+		return
+	}
+
+	var listing models.MarketplaceListing
+	result := db.DB.Where("id = ? AND college_id = ?", listingID, claims.CollegeID).First(&listing)
+	if result.Error != nil {
+		respondWithError(w, http.StatusNotFound, "Listing not found or access denied")
+		return
+	}
+
+	query := db.DB.Preload("Listing").Preload("Buyer").Where("listing_id = ?", listing.ID)
+	if listing.SellerID != claims.UserID {
+		query = query.Where("buyer_id = ?", claims.UserID)
+	}
+
+	var offers []models.ListingOffer
+	if err := query.Order("updated_at DESC").Find(&offers).Error; err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to fetch offers")
+		return
+	}
+
+	response := make([]OfferResponse, 0, len(offers))
+	for _, offer := range offers {
+		response = append(response, toOfferResponse(offer))
+	}
+
+	respondWithJSON(w, http.StatusOK, response)
+}
+
+// GetMyOffers returns offers the user made (?role=buyer, default) or received (?role=seller)
+func GetMyOffers(w http.ResponseWriter, r *http.Request) {
+	claims, ok := utils.GetUserClaims(r)
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "User claims not found")
+		return
+	}
+
+	query := db.DB.Preload("Listing").Preload("Buyer")
+	switch r.URL.Query().Get("role") {
+	case "", "buyer":
+		query = query.Where("buyer_id = ?", claims.UserID)
+	case "seller":
+		query = query.Where("seller_id = ?", claims.UserID)
+	default:
+		respondWithError(w, http.StatusBadRequest, "Role must be 'buyer' or 'seller'")
+		return
+	}
+	if status := r.URL.Query().Get("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var offers []models.ListingOffer
+	if err := query.Order("updated_at DESC").Find(&offers).Error; err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to fetch offers")
+		return
+	}
+
+	response := make([]OfferResponse, 0, len(offers))
+	for _, offer := range offers {
+		response = append(response, toOfferResponse(offer))
+	}
+
+	respondWithJSON(w, http.StatusOK, response)
+}
+
+// CounterOffer replaces the current proposal with a new amount.
+// Only the party who did not make the current proposal can counter.
+func CounterOffer(w http.ResponseWriter, r *http.Request) {
+	claims, ok := utils.GetUserClaims(r)
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "User claims not found")
+		return
+	}
+
+	offer, ok := findOpenOffer(w, r, claims.UserID)
+	if !ok {
+		return
+	}
+	if offer.ProposedBy == claims.UserID {
+		respondWithError(w, http.StatusConflict, "Waiting for the other party to respond")
+		return
+	}
+
+	var req OfferRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	if req.Amount <= 0 {
+		respondWithError(w, http.StatusBadRequest, "Offer amount must be greater than zero")
+		return
+	}
+
+	// Conditional update so a concurrent accept/reject can't be overwritten
+	result := db.DB.Model(&models.ListingOffer{}).
+		Where("id = ? AND status = ? AND proposed_by = ?", offer.ID, "pending", offer.ProposedBy).
+		Updates(map[string]interface{}{
+			"amount":      req.Amount,
+			"proposed_by": claims.UserID,
+			"message":     req.Message,
+		})
+	if result.Error != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to counter offer")
+		return
+	}
+	if result.RowsAffected == 0 {
+		respondWithError(w, http.StatusConflict, "Offer was updated by the other party, please refresh")
+		return
+	}
+
+	db.DB.Preload("Listing").Preload("Buyer").First(offer, offer.ID)
+	response := toOfferResponse(*offer)
+	notifyOfferUpdate(r, "CounterOffer", offer, claims.UserID, "countered", response)
+
+	respondWithJSON(w, http.StatusOK, response)
+}
+
+// RejectOffer declines the other party's current proposal and closes the negotiation
+func RejectOffer(w http.ResponseWriter, r *http.Request) {
+	claims, ok := utils.GetUserClaims(r)
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "User claims not found")
+		return
+	}
+
+	offer, ok := findOpenOffer(w, r, claims.UserID)
+	if !ok {
+		return
+	}
+	if offer.ProposedBy == claims.UserID {
+		respondWithError(w, http.StatusConflict, "You cannot reject your own proposal. Withdraw the offer instead.")
+		return
+	}
+
+	closeOffer(w, r, offer, claims.UserID, "rejected", "RejectOffer")
+}
+
+// WithdrawOffer lets the buyer walk away from an open negotiation
+func WithdrawOffer(w http.ResponseWriter, r *http.Request) {
+	claims, ok := utils.GetUserClaims(r)
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "User claims not found")
+		return
+	}
+
+	offer, ok := findOpenOffer(w, r, claims.UserID)
+	if !ok {
+		return
+	}
+	if offer.BuyerID != claims.UserID {
+		respondWithError(w, http.StatusForbidden, "Only the buyer can withdraw an offer")
+		return
+	}
+
+	closeOffer(w, r, offer, claims.UserID, "withdrawn", "WithdrawOffer")
+}
+
+// AcceptOffer accepts the other party's current proposal and reserves the listing
+// for the buyer at that price. Other buyers' open offers on the listing are closed
+// in the same transaction.
+func AcceptOffer(w http.ResponseWriter, r *http.Request) {
+	claims, ok := utils.GetUserClaims(r)
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "User claims not found")
+		return
+	}
+
+	offer, ok := findOpenOffer(w, r, claims.UserID)
+	if !ok {
+		return
+	}
+	if offer.ProposedBy == claims.UserID {
+		respondWithError(w, http.StatusConflict, "Waiting for the other party to respond")
+		return
+	}
+
+	tx := db.DB.Begin()
+	if tx.Error != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	// Close the offer first; fails if the other party countered or withdrew meanwhile
+	offerUpdate := tx.Model(&models.ListingOffer{}).
+		Where("id = ? AND status = ? AND proposed_by = ?", offer.ID, "pending", offer.ProposedBy).
+		Update("status", "accepted")
+	if offerUpdate.Error != nil {
+		tx.Rollback()
+		respondWithError(w, http.StatusInternalServerError, "Failed to accept offer")
+		return
+	}
+	if offerUpdate.RowsAffected == 0 {
+		tx.Rollback()
+		respondWithError(w, http.StatusConflict, "Offer was updated by the other party, please refresh")
+		return
+	}
+
+	// Reserve the listing only if it is still available
+	expiryTime := time.Now().Add(reservationWindow(offer.Listing.CollegeID))
+	listingUpdate := tx.Model(&models.MarketplaceListing{}).
+		Where("id = ? AND status = ?", offer.ListingID, "available").
+		Updates(map[string]interface{}{
+			"status":         "reserved",
+			"buyer_id":       offer.BuyerID,
+			"reserved_until": expiryTime,
+			"agreed_price":   offer.Amount,
+		})
+	if listingUpdate.Error != nil {
+		tx.Rollback()
+		respondWithError(w, http.StatusInternalServerError, "Failed to reserve listing")
+		return
+	}
+	if listingUpdate.RowsAffected == 0 {
+		tx.Rollback()
+		respondWithError(w, http.StatusConflict, "Listing is no longer available for reservation")
+		return
+	}
+
+	// The listing is taken, so every other open negotiation on it ends too
+	competing, err := closeOpenOffers(tx, offer.ListingID, offer.ID)
+	if err != nil {
+		tx.Rollback()
+		respondWithError(w, http.StatusInternalServerError, "Failed to close other offers")
+		return
+	}
+
+	ensureChatFriendship(tx, offer.ListingID, offer.BuyerID, offer.SellerID, offer.Listing.CollegeID)
+
+	if err := tx.Commit().Error; err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to finalize reservation")
+		return
+	}
+
+	log.Printf("Offer %d accepted: Listing %d reserved for Buyer %d at %.2f (%d other offers closed)", offer.ID, offer.ListingID, offer.BuyerID, offer.Amount, len(competing))
+
+	db.DB.Preload("Listing").Preload("Buyer").First(offer, offer.ID)
+	response := toOfferResponse(*offer)
+	notifyOfferUpdate(r, "AcceptOffer", offer, claims.UserID, "accepted", response)
+
+	notifyOffersClosed(r, "AcceptOffer", competing)
+
+	var listing models.MarketplaceListing
+	db.DB.Preload("Seller").Preload("Category").Preload("Images", orderImages).Preload("Buyer").First(&listing, offer.ListingID)
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"message":        "Offer accepted. The listing is now reserved.",
+		"offer":          response,
+		"listing":        toListingResponse(listing),
+		"conversationId": dmConversationID(offer.BuyerID, offer.SellerID),
+	})
+}
+
+// closeOpenOffers closes the pending offers on a listing that is no longer available,
+// except keepID (0 keeps none). It returns the closed offers so the buyers can be
+// notified with notifyOffersClosed once the transaction commits.
+func closeOpenOffers(tx *gorm.DB, listingID uint, keepID uint) ([]models.ListingOffer, error) {
+	var open []models.ListingOffer
+	if err := tx.Where("listing_id = ? AND id <> ? AND status = ?", listingID, keepID, "pending").
+		Find(&open).Error; err != nil {
+		return nil, err
+	}
+	if len(open) == 0 {
+		return nil, nil
+	}
+
+	openIDs := make([]uint, len(open))
+	for i, offer := range open {
+		openIDs[i] = offer.ID
+	}
+	if err := tx.Model(&models.ListingOffer{}).
+		Where("id IN ? AND status = ?", openIDs, "pending").
+		Update("status", "closed").Error; err != nil {
+		return nil, err
+	}
+	return open, nil
+}
+
+// notifyOffersClosed tells each buyer whose offer closeOpenOffers closed that the
+// negotiation is over
+func notifyOffersClosed(r *http.Request, handlerName string, closed []models.ListingOffer) {
+	for i := range closed {
+		offer := &closed[i]
+		// The listing may have just been deleted
+		db.DB.Preload("Listing", func(tx *gorm.DB) *gorm.DB { return tx.Unscoped() }).
+			Preload("Buyer").First(offer, offer.ID)
+		notifyOfferUpdate(r, handlerName, offer, offer.SellerID, "closed", toOfferResponse(*offer))
+	}
+}
+
+// findOpenOffer loads the {id} offer if the user is its buyer or seller and it is still pending
+func findOpenOffer(w http.ResponseWriter, r *http.Request, userID uint) (*models.ListingOffer, bool) {
+	vars := mux.Vars(r)
+	offerID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid offer ID")
+		return nil, false
+	}
+
+	var offer models.ListingOffer
+	result := db.DB.Preload("Listing").
+		Where("id = ? AND (buyer_id = ? OR seller_id = ?)", offerID, userID, userID).
+		First(&offer)
+	if result.Error != nil {
+		respondWithError(w, http.StatusNotFound, "Offer not found")
+		return nil, false
+	}
+
+	if offer.Status != "pending" {
+		respondWithError(w, http.StatusConflict, "Offer is already "+offer.Status)
+		return nil, false
+	}
+	return &offer, true
+}
+
+// closeOffer ends a pending negotiation with the given status and notifies the other party
+func closeOffer(w http.ResponseWriter, r *http.Request, offer *models.ListingOffer, actorID uint, status string, handlerName string) {
+	result := db.DB.Model(&models.ListingOffer{}).
+		Where("id = ? AND status = ?", offer.ID, "pending").
+		Update("status", status)
+	if result.Error != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to update offer")
+		return
+	}
+	if result.RowsAffected == 0 {
+		respondWithError(w, http.StatusConflict, "Offer is no longer open")
+		return
+	}
+
+	db.DB.Preload("Listing").Preload("Buyer").First(offer, offer.ID)
+	response := toOfferResponse(*offer)
+	notifyOfferUpdate(r, handlerName, offer, actorID, status, response)
+
+	respondWithJSON(w, http.StatusOK, response)
+}
+
+// notifyOfferUpdate tells the party who did not act that the offer changed
+func notifyOfferUpdate(r *http.Request, handlerName string, offer *models.ListingOffer, actorID uint, action string, response OfferResponse) {
+	recipientID := offer.SellerID
+	if actorID == offer.SellerID {
+		recipientID = offer.BuyerID
+	}
+
+	hub, hubOk := r.Context().Value(utils.HubKey).(*websocket.Hub)
+	if hubOk && hub != nil {
+		hub.Publish(websocket.OfferUpdateEvent{
+			RecipientID: recipientID,
+			Action:      action, // "countered", "accepted", "rejected", "withdrawn", "closed"
+			Offer:       response,
+		})
+	} else {
+		log.Printf("Warning: Hub not found in context for %s. HubOk: %v, HubNil: %v", handlerName, hubOk, hub == nil)
+	}
+}
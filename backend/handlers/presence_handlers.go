@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"unilink-backend/db"
+	"unilink-backend/models"
+	"unilink-backend/utils"
+	"unilink-backend/websocket"
+)
+
+// maxPresenceLookup caps how many users can be looked up at once
+const maxPresenceLookup = 100
+
+// GetPresence returns online/away/offline for users in the caller's college (?userIds=1,2,3).
+// Users with a private profile or presence turned off are always reported offline, as are
+// users outside the caller's presence audience (friends and shared group members).
+func GetPresence(w http.ResponseWriter, r *http.Request) {
+	claims, ok := utils.GetUserClaims(r)
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "User claims not found")
+		return
+	}
+
+	var userIDs []uint
+	for _, part := range strings.Split(r.URL.Query().Get("userIds"), ",") {
+		if part = strings.TrimSpace(part); part == "" {
+			continue
+		}
+		id, err := strconv.Atoi(part)
+		if err != nil || id <= 0 {
+			respondWithError(w, http.StatusBadRequest, "Invalid userIds")
+			return
+		}
+		userIDs = append(userIDs, uint(id))
+	}
+	if len(userIDs) == 0 {
+		respondWithError(w, http.StatusBadRequest, "userIds is required")
+		return
+	}
+	if len(userIDs) > maxPresenceLookup {
+		respondWithError(w, http.StatusBadRequest, "At most 100 users can be looked up at once")
+		return
+	}
+
+	hub, hubOk := r.Context().Value(utils.HubKey).(*websocket.Hub)
+	if !hubOk || hub == nil {
+		respondWithError(w, http.StatusInternalServerError, "Presence is unavailable")
+		return
+	}
+
+	// College isolation
+	var users []models.User
+	if err := db.DB.Where("id IN ? AND college_id = ?", userIDs, claims.CollegeID).Find(&users).Error; err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to fetch presence")
+		return
+	}
+
+	// The relationship is mutual, so whoever sees the caller's presence is who the caller may see
+	audience := websocket.PresenceAudience(claims.UserID)
+
+	visibleIDs := make([]uint, 0, len(users))
+	for _, user := range users {
+		if user.ID == claims.UserID || audience[user.ID] {
+			visibleIDs = append(visibleIDs, user.ID)
+		}
+	}
+	live := hub.PresenceOfUsers(visibleIDs)
+
+	presence := make([]websocket.PresenceInfo, 0, len(users))
+	for i := range users {
+		status, visible := live[users[i].ID]
+		if !visible {
+			presence = append(presence, websocket.PresenceInfo{UserID: users[i].ID, Status: websocket.PresenceOffline})
+			continue
+		}
+		presence = append(presence, websocket.VisiblePresence(&users[i], status))
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"presence": presence,
+	})
+}
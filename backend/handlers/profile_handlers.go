@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"encoding/json"
+	"log"
 	"net/http"
 	"strconv"
 	"strings"
@@ -9,6 +10,7 @@ import (
 	"unilink-backend/db"
 	"unilink-backend/models"
 	"unilink-backend/utils"
+	"unilink-backend/websocket"
 
 	"github.com/gorilla/mux"
 )
@@ -28,13 +30,19 @@ type ProfileResponse struct {
 	IsPublic         bool   `json:"isPublic"`
 	CreatedAt        string `json:"createdAt"`
 	FriendshipStatus string `json:"friendshipStatus"`
+	// Marketplace reputation
+	Rating      float64 `json:"rating"`
+	RatingCount int     `json:"ratingCount"`
+	// Presence privacy (own profile only)
+	ShowPresence *bool `json:"showPresence,omitempty"`
 }
 
 // UpdateProfileRequest is the payload for updating profile
 type UpdateProfileRequest struct {
-	ProfilePicture string `json:"profilePicture"`
-	Bio            string `json:"bio"`
-	IsPublic       bool   `json:"isPublic"`
+	ProfilePictureID *uint  `json:"profilePictureId"` // Optional; upload ID from POST /api/uploads (purpose "profile"), 0 removes the picture
+	Bio              string `json:"bio"`
+	IsPublic         bool   `json:"isPublic"`
+	ShowPresence     *bool  `json:"showPresence"` // Optional; false = always appear offline
 }
 
 // GetMyProfile returns the authenticated user's profile
@@ -66,6 +74,9 @@ func GetMyProfile(w http.ResponseWriter, r *http.Request) {
 		CollegeName:    user.College.Name,
 		IsPublic:       user.IsPublic,
 		CreatedAt:      user.CreatedAt.Format("2006-01-02 15:04:05"),
+		Rating:         user.RatingAverage,
+		RatingCount:    user.RatingCount,
+		ShowPresence:   &user.ShowPresence,
 	}
 
 	respondWithJSON(w, http.StatusOK, profile)
@@ -93,10 +104,27 @@ func UpdateMyProfile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Presence is re-announced if the user's visibility changes
+	wasVisible := user.IsPublic && user.ShowPresence
+
 	// Update fields
-	user.ProfilePicture = req.ProfilePicture
+	if req.ProfilePictureID != nil {
+		if *req.ProfilePictureID == 0 {
+			user.ProfilePicture = ""
+		} else {
+			url, ok := resolveImageUpload(*req.ProfilePictureID, claims.UserID, "profile")
+			if !ok {
+				respondWithError(w, http.StatusBadRequest, "Profile picture upload not found")
+				return
+			}
+			user.ProfilePicture = url
+		}
+	}
 	user.Bio = strings.TrimSpace(req.Bio)
 	user.IsPublic = req.IsPublic
+	if req.ShowPresence != nil {
+		user.ShowPresence = *req.ShowPresence
+	}
 
 	// Validate bio length
 	if len(user.Bio) > 500 {
@@ -110,6 +138,15 @@ func UpdateMyProfile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if wasVisible != (user.IsPublic && user.ShowPresence) {
+		hub, hubOk := r.Context().Value(utils.HubKey).(*websocket.Hub)
+		if hubOk && hub != nil {
+			hub.RefreshPresence(user.ID)
+		} else {
+			log.Printf("Warning: Hub not found in context for UpdateMyProfile. HubOk: %v, HubNil: %v", hubOk, hub == nil)
+		}
+	}
+
 	respondWithJSON(w, http.StatusOK, map[string]interface{}{
 		"message": "Profile updated successfully",
 		"profile": ProfileResponse{
@@ -123,6 +160,7 @@ func UpdateMyProfile(w http.ResponseWriter, r *http.Request) {
 			Semester:       user.Semester,
 			IsPublic:       user.IsPublic,
 			CreatedAt:      user.CreatedAt.Format("2006-01-02 15:04:05"),
+			ShowPresence:   &user.ShowPresence,
 		},
 	})
 }
@@ -202,6 +240,8 @@ func GetUserProfile(w http.ResponseWriter, r *http.Request) {
 		CollegeName:    user.College.Name,
 		IsPublic:       user.IsPublic,
 		CreatedAt:      user.CreatedAt.Format("2006-01-02 15:04:05"),
+		Rating:         user.RatingAverage,
+		RatingCount:    user.RatingCount,
 	}
 
 	respondWithJSON(w, http.StatusOK, profile)
@@ -0,0 +1,301 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"unilink-backend/db"
+	"unilink-backend/models"
+	"unilink-backend/utils"
+	"unilink-backend/websocket"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+// maxReviewLength keeps reviews short
+const maxReviewLength = 500
+
+// RatingRequest is the payload for rating the other side of a sale
+type RatingRequest struct {
+	Score  int    `json:"score"`  // 1-5
+	Review string `json:"review"` // Optional
+}
+
+func toRatingResponse(rating models.UserRating) RatingResponse {
+	var removedAt *string
+	if rating.RemovedAt != nil {
+		str := rating.RemovedAt.Format("2006-01-02 15:04:05")
+		removedAt = &str
+	}
+
+	return RatingResponse{
+		ID:        rating.ID,
+		ListingID: rating.ListingID,
+		Rater:     toSellerInfo(rating.Rater),
+		RateeID:   rating.RateeID,
+		RateeRole: rating.RateeRole,
+		Score:     rating.Score,
+		Review:    rating.Review,
+		CreatedAt: rating.CreatedAt.Format("2006-01-02 15:04:05"),
+		RemovedAt: removedAt,
+	}
+}
+
+// RateTransaction lets the buyer or seller of a sold listing rate the other party once
+func RateTransaction(w http.ResponseWriter, r *http.Request) {
+	claims, ok := utils.GetUserClaims(r)
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "User claims not found")
+		return
+	}
+
+	vars := mux.Vars(r)
+	listingID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid listing ID")
+		return
+	}
+
+	var req RatingRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	req.Review = strings.TrimSpace(req.Review)
+	if req.Score < 1 || req.Score > 5 {
+		respondWithError(w, http.StatusBadRequest, "Score must be between 1 and 5")
+		return
+	}
+	if len(req.Review) > maxReviewLength {
+		respondWithError(w, http.StatusBadRequest, "Review must be at most 500 characters")
+		return
+	}
+
+	var listing models.MarketplaceListing
+	result := db.DB.Where("id = ? AND college_id = ?", listingID, claims.CollegeID).First(&listing)
+	if result.Error != nil {
+		respondWithError(w, http.StatusNotFound, "Listing not found or access denied")
+		return
+	}
+
+	if listing.Status != "sold" || listing.BuyerID == nil {
+		respondWithError(w, http.StatusBadRequest, "Only completed sales can be rated")
+		return
+	}
+
+	// Work out who is being rated
+	var rateeID uint
+	var rateeRole string
+	switch claims.UserID {
+	case listing.SellerID:
+		rateeID, rateeRole = *listing.BuyerID, "buyer"
+	case *listing.BuyerID:
+		rateeID, rateeRole = listing.SellerID, "seller"
+	default:
+		respondWithError(w, http.StatusForbidden, "Only the buyer and seller can rate this sale")
+		return
+	}
+
+	// Check if this side already rated the sale
+	var existing models.UserRating
+	db.DB.Where("listing_id = ? AND rater_id = ?", listing.ID, claims.UserID).First(&existing)
+	if existing.ID != 0 {
+		respondWithError(w, http.StatusConflict, "You have already rated this sale")
+		return
+	}
+
+	rating := models.UserRating{
+		ListingID: listing.ID,
+		RaterID:   claims.UserID,
+		RateeID:   rateeID,
+		RateeRole: rateeRole,
+		Score:     req.Score,
+		Review:    req.Review,
+		CollegeID: listing.CollegeID,
+	}
+
+	tx := db.DB.Begin()
+	if err := tx.Create(&rating).Error; err != nil {
+		tx.Rollback()
+		log.Printf("Error saving rating for listing %d: %v", listing.ID, err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to save rating")
+		return
+	}
+	if err := refreshReputation(tx, rateeID); err != nil {
+		tx.Rollback()
+		log.Printf("Error updating reputation for user %d: %v", rateeID, err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to save rating")
+		return
+	}
+	if err := tx.Commit().Error; err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to save rating")
+		return
+	}
+
+	db.DB.Preload("Rater").First(&rating, rating.ID)
+	response := toRatingResponse(rating)
+
+	// --- Let the rated user know ---
+	hub, hubOk := r.Context().Value(utils.HubKey).(*websocket.Hub)
+	if hubOk && hub != nil {
+		hub.Publish(websocket.NewRatingEvent{
+			RateeID: rateeID,
+			Rating:  response,
+		})
+	} else {
+		log.Printf("Warning: Hub not found in context for RateTransaction. HubOk: %v, HubNil: %v", hubOk, hub == nil)
+	}
+
+	respondWithJSON(w, http.StatusCreated, response)
+}
+
+// GetUserRatings returns the visible reviews a user has received, newest first
+func GetUserRatings(w http.ResponseWriter, r *http.Request) {
+	claims, ok := utils.GetUserClaims(r)
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "User claims not found")
+		return
+	}
+
+	vars := mux.Vars(r)
+	userID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	var user models.User
+	result := db.DB.Where("id = ? AND college_id = ?", userID, claims.CollegeID).First(&user) // College isolation
+	if result.Error != nil {
+		respondWithError(w, http.StatusNotFound, "User not found or not in your college")
+		return
+	}
+
+	var ratings []models.UserRating
+	if err := db.DB.Preload("Rater").
+		Where("ratee_id = ? AND removed_at IS NULL", user.ID).
+		Order("created_at DESC").
+		Find(&ratings).Error; err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to fetch ratings")
+		return
+	}
+
+	response := make([]RatingResponse, 0, len(ratings))
+	for _, rating := range ratings {
+		response = append(response, toRatingResponse(rating))
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"userId":      user.ID,
+		"rating":      user.RatingAverage,
+		"ratingCount": user.RatingCount,
+		"ratings":     response,
+	})
+}
+
+// ============================================
+// COLLEGE ADMIN FUNCTIONS (Review moderation)
+// ============================================
+
+// GetCollegeRatings lists reviews in the admin's college (?includeRemoved=true to see removed ones)
+func GetCollegeRatings(w http.ResponseWriter, r *http.Request) {
+	claims, ok := utils.GetUserClaims(r)
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "User claims not found")
+		return
+	}
+
+	query := db.DB.Preload("Rater").Where("college_id = ?", claims.CollegeID)
+	if r.URL.Query().Get("includeRemoved") != "true" {
+		query = query.Where("removed_at IS NULL")
+	}
+
+	var ratings []models.UserRating
+	if err := query.Order("created_at DESC").Find(&ratings).Error; err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to fetch ratings")
+		return
+	}
+
+	response := make([]RatingResponse, 0, len(ratings))
+	for _, rating := range ratings {
+		response = append(response, toRatingResponse(rating))
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"total":   len(response),
+		"ratings": response,
+	})
+}
+
+// RemoveRating hides an abusive review and recalculates the ratee's reputation
+func RemoveRating(w http.ResponseWriter, r *http.Request) {
+	claims, ok := utils.GetUserClaims(r)
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "User claims not found")
+		return
+	}
+
+	vars := mux.Vars(r)
+	ratingID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid rating ID")
+		return
+	}
+
+	// Find rating and verify it belongs to admin's college
+	var rating models.UserRating
+	result := db.DB.Where("id = ? AND college_id = ? AND removed_at IS NULL", ratingID, claims.CollegeID).First(&rating)
+	if result.Error != nil {
+		respondWithError(w, http.StatusNotFound, "Rating not found in your college")
+		return
+	}
+
+	tx := db.DB.Begin()
+	if err := tx.Model(&rating).Updates(map[string]interface{}{
+		"removed_at": time.Now(),
+		"removed_by": claims.UserID,
+	}).Error; err != nil {
+		tx.Rollback()
+		log.Printf("Error removing rating %d: %v", rating.ID, err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to remove rating")
+		return
+	}
+	if err := refreshReputation(tx, rating.RateeID); err != nil {
+		tx.Rollback()
+		log.Printf("Error updating reputation for user %d: %v", rating.RateeID, err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to remove rating")
+		return
+	}
+	if err := tx.Commit().Error; err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to remove rating")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]string{
+		"message": "Rating removed successfully",
+	})
+}
+
+// refreshReputation recomputes a user's cached rating from their visible reviews
+func refreshReputation(tx *gorm.DB, userID uint) error {
+	var agg struct {
+		Average float64
+		Count   int
+	}
+	if err := tx.Model(&models.UserRating{}).
+		Select("COALESCE(AVG(score), 0) AS average, COUNT(*) AS count").
+		Where("ratee_id = ? AND removed_at IS NULL", userID).
+		Scan(&agg).Error; err != nil {
+		return err
+	}
+
+	return tx.Model(&models.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+		"rating_average": agg.Average,
+		"rating_count":   agg.Count,
+	}).Error
+}
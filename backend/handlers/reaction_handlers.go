@@ -0,0 +1,198 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"unilink-backend/db"
+	"unilink-backend/models"
+	"unilink-backend/utils"
+	"unilink-backend/websocket"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm/clause"
+)
+
+// maxEmojiBytes fits multi-codepoint emoji (skin tones, ZWJ sequences) and matches the column size
+const maxEmojiBytes = 32
+
+// ReactionRequest is the payload for reacting to a message
+type ReactionRequest struct {
+	Emoji string `json:"emoji"`
+}
+
+// AddReaction adds the user's emoji reaction to a message (adding the same one twice is a no-op)
+func AddReaction(w http.ResponseWriter, r *http.Request) {
+	claims, ok := utils.GetUserClaims(r)
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "User claims not found")
+		return
+	}
+
+	var req ReactionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	req.Emoji = strings.TrimSpace(req.Emoji)
+	if !validEmoji(req.Emoji) {
+		respondWithError(w, http.StatusBadRequest, "Invalid emoji")
+		return
+	}
+
+	message, ok := findReactableMessage(w, r, claims)
+	if !ok {
+		return
+	}
+
+	reaction := models.MessageReaction{
+		MessageID: message.ID,
+		UserID:    claims.UserID,
+		Emoji:     req.Emoji,
+		CreatedAt: time.Now(),
+	}
+	result := db.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&reaction)
+	if result.Error != nil {
+		log.Printf("Error adding reaction to message %d: %v", message.ID, result.Error)
+		respondWithError(w, http.StatusInternalServerError, "Failed to add reaction")
+		return
+	}
+
+	reactions := messageReactions([]uint{message.ID}, claims.UserID)[message.ID]
+	if result.RowsAffected > 0 {
+		touchMessage(message.ID)
+		broadcastReaction(r, message, claims.UserID, req.Emoji, "added")
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"messageId": message.ID,
+		"reactions": reactions,
+	})
+}
+
+// RemoveReaction removes the user's {emoji} reaction from a message
+func RemoveReaction(w http.ResponseWriter, r *http.Request) {
+	claims, ok := utils.GetUserClaims(r)
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "User claims not found")
+		return
+	}
+
+	emoji := mux.Vars(r)["emoji"]
+	if !validEmoji(emoji) {
+		respondWithError(w, http.StatusBadRequest, "Invalid emoji")
+		return
+	}
+
+	message, ok := findReactableMessage(w, r, claims)
+	if !ok {
+		return
+	}
+
+	result := db.DB.Where("message_id = ? AND user_id = ? AND emoji = ?", message.ID, claims.UserID, emoji).
+		Delete(&models.MessageReaction{})
+	if result.Error != nil {
+		log.Printf("Error removing reaction from message %d: %v", message.ID, result.Error)
+		respondWithError(w, http.StatusInternalServerError, "Failed to remove reaction")
+		return
+	}
+	if result.RowsAffected == 0 {
+		respondWithError(w, http.StatusNotFound, "Reaction not found")
+		return
+	}
+
+	touchMessage(message.ID)
+	reactions := messageReactions([]uint{message.ID}, claims.UserID)[message.ID]
+	broadcastReaction(r, message, claims.UserID, emoji, "removed")
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"messageId": message.ID,
+		"reactions": reactions,
+	})
+}
+
+// findReactableMessage loads the {id} message if it isn't deleted and the user is in its conversation
+func findReactableMessage(w http.ResponseWriter, r *http.Request, claims *utils.CustomClaims) (*models.Message, bool) {
+	vars := mux.Vars(r)
+	messageID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid message ID")
+		return nil, false
+	}
+
+	var message models.Message
+	result := db.DB.Where("id = ? AND college_id = ? AND is_deleted = ?", messageID, claims.CollegeID, false).First(&message)
+	if result.Error != nil || !userHasAccessToConversation(claims.UserID, message.ConversationID) {
+		respondWithError(w, http.StatusNotFound, "Message not found")
+		return nil, false
+	}
+	return &message, true
+}
+
+// touchMessage bumps a message's updated_at so GET /messages/sync?changedSince= picks up
+// reaction changes
+func touchMessage(messageID uint) {
+	if err := db.DB.Model(&models.Message{}).Where("id = ?", messageID).
+		UpdateColumn("updated_at", time.Now()).Error; err != nil {
+		log.Printf("Error updating message %d after reaction change: %v", messageID, err)
+	}
+}
+
+// messageReactions aggregates reactions per message, in the order each emoji was first used
+func messageReactions(messageIDs []uint, viewerID uint) map[uint][]ReactionSummary {
+	result := make(map[uint][]ReactionSummary)
+	if len(messageIDs) == 0 {
+		return result
+	}
+
+	var rows []struct {
+		MessageID uint
+		Emoji     string
+		Count     int
+		Reacted   bool
+	}
+	err := db.DB.Model(&models.MessageReaction{}).
+		Select("message_id, emoji, COUNT(*) AS count, BOOL_OR(user_id = ?) AS reacted", viewerID).
+		Where("message_id IN ?", messageIDs).
+		Group("message_id, emoji").
+		Order("MIN(created_at) ASC").
+		Scan(&rows).Error
+	if err != nil {
+		log.Printf("Error aggregating reactions: %v", err)
+		return result
+	}
+
+	for _, row := range rows {
+		result[row.MessageID] = append(result[row.MessageID], ReactionSummary{
+			Emoji:   row.Emoji,
+			Count:   row.Count,
+			Reacted: row.Reacted,
+		})
+	}
+	return result
+}
+
+// broadcastReaction tells everyone in the conversation that an emoji's count changed
+func broadcastReaction(r *http.Request, message *models.Message, userID uint, emoji string, action string) {
+	hub, hubOk := r.Context().Value(utils.HubKey).(*websocket.Hub)
+	if !hubOk || hub == nil {
+		log.Printf("Warning: Hub not found in context for reaction. HubOk: %v, HubNil: %v", hubOk, hub == nil)
+		return
+	}
+
+	var count int64
+	db.DB.Model(&models.MessageReaction{}).Where("message_id = ? AND emoji = ?", message.ID, emoji).Count(&count)
+
+	hub.Publish(websocket.MessageReactionEvent{
+		MessageID:      message.ID,
+		ConversationID: message.ConversationID,
+		UserID:         userID,
+		Emoji:          emoji,
+		Action:         action, // "added" or "removed"
+		Count:          count,
+	})
+}
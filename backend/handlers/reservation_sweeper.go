@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"log"
+	"os"
+	"time"
+
+	"unilink-backend/db"
+	"unilink-backend/models"
+	"unilink-backend/websocket"
+)
+
+// defaultSweepInterval is how often expired reservations are checked when
+// RESERVATION_SWEEP_INTERVAL is not set
+const defaultSweepInterval = time.Minute
+
+// StartReservationSweeper periodically returns listings whose reservation has
+// expired to "available" and notifies both buyer and seller. Run it in a goroutine.
+func StartReservationSweeper(hub *websocket.Hub) {
+	interval := defaultSweepInterval
+	if d, err := time.ParseDuration(os.Getenv("RESERVATION_SWEEP_INTERVAL")); err == nil && d > 0 {
+		interval = d
+	}
+
+	log.Printf("🧹 Reservation sweeper started (every %v)", interval)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		sweepExpiredReservations(hub)
+	}
+}
+
+// sweepExpiredReservations releases every reservation past its ReservedUntil
+func sweepExpiredReservations(hub *websocket.Hub) {
+	now := time.Now()
+
+	var expired []models.MarketplaceListing
+	result := db.DB.
+		Where("status = ? AND reserved_until IS NOT NULL AND reserved_until < ?", "reserved", now).
+		Find(&expired)
+	if result.Error != nil {
+		log.Printf("Error finding expired reservations: %v", result.Error)
+		return
+	}
+
+	for _, listing := range expired {
+		if listing.BuyerID == nil {
+			continue
+		}
+		buyerID := *listing.BuyerID
+
+		// Conditional update: skip listings that were sold, cancelled or extended meanwhile
+		update := db.DB.Model(&models.MarketplaceListing{}).
+			Where("id = ? AND status = ? AND reserved_until < ?", listing.ID, "reserved", now).
+			Updates(map[string]interface{}{
+				"status":         "available",
+				"buyer_id":       nil,
+				"reserved_until": nil,
+				"agreed_price":   nil,
+			})
+		if update.Error != nil {
+			log.Printf("Error releasing expired reservation for listing %d: %v", listing.ID, update.Error)
+			continue
+		}
+		if update.RowsAffected == 0 {
+			continue
+		}
+
+		log.Printf("Reservation expired for listing %d (Buyer: %d, Seller: %d)", listing.ID, buyerID, listing.SellerID)
+
+		if hub != nil {
+			hub.Publish(websocket.ReservationExpiredEvent{
+				ListingID: listing.ID,
+				Title:     listing.Title,
+				BuyerID:   buyerID,
+				SellerID:  listing.SellerID,
+				Status:    "available",
+			})
+		}
+	}
+}
@@ -0,0 +1,313 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"time"
+
+	"unilink-backend/db"
+	"unilink-backend/models"
+	"unilink-backend/storage"
+	"unilink-backend/utils"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+// defaultMaxUploadSize is used when MAX_UPLOAD_SIZE (bytes) is not set
+const defaultMaxUploadSize = 5 << 20
+
+// maxListingImages caps how many photos one listing can have
+const maxListingImages = 8
+
+// allowedImageTypes maps accepted content types to file extensions
+var allowedImageTypes = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+}
+
+// imageContentTypes maps stored file extensions back to content types (thumbnails are JPEG)
+var imageContentTypes = map[string]string{
+	".jpg": "image/jpeg",
+	".png": "image/png",
+	".gif": "image/gif",
+}
+
+// validUploadPurposes lists what an upload can be used for
+var validUploadPurposes = map[string]bool{
+	"listing": true, "profile": true, "group": true,
+}
+
+// UploadResponse tells the client where the stored file can be fetched
+type UploadResponse struct {
+	ID           uint   `json:"id"`
+	URL          string `json:"url"`
+	ThumbnailURL string `json:"thumbnailUrl"`
+	Purpose      string `json:"purpose"`
+	ContentType  string `json:"contentType"`
+	Size         int64  `json:"size"`
+	Width        int    `json:"width"`
+	Height       int    `json:"height"`
+}
+
+// ImageRoute serves stored images without authentication: <img> tags can't send the
+// bearer token. The random file names in storage keys keep the URLs unguessable.
+const ImageRoute = "/api/images/{college:college_[0-9]+}/{file:[0-9a-f]{32}(?:_thumb)?\\.(?:jpg|png|gif)}"
+
+// ImageURL is the public URL of a stored image, matching ImageRoute
+func ImageURL(key string) string {
+	return "/api/images/" + key
+}
+
+func maxUploadSize() int64 {
+	if size, err := strconv.ParseInt(os.Getenv("MAX_UPLOAD_SIZE"), 10, 64); err == nil && size > 0 {
+		return size
+	}
+	return defaultMaxUploadSize
+}
+
+// UploadImage stores an image (multipart field "file") and generates its thumbnail.
+// The optional "purpose" field is "listing" (default), "profile" or "group".
+func UploadImage(w http.ResponseWriter, r *http.Request) {
+	claims, ok := utils.GetUserClaims(r)
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "User claims not found")
+		return
+	}
+
+	limit := maxUploadSize()
+	r.Body = http.MaxBytesReader(w, r.Body, limit+1024) // Leave room for the multipart framing
+	if err := r.ParseMultipartForm(limit); err != nil {
+		respondWithError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("File is missing or larger than %d bytes", limit))
+		return
+	}
+
+	purpose := r.FormValue("purpose")
+	if purpose == "" {
+		purpose = "listing"
+	}
+	if !validUploadPurposes[purpose] {
+		respondWithError(w, http.StatusBadRequest, "Purpose must be 'listing', 'profile', or 'group'")
+		return
+	}
+
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Image file is required (form field 'file')")
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Failed to read image")
+		return
+	}
+	if int64(len(data)) > limit {
+		respondWithError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("File is larger than %d bytes", limit))
+		return
+	}
+
+	// Trust the bytes, not the client's Content-Type header
+	contentType := http.DetectContentType(data)
+	ext, allowed := allowedImageTypes[contentType]
+	if !allowed {
+		respondWithError(w, http.StatusUnsupportedMediaType, "Only JPEG, PNG and GIF images are allowed")
+		return
+	}
+
+	img, err := storage.DecodeImage(data)
+	if errors.Is(err, storage.ErrImageTooLarge) {
+		respondWithError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("Image is larger than %d pixels", storage.MaxImagePixels))
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "File is not a valid image")
+		return
+	}
+	thumb, err := storage.Thumbnail(img)
+	if err != nil {
+		log.Printf("Error generating thumbnail: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to process image")
+		return
+	}
+
+	name, err := randomName()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to store image")
+		return
+	}
+	key := fmt.Sprintf("college_%d/%s%s", claims.CollegeID, name, ext)
+	thumbKey := fmt.Sprintf("college_%d/%s_thumb.jpg", claims.CollegeID, name)
+
+	if err := storage.Files.Save(key, bytes.NewReader(data)); err != nil {
+		log.Printf("Error saving upload %s: %v", key, err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to store image")
+		return
+	}
+	if err := storage.Files.Save(thumbKey, bytes.NewReader(thumb)); err != nil {
+		log.Printf("Error saving thumbnail %s: %v", thumbKey, err)
+		storage.Files.Delete(key)
+		respondWithError(w, http.StatusInternalServerError, "Failed to store image")
+		return
+	}
+
+	bounds := img.Bounds()
+	upload := models.Upload{
+		Key:          key,
+		ThumbnailKey: thumbKey,
+		OwnerID:      claims.UserID,
+		CollegeID:    claims.CollegeID,
+		Purpose:      purpose,
+		ContentType:  contentType,
+		Size:         int64(len(data)),
+		Width:        bounds.Dx(),
+		Height:       bounds.Dy(),
+	}
+	if err := db.DB.Create(&upload).Error; err != nil {
+		log.Printf("Error recording upload %s: %v", key, err)
+		storage.Files.Delete(key)
+		storage.Files.Delete(thumbKey)
+		respondWithError(w, http.StatusInternalServerError, "Failed to store image")
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, UploadResponse{
+		ID:           upload.ID,
+		URL:          ImageURL(upload.Key),
+		ThumbnailURL: ImageURL(upload.ThumbnailKey),
+		Purpose:      upload.Purpose,
+		ContentType:  upload.ContentType,
+		Size:         upload.Size,
+		Width:        upload.Width,
+		Height:       upload.Height,
+	})
+}
+
+// ServeImage streams an uploaded image or thumbnail by storage key (see ImageRoute)
+func ServeImage(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	key := vars["college"] + "/" + vars["file"]
+
+	file, err := storage.Files.Open(key)
+	if err != nil {
+		if !errors.Is(err, storage.ErrNotFound) {
+			log.Printf("Error opening image %s: %v", key, err)
+		}
+		respondWithError(w, http.StatusNotFound, "File not found")
+		return
+	}
+	defer file.Close()
+
+	w.Header().Set("Content-Type", imageContentTypes[path.Ext(key)])
+	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable") // Keys are never reused
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+
+	// Local files support range requests; other backends are streamed as-is
+	if seeker, ok := file.(io.ReadSeeker); ok {
+		http.ServeContent(w, r, "", time.Time{}, seeker)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	io.Copy(w, file)
+}
+
+// orderImages is passed to Preload("Images", ...) so photos come back in position
+// order, with the uploads their URLs are built from
+func orderImages(tx *gorm.DB) *gorm.DB {
+	return tx.Preload("Upload").Order("position ASC")
+}
+
+// toListingImages converts preloaded listing images for responses
+func toListingImages(images []models.ListingImage) []ListingImageInfo {
+	result := make([]ListingImageInfo, 0, len(images))
+	for _, image := range images {
+		result = append(result, ListingImageInfo{
+			UploadID:     image.UploadID,
+			URL:          ImageURL(image.Upload.Key),
+			ThumbnailURL: ImageURL(image.Upload.ThumbnailKey),
+			Position:     image.Position,
+		})
+	}
+	return result
+}
+
+// resolveImageUpload returns the URL of one of the user's uploads, as stored in
+// User.ProfilePicture and Group.Avatar. ok is false if the upload doesn't exist,
+// isn't theirs or was uploaded for another purpose.
+func resolveImageUpload(uploadID uint, userID uint, purpose string) (url string, ok bool) {
+	var upload models.Upload
+	if err := db.DB.Where("id = ? AND owner_id = ? AND purpose = ?", uploadID, userID, purpose).
+		First(&upload).Error; err != nil {
+		return "", false
+	}
+	return ImageURL(upload.Key), true
+}
+
+// validateListingImages checks that every upload exists, belongs to the user and
+// is a listing image. It returns an error message for the client.
+func validateListingImages(uploadIDs []uint, userID uint) string {
+	if len(uploadIDs) > maxListingImages {
+		return fmt.Sprintf("A listing can have at most %d images", maxListingImages)
+	}
+	if len(uploadIDs) == 0 {
+		return ""
+	}
+
+	seen := make(map[uint]bool, len(uploadIDs))
+	for _, id := range uploadIDs {
+		if seen[id] {
+			return "Duplicate image in list"
+		}
+		seen[id] = true
+	}
+
+	var count int64
+	db.DB.Model(&models.Upload{}).
+		Where("id IN ? AND owner_id = ? AND purpose = ?", uploadIDs, userID, "listing").
+		Count(&count)
+	if int(count) != len(uploadIDs) {
+		return "One or more images were not found or don't belong to you"
+	}
+	return ""
+}
+
+// replaceListingImages sets a listing's photos to uploadIDs, in order
+func replaceListingImages(tx *gorm.DB, listingID uint, uploadIDs []uint) error {
+	if err := tx.Where("listing_id = ?", listingID).Delete(&models.ListingImage{}).Error; err != nil {
+		return err
+	}
+	if len(uploadIDs) == 0 {
+		return nil
+	}
+
+	images := make([]models.ListingImage, 0, len(uploadIDs))
+	for i, id := range uploadIDs {
+		images = append(images, models.ListingImage{
+			ListingID: listingID,
+			UploadID:  id,
+			Position:  i,
+			CreatedAt: time.Now(),
+		})
+	}
+	return tx.Create(&images).Error
+}
+
+// randomName returns a random hex file name so keys can't be guessed
+func randomName() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+
+	"unilink-backend/websocket"
+)
+
+// typingExpirySeconds is how long a typing indicator stays up without a fresh typingStart
+const typingExpirySeconds = 5
+
+// TypingRequest is the payload of the typingStart/typingStop WebSocket actions
+type TypingRequest struct {
+	ConversationID string `json:"conversationId"`
+}
+
+// MarkReadActionRequest is the payload of the markRead WebSocket action
+type MarkReadActionRequest struct {
+	ConversationID string `json:"conversationId"`
+	MessageID      uint   `json:"messageId"` // 0 = latest
+}
+
+// RegisterWebSocketActions wires the chat actions clients can send over /ws.
+// They go through the same checks as the REST endpoints.
+func RegisterWebSocketActions(hub *websocket.Hub) {
+	hub.HandleAction("sendMessage", wsSendMessage)
+	hub.HandleAction("markRead", wsMarkRead)
+	hub.HandleAction("typingStart", func(hub *websocket.Hub, client websocket.ClientInfo, payload json.RawMessage) (interface{}, error) {
+		return wsTyping(hub, client, payload, true)
+	})
+	hub.HandleAction("typingStop", func(hub *websocket.Hub, client websocket.ClientInfo, payload json.RawMessage) (interface{}, error) {
+		return wsTyping(hub, client, payload, false)
+	})
+}
+
+// wsSendMessage stores and fans out a message; the ack carries the stored message
+// so the client can replace its optimistic copy
+func wsSendMessage(hub *websocket.Hub, client websocket.ClientInfo, payload json.RawMessage) (interface{}, error) {
+	var req SendMessageRequest
+	if err := json.Unmarshal(payload, &req); err != nil {
+		return nil, errors.New("Invalid request payload")
+	}
+
+	message, _, err := createMessage(client.UserID, client.CollegeID, req)
+	if err != nil {
+		return nil, err
+	}
+
+	publishNewMessage(hub, message)
+	return message, nil
+}
+
+// wsMarkRead moves the user's read pointer and notifies the other participants
+func wsMarkRead(hub *websocket.Hub, client websocket.ClientInfo, payload json.RawMessage) (interface{}, error) {
+	var req MarkReadActionRequest
+	if err := json.Unmarshal(payload, &req); err != nil {
+		return nil, errors.New("Invalid request payload")
+	}
+	if !userHasAccessToConversation(client.UserID, req.ConversationID) {
+		return nil, errors.New("Access denied to this conversation")
+	}
+
+	lastRead, advanced, err := markConversationRead(req.ConversationID, client.UserID, req.MessageID)
+	if err != nil {
+		log.Printf("Error marking conversation %s read for user %d: %v", req.ConversationID, client.UserID, err)
+		return nil, errors.New("Failed to mark conversation as read")
+	}
+	if advanced {
+		broadcastMessagesRead(hub, req.ConversationID, client.UserID, lastRead)
+	}
+
+	return map[string]interface{}{
+		"conversationId":    req.ConversationID,
+		"lastReadMessageId": lastRead,
+	}, nil
+}
+
+// wsTyping relays a typing indicator to the other participants
+func wsTyping(hub *websocket.Hub, client websocket.ClientInfo, payload json.RawMessage, typing bool) (interface{}, error) {
+	var req TypingRequest
+	if err := json.Unmarshal(payload, &req); err != nil {
+		return nil, errors.New("Invalid request payload")
+	}
+	if !userHasAccessToConversation(client.UserID, req.ConversationID) {
+		return nil, errors.New("Access denied to this conversation")
+	}
+
+	hub.Publish(websocket.TypingEvent{
+		ConversationID: req.ConversationID,
+		UserID:         client.UserID,
+		Typing:         typing,
+		ExpiresIn:      typingExpirySeconds,
+	})
+	return nil, nil
+}
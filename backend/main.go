@@ -9,6 +9,7 @@ import (
 
 	"unilink-backend/db"
 	"unilink-backend/handlers"
+	"unilink-backend/storage"
 	"unilink-backend/utils"
 	"unilink-backend/websocket"
 
@@ -52,12 +53,38 @@ func main() {
 	}
 
 	db.ConnectDB()
+	handlers.BackfillConversationMembers()
+	storage.InitStorage()
 	wsHub = websocket.NewHub()
+	// Multiple replicas share events over Postgres LISTEN/NOTIFY (HUB_BACKPLANE=postgres)
+	if os.Getenv("HUB_BACKPLANE") == "postgres" {
+		wsHub.SetBackplane(websocket.NewPostgresBackplane(os.Getenv("DATABASE_URL"), os.Getenv("HUB_BACKPLANE_CHANNEL")))
+	}
+	handlers.RegisterWebSocketActions(wsHub)
 	go wsHub.Run()
+	go handlers.StartReservationSweeper(wsHub)
+	go handlers.StartAttachmentSweeper()
 
-	router := mux.NewRouter()
+	router := newRouter(wsHub)
 	corsMiddleware := utils.SetupCORS()
 
+	port := os.Getenv("PORT")
+	if port == "" {
+		port = "8080"
+	}
+
+	handler := corsMiddleware(router)
+
+	log.Printf("🚀 Server starting on port %s", port)
+	log.Printf("🔌 WebSocket endpoint: ws://localhost:%s/ws", port)
+	log.Printf("📍 Health check: http://localhost:%s/api/health", port)
+	log.Fatal(http.ListenAndServe(":"+port, handler))
+}
+
+// newRouter registers every route. Protected routes get the Hub and a validated token.
+func newRouter(hub *websocket.Hub) *mux.Router {
+	router := mux.NewRouter()
+
 	// Apply logging middleware globally (optional, but helpful for debugging)
 	router.Use(loggingMiddleware)
 
@@ -66,10 +93,14 @@ func main() {
 	router.HandleFunc("/api/register", handlers.RegisterStudent).Methods("POST", "OPTIONS")
 	router.HandleFunc("/api/login", handlers.Login).Methods("POST", "OPTIONS")
 	router.HandleFunc("/api/admin/login", handlers.LoginAdmin).Methods("POST", "OPTIONS")
+	router.HandleFunc("/api/auth/refresh", handlers.RefreshToken).Methods("POST", "OPTIONS")
 	// router.HandleFunc("/api/setup/platform-admin", handlers.CreateFirstPlatformAdmin).Methods("POST", "OPTIONS") // Keep commented unless needed
 	router.HandleFunc("/ws", func(w http.ResponseWriter, r *http.Request) {
-		websocket.ServeWs(wsHub, w, r)
+		websocket.ServeWs(hub, w, r)
 	})
+	router.HandleFunc("/api/ws/schema", websocket.ServeEventSchema).Methods("GET")
+	// Uploaded images, loaded by <img> tags that can't send a token
+	router.HandleFunc(handlers.ImageRoute, handlers.ServeImage).Methods("GET")
 	router.HandleFunc("/api/health", func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusOK)
@@ -78,28 +109,49 @@ func main() {
 
 	// Protected routes
 	protected := router.PathPrefix("/api").Subrouter()
-	protected.Use(withHub(wsHub))      // Apply Hub middleware FIRST
+	protected.Use(withHub(hub))        // Apply Hub middleware FIRST
 	protected.Use(utils.ValidateToken) // Then validate token
 
 	// Define all protected routes
+	// Session routes
+	protected.HandleFunc("/auth/logout", handlers.Logout).Methods("POST")
+	protected.HandleFunc("/auth/logout-all", handlers.LogoutAll).Methods("POST")
+	protected.HandleFunc("/ws/ticket", handlers.CreateWebSocketTicket).Methods("POST")
 	// Student marketplace routes
 	protected.HandleFunc("/listings", handlers.GetAllListings).Methods("GET")
 	protected.HandleFunc("/listings", handlers.CreateListing).Methods("POST")
 	protected.HandleFunc("/listings/my", handlers.GetMyListings).Methods("GET")
 	protected.HandleFunc("/listings/my-reservations", handlers.GetMyReservations).Methods("GET")
 	protected.HandleFunc("/listings/{id}", handlers.GetListingByID).Methods("GET")
+	protected.HandleFunc("/listings/{id}", handlers.UpdateListing).Methods("PUT")
 	protected.HandleFunc("/listings/{id}", handlers.DeleteListing).Methods("DELETE")
+	protected.HandleFunc("/listings/{id}/history", handlers.GetListingHistory).Methods("GET")
 	// *** NEW Marketplace Action Routes ***
 	protected.HandleFunc("/listings/{id}/reserve", handlers.ReserveListing).Methods("POST")
 	protected.HandleFunc("/listings/{id}/cancel-reservation", handlers.CancelReservation).Methods("POST")
+	protected.HandleFunc("/listings/{id}/extend-reservation", handlers.ExtendReservation).Methods("POST")
 	protected.HandleFunc("/listings/{id}/mark-sold", handlers.MarkListingSold).Methods("POST")
+	protected.HandleFunc("/listings/{id}/rating", handlers.RateTransaction).Methods("POST")
+	// Offer routes
+	protected.HandleFunc("/listings/{id}/offers", handlers.CreateOffer).Methods("POST")
+	protected.HandleFunc("/listings/{id}/offers", handlers.GetListingOffers).Methods("GET")
+	protected.HandleFunc("/offers/my", handlers.GetMyOffers).Methods("GET")
+	protected.HandleFunc("/offers/{id}/counter", handlers.CounterOffer).Methods("POST")
+	protected.HandleFunc("/offers/{id}/accept", handlers.AcceptOffer).Methods("POST")
+	protected.HandleFunc("/offers/{id}/reject", handlers.RejectOffer).Methods("POST")
+	protected.HandleFunc("/offers/{id}/withdraw", handlers.WithdrawOffer).Methods("POST")
+	protected.HandleFunc("/categories", handlers.GetCategories).Methods("GET")
 
 	// *** END NEW Routes ***
 
+	// Upload routes (images are served publicly, see handlers.ImageRoute)
+	protected.HandleFunc("/uploads", handlers.UploadImage).Methods("POST")
+
 	// Profile routes
 	protected.HandleFunc("/profile/me", handlers.GetMyProfile).Methods("GET")
 	protected.HandleFunc("/profile/me", handlers.UpdateMyProfile).Methods("PUT")
 	protected.HandleFunc("/profile/{id}", handlers.GetUserProfile).Methods("GET")
+	protected.HandleFunc("/profile/{id}/ratings", handlers.GetUserRatings).Methods("GET")
 	protected.HandleFunc("/directory", handlers.SearchDirectory).Methods("GET")
 	protected.HandleFunc("/departments", handlers.GetDepartments).Methods("GET")
 	// Student announcement feed
@@ -109,6 +161,7 @@ func main() {
 	protected.HandleFunc("/friends/requests/pending", handlers.GetPendingRequests).Methods("GET")
 	protected.HandleFunc("/friends/accept/{id}", handlers.AcceptFriendRequest).Methods("POST")
 	protected.HandleFunc("/friends/reject/{id}", handlers.RejectFriendRequest).Methods("POST")
+	protected.HandleFunc("/presence", handlers.GetPresence).Methods("GET")
 	protected.HandleFunc("/friends", handlers.GetFriends).Methods("GET")
 	protected.HandleFunc("/friends/{id}", handlers.RemoveFriend).Methods("DELETE")
 	protected.HandleFunc("/friends/suggestions", handlers.GetFriendSuggestions).Methods("GET")
@@ -120,17 +173,39 @@ func main() {
 	protected.HandleFunc("/groups/{id}/leave", handlers.LeaveGroup).Methods("POST")
 	// Messaging routes
 	protected.HandleFunc("/conversations", handlers.GetConversations).Methods("GET")
+	protected.HandleFunc("/messages/sync", handlers.SyncMessages).Methods("GET")
+	protected.HandleFunc("/messages/search", handlers.SearchMessages).Methods("GET")
 	protected.HandleFunc("/conversations/{conversationId}/messages", handlers.GetMessages).Methods("GET")
 	protected.HandleFunc("/conversations/{conversationId}/messages", handlers.SendMessage).Methods("POST")
+	protected.HandleFunc("/conversations/{conversationId}/read", handlers.MarkConversationRead).Methods("POST")
+	protected.HandleFunc("/conversations/{conversationId}/attachments", handlers.UploadAttachment).Methods("POST")
+	protected.HandleFunc("/attachments/{id}", handlers.ServeAttachment).Methods("GET")
+	protected.HandleFunc("/attachments/{id}/thumbnail", handlers.ServeAttachmentThumbnail).Methods("GET")
+	protected.HandleFunc("/messages/{id}", handlers.EditMessage).Methods("PATCH")
 	protected.HandleFunc("/messages/{id}", handlers.DeleteMessage).Methods("DELETE")
+	protected.HandleFunc("/messages/{id}/edits", handlers.GetMessageEdits).Methods("GET")
+	protected.HandleFunc("/messages/{id}/replies", handlers.GetThread).Methods("GET")
+	protected.HandleFunc("/messages/{id}/reactions", handlers.AddReaction).Methods("POST")
+	protected.HandleFunc("/messages/{id}/reactions/{emoji}", handlers.RemoveReaction).Methods("DELETE")
 
 	// College Admin Routes
 	collegeAdmin := protected.PathPrefix("/college-admin").Subrouter()
 	collegeAdmin.Use(utils.RequireRole("college_admin"))
 	collegeAdmin.HandleFunc("/students", handlers.GetCollegeStudents).Methods("GET")
+	collegeAdmin.HandleFunc("/students/{id}/suspend", handlers.SuspendStudent).Methods("POST")
+	collegeAdmin.HandleFunc("/students/{id}/reinstate", handlers.ReinstateStudent).Methods("POST")
 	collegeAdmin.HandleFunc("/listings", handlers.GetCollegeListings).Methods("GET")
 	collegeAdmin.HandleFunc("/listings/{id}", handlers.DeleteCollegeListing).Methods("DELETE")
 	collegeAdmin.HandleFunc("/stats", handlers.GetCollegeStats).Methods("GET")
+	collegeAdmin.HandleFunc("/ratings", handlers.GetCollegeRatings).Methods("GET")
+	collegeAdmin.HandleFunc("/ratings/{id}", handlers.RemoveRating).Methods("DELETE")
+	collegeAdmin.HandleFunc("/roster", handlers.UploadStudentRoster).Methods("POST")
+	collegeAdmin.HandleFunc("/settings", handlers.GetCollegeSettings).Methods("GET")
+	collegeAdmin.HandleFunc("/settings", handlers.UpdateCollegeSettings).Methods("PUT")
+	collegeAdmin.HandleFunc("/categories", handlers.GetCategories).Methods("GET")
+	collegeAdmin.HandleFunc("/categories", handlers.CreateCollegeCategory).Methods("POST")
+	collegeAdmin.HandleFunc("/categories/{id}", handlers.UpdateCollegeCategory).Methods("PUT")
+	collegeAdmin.HandleFunc("/categories/{id}", handlers.DeleteCollegeCategory).Methods("DELETE")
 	collegeAdmin.HandleFunc("/announcements", handlers.CreateAnnouncement).Methods("POST")
 	collegeAdmin.HandleFunc("/announcements", handlers.GetCollegeAnnouncements).Methods("GET")
 	collegeAdmin.HandleFunc("/announcements/{id}", handlers.UpdateAnnouncement).Methods("PUT")
@@ -143,20 +218,16 @@ func main() {
 	platformAdmin := protected.PathPrefix("/platform-admin").Subrouter()
 	platformAdmin.Use(utils.RequireRole("platform_admin"))
 	platformAdmin.HandleFunc("/colleges", handlers.AddCollege).Methods("POST")
+	platformAdmin.HandleFunc("/colleges/{id}/verifier", handlers.UpdateCollegeVerifier).Methods("PUT")
+	platformAdmin.HandleFunc("/colleges/{id}/categories", handlers.GetCollegeCategoriesPlatform).Methods("GET")
+	platformAdmin.HandleFunc("/colleges/{id}/categories", handlers.CreateCollegeCategoryPlatform).Methods("POST")
+	platformAdmin.HandleFunc("/categories/{id}", handlers.UpdateCategoryPlatform).Methods("PUT")
+	platformAdmin.HandleFunc("/categories/{id}", handlers.DeleteCategoryPlatform).Methods("DELETE")
 	platformAdmin.HandleFunc("/college-admins", handlers.CreateCollegeAdmin).Methods("POST")
 	platformAdmin.HandleFunc("/students", handlers.GetAllStudents).Methods("GET")
 	platformAdmin.HandleFunc("/listings", handlers.GetAllListingsPlatform).Methods("GET")
 	platformAdmin.HandleFunc("/stats", handlers.GetPlatformStats).Methods("GET")
+	platformAdmin.HandleFunc("/ws-metrics", handlers.GetWebSocketMetrics).Methods("GET")
 
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
-	}
-
-	handler := corsMiddleware(router)
-
-	log.Printf("🚀 Server starting on port %s", port)
-	log.Printf("🔌 WebSocket endpoint: ws://localhost:%s/ws", port)
-	log.Printf("📍 Health check: http://localhost:%s/api/health", port)
-	log.Fatal(http.ListenAndServe(":"+port, handler))
+	return router
 }
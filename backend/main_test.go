@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"unilink-backend/handlers"
+	"unilink-backend/storage"
+	"unilink-backend/websocket"
+)
+
+// TestUploadedImageLoadsWithoutToken requests stored images the way an <img> tag
+// does: a plain GET with no Authorization header
+func TestUploadedImageLoadsWithoutToken(t *testing.T) {
+	local, err := storage.NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := storage.Files
+	storage.Files = local
+	t.Cleanup(func() { storage.Files = previous })
+
+	var data bytes.Buffer
+	if err := png.Encode(&data, image.NewRGBA(image.Rect(0, 0, 4, 4))); err != nil {
+		t.Fatal(err)
+	}
+	key := "college_1/0123456789abcdef0123456789abcdef.png"
+	thumbKey := "college_1/0123456789abcdef0123456789abcdef_thumb.jpg"
+	for _, k := range []string{key, thumbKey} {
+		if err := local.Save(k, bytes.NewReader(data.Bytes())); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	router := newRouter(websocket.NewHub())
+	get := func(url string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, url, nil))
+		return rec
+	}
+
+	rec := get(handlers.ImageURL(key))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET %s = %d, want 200: %s", handlers.ImageURL(key), rec.Code, rec.Body)
+	}
+	if got := rec.Header().Get("Content-Type"); got != "image/png" {
+		t.Errorf("Content-Type = %q, want image/png", got)
+	}
+	if !bytes.Equal(rec.Body.Bytes(), data.Bytes()) {
+		t.Error("served image differs from the stored file")
+	}
+
+	if rec := get(handlers.ImageURL(thumbKey)); rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "image/jpeg" {
+		t.Errorf("thumbnail: status %d, Content-Type %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	if rec := get(handlers.ImageURL("college_1/ffffffffffffffffffffffffffffffff.png")); rec.Code != http.StatusNotFound {
+		t.Errorf("missing image: status %d, want 404", rec.Code)
+	}
+	// Anything that isn't a generated key falls through to the authenticated API
+	if rec := get("/api/images/college_1/../secret.png"); rec.Code == http.StatusOK {
+		t.Error("a non-key path was served")
+	}
+}
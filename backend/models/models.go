@@ -8,13 +8,22 @@ import (
 
 // College represents an educational institution in the system
 type College struct {
-	ID          uint           `gorm:"primaryKey" json:"id"`
-	CollegeCode string         `gorm:"uniqueIndex;not null" json:"collegeCode"` // e.g., "VIT", "MIT"
-	Name        string         `gorm:"not null" json:"name"`                    // e.g., "Vellore Institute of Technology"
-	LogoURL     string         `json:"logoUrl"`
-	CreatedAt   time.Time      `json:"createdAt"`
-	UpdatedAt   time.Time      `json:"updatedAt"`
-	DeletedAt   gorm.DeletedAt `gorm:"index" json:"-"`
+	ID          uint   `gorm:"primaryKey" json:"id"`
+	CollegeCode string `gorm:"uniqueIndex;not null" json:"collegeCode"` // e.g., "VIT", "MIT"
+	Name        string `gorm:"not null" json:"name"`                    // e.g., "Vellore Institute of Technology"
+	LogoURL     string `json:"logoUrl"`
+
+	// Student verification backend used at registration
+	VerifierType   string `gorm:"default:'mock'" json:"verifierType"` // "mock", "csv", "http"
+	VerifierConfig string `json:"-"`                                  // Roster file path (csv) or endpoint URL (http); admin settings only
+	VerifierAPIKey string `json:"-"`                                  // Optional bearer token for the http backend
+
+	// Marketplace settings
+	ReservationWindowHours int `gorm:"default:24" json:"reservationWindowHours"` // How long a buyer can hold a listing
+
+	CreatedAt time.Time      `json:"createdAt"`
+	UpdatedAt time.Time      `json:"updatedAt"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
 }
 
 // User represents any user in the system (students, admins, etc.)
@@ -27,13 +36,28 @@ type User struct {
 	StudentID    string `gorm:"uniqueIndex;not null" json:"studentId"`  // e.g., "21BCE1001"
 
 	// Profile fields (Module 1)
-	ProfilePicture string `json:"profilePicture"` // URL to profile image
+	ProfilePicture string `json:"profilePicture"` // Served URL of a "profile" upload
 	Bio            string `gorm:"type:text" json:"bio"`
 	Department     string `json:"department"`                     // e.g., "Computer Science"
 	Semester       int    `json:"semester"`                       // e.g., 4
 	IsPublic       bool   `gorm:"default:true" json:"isPublic"`   // Privacy control
 	Status         string `gorm:"default:'active'" json:"status"` // "active", "suspended"
 
+	// Presence (online/away/offline comes from live WebSocket connections)
+	ShowPresence bool       `gorm:"default:true" json:"showPresence"` // false = always appear offline
+	LastSeenAt   *time.Time `json:"lastSeenAt,omitempty"`
+
+	// Suspension details (set by college admins, cleared on reinstatement).
+	// Admin-only: GetCollegeStudents returns them, nothing else serializes them.
+	SuspensionReason string     `json:"-"`
+	SuspendedAt      *time.Time `json:"-"`
+	SuspendedUntil   *time.Time `json:"-"` // nil = until reinstated
+	SuspendedBy      *uint      `json:"-"`
+
+	// Marketplace reputation (kept in sync with UserRating by the rating handlers)
+	RatingAverage float64 `gorm:"default:0" json:"ratingAverage"`
+	RatingCount   int     `gorm:"default:0" json:"ratingCount"`
+
 	// Foreign Key Relationship
 	CollegeID uint    `gorm:"not null" json:"collegeId"`
 	College   College `gorm:"foreignKey:CollegeID" json:"college"` // Preload this for JWT
@@ -50,6 +74,15 @@ type MarketplaceListing struct {
 	Description string  `json:"description"`
 	Price       float64 `gorm:"not null" json:"price"`
 	ImageURL    string  `json:"imageUrl"`
+
+	// Uploaded photos, ordered by Position (ImageURL is kept for older listings)
+	Images []ListingImage `gorm:"foreignKey:ListingID" json:"images,omitempty"`
+
+	// Browsing metadata
+	CategoryID *uint            `gorm:"index" json:"categoryId"` // nil = uncategorized
+	Category   *ListingCategory `gorm:"foreignKey:CategoryID" json:"category,omitempty"`
+	Condition  string           `gorm:"index" json:"condition"` // "new", "like_new", "good", "fair", "poor"
+
 	// *** UPDATED Status field ***
 	Status string `gorm:"default:'available';index" json:"status"` // "available", "reserved", "sold", "cancelled"
 
@@ -60,15 +93,98 @@ type MarketplaceListing struct {
 	College   College `gorm:"foreignKey:CollegeID" json:"college"`
 
 	// *** NEW Fields for Reservation ***
-	BuyerID       *uint      `gorm:"index" json:"buyerId"`     // Pointer to allow NULL
+	BuyerID       *uint      `gorm:"index" json:"buyerId"`                      // Pointer to allow NULL
 	Buyer         *User      `gorm:"foreignKey:BuyerID" json:"buyer,omitempty"` // Added relation, omitempty for JSON
-	ReservedUntil *time.Time `json:"reservedUntil"` // Pointer to allow NULL
+	ReservedUntil *time.Time `json:"reservedUntil"`                             // Pointer to allow NULL
+	AgreedPrice   *float64   `json:"agreedPrice"`                               // Set when reserved through an accepted offer
 
 	CreatedAt time.Time      `json:"createdAt"`
 	UpdatedAt time.Time      `json:"updatedAt"`
 	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
 }
 
+// ListingCategory is an entry in a college's marketplace taxonomy (e.g. "Books", "Electronics")
+type ListingCategory struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CollegeID uint      `gorm:"not null;uniqueIndex:idx_category_college_slug" json:"collegeId"`
+	Name      string    `gorm:"not null" json:"name"`
+	Slug      string    `gorm:"not null;uniqueIndex:idx_category_college_slug" json:"slug"` // e.g. "books"
+	SortOrder int       `gorm:"default:0" json:"sortOrder"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// ListingOffer is a buyer's price offer on a listing. Buyer and seller take turns
+// proposing an amount until one side accepts or rejects the other's proposal.
+type ListingOffer struct {
+	ID         uint               `gorm:"primaryKey" json:"id"`
+	ListingID  uint               `gorm:"not null;index" json:"listingId"`
+	Listing    MarketplaceListing `gorm:"foreignKey:ListingID" json:"-"`
+	BuyerID    uint               `gorm:"not null;index" json:"buyerId"`
+	Buyer      User               `gorm:"foreignKey:BuyerID" json:"-"`
+	SellerID   uint               `gorm:"not null;index" json:"sellerId"`
+	Amount     float64            `gorm:"not null" json:"amount"`     // Current proposal
+	ProposedBy uint               `gorm:"not null" json:"proposedBy"` // Who made the current proposal; the other party responds
+	Message    string             `json:"message"`
+	Status     string             `gorm:"default:'pending';index" json:"status"` // "pending", "accepted", "rejected", "withdrawn", "closed" (the listing was reserved, sold or deleted)
+	CreatedAt  time.Time          `json:"createdAt"`
+	UpdatedAt  time.Time          `json:"updatedAt"`
+}
+
+// UserRating is the feedback one side of a completed sale leaves for the other
+type UserRating struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	ListingID uint       `gorm:"not null;uniqueIndex:idx_rating_listing_rater" json:"listingId"`
+	RaterID   uint       `gorm:"not null;uniqueIndex:idx_rating_listing_rater" json:"raterId"` // One rating per side per sale
+	Rater     User       `gorm:"foreignKey:RaterID" json:"-"`
+	RateeID   uint       `gorm:"not null;index" json:"rateeId"`
+	RateeRole string     `gorm:"not null" json:"rateeRole"` // "seller" or "buyer"
+	Score     int        `gorm:"not null" json:"score"`     // 1-5
+	Review    string     `gorm:"type:text" json:"review"`
+	CollegeID uint       `gorm:"not null;index" json:"collegeId"`
+	RemovedAt *time.Time `gorm:"index" json:"removedAt,omitempty"` // Set when a college admin removes the review
+	RemovedBy *uint      `json:"removedBy,omitempty"`
+	CreatedAt time.Time  `json:"createdAt"`
+	UpdatedAt time.Time  `json:"updatedAt"`
+}
+
+// Upload is an image stored through the storage backend. Files are served without
+// authentication at their key, which is random and so unguessable (see handlers.ImageRoute).
+type Upload struct {
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	Key          string    `gorm:"uniqueIndex;not null" json:"-"` // Storage key of the original
+	ThumbnailKey string    `json:"-"`
+	OwnerID      uint      `gorm:"not null;index" json:"ownerId"`
+	CollegeID    uint      `gorm:"not null;index" json:"collegeId"`
+	Purpose      string    `gorm:"not null" json:"purpose"` // "listing", "profile", "group"
+	ContentType  string    `gorm:"not null" json:"contentType"`
+	Size         int64     `json:"size"`
+	Width        int       `json:"width"`
+	Height       int       `json:"height"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+// ListingImage attaches an upload to a listing at a given position
+type ListingImage struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	ListingID uint      `gorm:"not null;index" json:"listingId"`
+	UploadID  uint      `gorm:"not null" json:"uploadId"`
+	Upload    Upload    `gorm:"foreignKey:UploadID" json:"-"`
+	Position  int       `gorm:"not null" json:"position"` // 0 = cover image
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// ListingChange records one field edit on a listing, so buyers can see price drops
+type ListingChange struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	ListingID uint      `gorm:"not null;index:idx_listing_change_listing" json:"listingId"`
+	ChangedBy uint      `gorm:"not null" json:"changedBy"`
+	Field     string    `gorm:"not null;index:idx_listing_change_listing" json:"field"` // "title", "description", "price", "imageUrl", "imageIds", "categoryId", "condition"
+	OldValue  string    `json:"oldValue"`
+	NewValue  string    `json:"newValue"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
 // Announcement represents official notices from college admins (Module 2)
 type Announcement struct {
 	ID       uint   `gorm:"primaryKey" json:"id"`
@@ -91,6 +207,52 @@ type Announcement struct {
 	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
 }
 
+// Session represents a login on one device. The access token carries the
+// session ID, and the refresh token (stored only as a hash) rotates on every use.
+type Session struct {
+	ID                uint       `gorm:"primaryKey" json:"id"`
+	UserID            uint       `gorm:"not null;index" json:"userId"`
+	User              User       `gorm:"foreignKey:UserID" json:"-"`
+	RefreshTokenHash  string     `gorm:"uniqueIndex;not null" json:"-"`
+	PreviousTokenHash string     `gorm:"index" json:"-"` // Last rotated-out token, used to detect reuse
+	UserAgent         string     `json:"userAgent"`
+	IPAddress         string     `json:"ipAddress"`
+	ExpiresAt         time.Time  `gorm:"not null" json:"expiresAt"`
+	LastUsedAt        time.Time  `json:"lastUsedAt"`
+	RevokedAt         *time.Time `gorm:"index" json:"revokedAt"` // nil = active
+	CreatedAt         time.Time  `json:"createdAt"`
+	UpdatedAt         time.Time  `json:"updatedAt"`
+}
+
+// WebSocketTicket is a short-lived, single-use credential for opening /ws, so the
+// access token never has to appear in a URL. Only the hash is stored.
+type WebSocketTicket struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	TokenHash string    `gorm:"uniqueIndex;not null" json:"-"`
+	UserID    uint      `gorm:"not null" json:"userId"`
+	SessionID uint      `gorm:"not null" json:"sessionId"` // The session the ticket was issued under
+	ExpiresAt time.Time `gorm:"not null;index" json:"expiresAt"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// BackplaneEvent holds a WebSocket backplane message too large for a Postgres
+// NOTIFY payload. Rows are only needed for a minute, until every instance has read them.
+type BackplaneEvent struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Payload   string    `gorm:"type:text;not null" json:"payload"`
+	CreatedAt time.Time `gorm:"index" json:"createdAt"`
+}
+
+// HubPresence records that a user is connected to a backend instance, so presence is
+// shared when several instances run. Each instance refreshes its rows periodically;
+// rows that stop being refreshed belong to an instance that is gone.
+type HubPresence struct {
+	InstanceID string    `gorm:"primaryKey;size:32" json:"instanceId"`
+	UserID     uint      `gorm:"primaryKey;index" json:"userId"`
+	Away       bool      `gorm:"not null;default:false" json:"away"`
+	UpdatedAt  time.Time `gorm:"index" json:"updatedAt"`
+}
+
 // JWTClaims represents the custom claims we'll store in the JWT token
 // This is not a database model, but it's related to User
 type JWTClaims struct {
@@ -123,7 +285,7 @@ type Group struct {
 	Name        string `gorm:"not null" json:"name"`
 	Description string `gorm:"type:text" json:"description"`
 	Type        string `gorm:"not null" json:"type"` // "auto" (dept/sem) or "public" (clubs)
-	Avatar      string `json:"avatar"`               // Group image URL
+	Avatar      string `json:"avatar"`               // Served URL of a "group" upload
 
 	// College isolation
 	CollegeID uint    `gorm:"not null" json:"collegeId"`
@@ -174,9 +336,16 @@ type Message struct {
 	// For Group messages
 	GroupID *uint `json:"groupId,omitempty"` // Only for group messages
 
-	// Message status
-	IsRead    bool `gorm:"default:false" json:"isRead"`
-	IsDeleted bool `gorm:"default:false" json:"isDeleted"`
+	// Replies (the quoted parent message in the same conversation)
+	ReplyToID *uint    `gorm:"index" json:"replyToId,omitempty"`
+	ReplyTo   *Message `gorm:"foreignKey:ReplyToID" json:"replyTo,omitempty"`
+
+	// Files sent with the message (Type is "image" or "file" when present)
+	Attachments []MessageAttachment `gorm:"foreignKey:MessageID" json:"attachments,omitempty"`
+
+	// Message status (read state is tracked per user in ConversationRead)
+	IsDeleted bool       `gorm:"default:false" json:"isDeleted"`
+	EditedAt  *time.Time `json:"editedAt,omitempty"` // Set on the latest edit; prior versions are in MessageEdit
 
 	// College isolation (for security)
 	CollegeID uint `gorm:"not null" json:"collegeId"`
@@ -184,4 +353,71 @@ type Message struct {
 	CreatedAt time.Time      `json:"createdAt"`
 	UpdatedAt time.Time      `json:"updatedAt"`
 	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
-}
\ No newline at end of file
+}
+
+// MessageAttachment is a file sent in a conversation. It is uploaded first and
+// linked to its message when the message is sent (MessageID stays nil until then).
+type MessageAttachment struct {
+	ID             uint      `gorm:"primaryKey" json:"id"`
+	MessageID      *uint     `gorm:"index" json:"messageId,omitempty"`
+	ConversationID string    `gorm:"not null;index" json:"conversationId"`
+	UploaderID     uint      `gorm:"not null;index" json:"uploaderId"`
+	CollegeID      uint      `gorm:"not null" json:"collegeId"`
+	Key            string    `gorm:"not null;uniqueIndex" json:"-"` // Storage key
+	ThumbnailKey   string    `json:"-"`                             // Images only
+	FileName       string    `gorm:"not null" json:"fileName"`      // Original name, for downloads
+	ContentType    string    `gorm:"not null" json:"contentType"`
+	Size           int64     `json:"size"`
+	Width          int       `json:"width,omitempty"`
+	Height         int       `json:"height,omitempty"`
+	CreatedAt      time.Time `json:"createdAt"`
+}
+
+// MessageEdit keeps the content a message had before an edit
+type MessageEdit struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	MessageID uint      `gorm:"not null;index" json:"messageId"`
+	Content   string    `gorm:"type:text;not null" json:"content"` // Content before this edit
+	EditedBy  uint      `gorm:"not null" json:"editedBy"`
+	CreatedAt time.Time `json:"createdAt"` // When the edit replaced this content
+}
+
+// MessageReaction is one user's emoji reaction to a message
+type MessageReaction struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	MessageID uint      `gorm:"not null;uniqueIndex:idx_reaction_message_user_emoji" json:"messageId"`
+	UserID    uint      `gorm:"not null;uniqueIndex:idx_reaction_message_user_emoji" json:"userId"`
+	Emoji     string    `gorm:"size:32;not null;uniqueIndex:idx_reaction_message_user_emoji" json:"emoji"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// Conversation is the stored summary of a DM or group chat, kept up to date as
+// messages are sent so the conversation list doesn't scan messages
+type Conversation struct {
+	ID             string     `gorm:"primaryKey;size:64" json:"id"` // Same format as Message.ConversationID
+	Type           string     `gorm:"not null" json:"type"`         // "dm" or "group"
+	LastMessageID  *uint      `json:"lastMessageId,omitempty"`
+	LastActivityAt *time.Time `json:"lastActivityAt,omitempty"` // When the last message was sent
+	CreatedAt      time.Time  `json:"createdAt"`
+	UpdatedAt      time.Time  `json:"updatedAt"`
+}
+
+// ConversationMember is one user's entry in their conversation list
+type ConversationMember struct {
+	ID             uint       `gorm:"primaryKey" json:"id"`
+	ConversationID string     `gorm:"size:64;not null;uniqueIndex:idx_conversation_member_user" json:"conversationId"`
+	UserID         uint       `gorm:"not null;uniqueIndex:idx_conversation_member_user" json:"userId"`
+	UnreadCount    int        `gorm:"not null;default:0" json:"unreadCount"`
+	LastActivityAt *time.Time `json:"lastActivityAt,omitempty"` // Copy of Conversation.LastActivityAt for sorting
+	CreatedAt      time.Time  `json:"createdAt"`
+}
+
+// ConversationRead is how far one member has read in a conversation. Messages
+// with an ID up to LastReadMessageID count as read for that user.
+type ConversationRead struct {
+	ID                uint      `gorm:"primaryKey" json:"id"`
+	ConversationID    string    `gorm:"not null;uniqueIndex:idx_conversation_read_user" json:"conversationId"`
+	UserID            uint      `gorm:"not null;uniqueIndex:idx_conversation_read_user;index" json:"userId"`
+	LastReadMessageID uint      `gorm:"not null;default:0" json:"lastReadMessageId"`
+	UpdatedAt         time.Time `json:"updatedAt"`
+}
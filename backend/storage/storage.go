@@ -0,0 +1,124 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Supported STORAGE_BACKEND values
+const (
+	BackendLocal = "local"
+	BackendS3    = "s3" // Reserved for an S3-compatible backend
+)
+
+// ErrNotFound is returned by Open for keys that don't exist
+var ErrNotFound = errors.New("file not found")
+
+// Storage saves and serves uploaded files by key (e.g. "college_1/ab12cd.jpg").
+// Keys are generated by the server and never come from the client.
+type Storage interface {
+	Save(key string, r io.Reader) error
+	Open(key string) (io.ReadCloser, error)
+	Delete(key string) error
+}
+
+// Files is the storage backend used by the upload handlers
+var Files Storage
+
+// InitStorage selects the backend from STORAGE_BACKEND (default "local")
+func InitStorage() {
+	backend := strings.ToLower(os.Getenv("STORAGE_BACKEND"))
+	switch backend {
+	case "", BackendLocal:
+		dir := os.Getenv("UPLOAD_DIR")
+		if dir == "" {
+			dir = "uploads"
+		}
+		local, err := NewLocalStorage(dir)
+		if err != nil {
+			log.Fatal("Failed to initialise upload storage:", err)
+		}
+		Files = local
+		log.Printf("✅ File storage: local (%s)", dir)
+	default:
+		log.Fatalf("Unsupported STORAGE_BACKEND %q (supported: %s)", backend, BackendLocal)
+	}
+}
+
+// LocalStorage keeps files on the local filesystem under Root
+type LocalStorage struct {
+	Root string
+}
+
+// NewLocalStorage creates the root directory if needed
+func NewLocalStorage(root string) (*LocalStorage, error) {
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, err
+	}
+	return &LocalStorage{Root: root}, nil
+}
+
+// path maps a key to a file path, rejecting keys that escape Root
+func (s *LocalStorage) path(key string) (string, error) {
+	clean := filepath.Clean("/" + key)
+	if clean == "/" || strings.Contains(key, "..") {
+		return "", fmt.Errorf("invalid storage key %q", key)
+	}
+	return filepath.Join(s.Root, clean), nil
+}
+
+// Save writes the file atomically (temp file + rename)
+func (s *LocalStorage) Save(key string, r io.Reader) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // No-op after a successful rename
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Open returns the file; the *os.File also implements io.ReadSeeker for range requests
+func (s *LocalStorage) Open(key string) (io.ReadCloser, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return f, err
+}
+
+// Delete removes the file; deleting a missing key is not an error
+func (s *LocalStorage) Delete(key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package storage
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+
+	// Register decoders for the accepted upload types
+	_ "image/gif"
+	_ "image/png"
+)
+
+// ThumbnailSize is the longest edge of generated thumbnails, in pixels
+const ThumbnailSize = 320
+
+// MaxImagePixels caps width×height of uploaded images. A small compressed file can
+// declare huge dimensions, and decoding allocates the full bitmap.
+const MaxImagePixels = 40_000_000
+
+// ErrImageTooLarge is returned by DecodeImage for images over MaxImagePixels
+var ErrImageTooLarge = errors.New("image dimensions too large")
+
+// DecodeImage decodes an uploaded JPEG, PNG or GIF. The header is checked first,
+// so oversized images are rejected before any pixel data is allocated.
+func DecodeImage(data []byte) (image.Image, error) {
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if config.Width <= 0 || config.Height <= 0 {
+		return nil, fmt.Errorf("invalid image dimensions %dx%d", config.Width, config.Height)
+	}
+	if int64(config.Width)*int64(config.Height) > MaxImagePixels {
+		return nil, ErrImageTooLarge
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	return img, err
+}
+
+// Thumbnail scales img so its longest edge is at most ThumbnailSize and
+// encodes it as JPEG. Each output pixel averages the source pixels it covers.
+// The standard library has no resampler, hence the hand-rolled box filter.
+func Thumbnail(img image.Image) ([]byte, error) {
+	src := img.Bounds()
+	srcW, srcH := src.Dx(), src.Dy()
+
+	dstW, dstH := srcW, srcH
+	if srcW >= srcH && srcW > ThumbnailSize {
+		dstW, dstH = ThumbnailSize, max(1, srcH*ThumbnailSize/srcW)
+	} else if srcH > srcW && srcH > ThumbnailSize {
+		dstW, dstH = max(1, srcW*ThumbnailSize/srcH), ThumbnailSize
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dstW, dstH))
+	for y := 0; y < dstH; y++ {
+		y0 := src.Min.Y + y*srcH/dstH
+		y1 := max(y0+1, src.Min.Y+(y+1)*srcH/dstH)
+		for x := 0; x < dstW; x++ {
+			x0 := src.Min.X + x*srcW/dstW
+			x1 := max(x0+1, src.Min.X+(x+1)*srcW/dstW)
+
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := img.At(sx, sy).RGBA()
+					r, g, b, a = r+uint64(cr), g+uint64(cg), b+uint64(cb), a+uint64(ca)
+					n++
+				}
+			}
+			// JPEG has no alpha: flatten transparent pixels onto white (colours are premultiplied)
+			bg := 0xffff - a/n
+			dst.Set(x, y, color.RGBA64{
+				R: uint16(r/n + bg), G: uint16(g/n + bg), B: uint16(b/n + bg), A: 0xffff,
+			})
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, dst, &jpeg.Options{Quality: 80}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
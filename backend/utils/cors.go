@@ -2,24 +2,54 @@ package utils
 
 import (
 	"net/http"
+	"os"
+	"strings"
 
 	"github.com/gorilla/handlers"
 )
 
+// defaultAllowedOrigins are used when ALLOWED_ORIGINS is not set
+var defaultAllowedOrigins = []string{
+	"http://localhost:3000", // React dev server
+	"http://localhost:5173", // Vite dev server
+}
+
+// AllowedOrigins returns the frontend URLs allowed to call the API and open WebSockets
+// (ALLOWED_ORIGINS, comma-separated, e.g. "https://unilink.example.edu,http://localhost:5173")
+func AllowedOrigins() []string {
+	var origins []string
+	for _, origin := range strings.Split(os.Getenv("ALLOWED_ORIGINS"), ",") {
+		if origin = strings.TrimRight(strings.TrimSpace(origin), "/"); origin != "" {
+			origins = append(origins, origin)
+		}
+	}
+	if len(origins) == 0 {
+		return defaultAllowedOrigins
+	}
+	return origins
+}
+
+// IsOriginAllowed reports whether a request's Origin header is one of AllowedOrigins
+func IsOriginAllowed(origin string) bool {
+	for _, allowed := range AllowedOrigins() {
+		if strings.EqualFold(origin, allowed) {
+			return true
+		}
+	}
+	return false
+}
+
 // SetupCORS configures CORS middleware for the application
 func SetupCORS() func(http.Handler) http.Handler {
 	// Allowed origins (frontend URLs)
-	allowedOrigins := handlers.AllowedOrigins([]string{
-		"http://localhost:3000",          // React dev server
-		"http://localhost:5173",          // Vite dev server
-		"https://unilink.yourdomain.com", // Production frontend (update this)
-	})
+	allowedOrigins := handlers.AllowedOrigins(AllowedOrigins())
 
 	// Allowed HTTP methods
 	allowedMethods := handlers.AllowedMethods([]string{
 		"GET",
 		"POST",
 		"PUT",
+		"PATCH",
 		"DELETE",
 		"OPTIONS",
 	})
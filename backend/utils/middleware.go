@@ -48,6 +48,12 @@ func ValidateToken(next http.Handler) http.Handler {
 			return
 		}
 
+		// Reject tokens whose session was logged out or revoked
+		if !IsSessionActive(claims) {
+			respondWithError(w, http.StatusUnauthorized, "Session has been revoked")
+			return
+		}
+
 		// Attach claims to request context
 		ctx := context.WithValue(r.Context(), UserClaimsKey, claims)
 		next.ServeHTTP(w, r.WithContext(ctx))
@@ -0,0 +1,254 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"unilink-backend/db"
+	"unilink-backend/models"
+)
+
+// defaultRefreshTokenTTL is used when REFRESH_TOKEN_TTL is not set
+const defaultRefreshTokenTTL = 30 * 24 * time.Hour
+
+var (
+	// ErrInvalidRefreshToken is returned for unknown, expired or revoked refresh tokens
+	ErrInvalidRefreshToken = errors.New("invalid or expired refresh token")
+	// ErrRefreshTokenReused is returned when an already-rotated token is presented again.
+	// The whole session is revoked because the token has most likely been stolen.
+	ErrRefreshTokenReused = errors.New("refresh token reuse detected")
+	// ErrUserSuspended is returned when a suspended user tries to obtain tokens
+	ErrUserSuspended = errors.New("account suspended")
+)
+
+// RefreshTokenTTL returns how long a session can go without being refreshed (REFRESH_TOKEN_TTL, e.g. "720h")
+func RefreshTokenTTL() time.Duration {
+	if ttl, err := time.ParseDuration(os.Getenv("REFRESH_TOKEN_TTL")); err == nil && ttl > 0 {
+		return ttl
+	}
+	return defaultRefreshTokenTTL
+}
+
+// CreateSession starts a new session for the user and returns a fresh token pair
+func CreateSession(user *models.User, r *http.Request) (accessToken string, refreshToken string, err error) {
+	refreshToken, err = generateRefreshToken()
+	if err != nil {
+		return "", "", err
+	}
+
+	now := time.Now()
+	session := models.Session{
+		UserID:           user.ID,
+		RefreshTokenHash: hashToken(refreshToken),
+		UserAgent:        r.UserAgent(),
+		IPAddress:        clientIP(r),
+		ExpiresAt:        now.Add(RefreshTokenTTL()),
+		LastUsedAt:       now,
+	}
+	if err := db.DB.Create(&session).Error; err != nil {
+		return "", "", err
+	}
+
+	accessToken, err = GenerateJWT(user, session.ID)
+	if err != nil {
+		return "", "", err
+	}
+	return accessToken, refreshToken, nil
+}
+
+// RotateSession exchanges a refresh token for a new token pair.
+// The presented token is invalidated; presenting it again revokes the session.
+func RotateSession(refreshToken string) (accessToken string, newRefreshToken string, user *models.User, err error) {
+	oldHash := hashToken(refreshToken)
+	newRefreshToken, err = generateRefreshToken()
+	if err != nil {
+		return "", "", nil, err
+	}
+
+	now := time.Now()
+	// Conditional update so two concurrent refreshes with the same token can't both succeed
+	result := db.DB.Model(&models.Session{}).
+		Where("refresh_token_hash = ? AND revoked_at IS NULL AND expires_at > ?", oldHash, now).
+		Updates(map[string]interface{}{
+			"refresh_token_hash":  hashToken(newRefreshToken),
+			"previous_token_hash": oldHash,
+			"last_used_at":        now,
+			"expires_at":          now.Add(RefreshTokenTTL()),
+		})
+	if result.Error != nil {
+		return "", "", nil, result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		// Reuse of a rotated-out token: kill the session for both holders
+		reused := db.DB.Model(&models.Session{}).
+			Where("previous_token_hash = ? AND revoked_at IS NULL", oldHash).
+			Update("revoked_at", now)
+		if reused.Error == nil && reused.RowsAffected > 0 {
+			return "", "", nil, ErrRefreshTokenReused
+		}
+		return "", "", nil, ErrInvalidRefreshToken
+	}
+
+	var session models.Session
+	if err := db.DB.Preload("User.College").Where("refresh_token_hash = ?", hashToken(newRefreshToken)).First(&session).Error; err != nil {
+		return "", "", nil, err
+	}
+
+	if err := EnsureUserActive(&session.User); err != nil {
+		db.DB.Model(&session).Update("revoked_at", now)
+		return "", "", &session.User, err
+	}
+
+	accessToken, err = GenerateJWT(&session.User, session.ID)
+	if err != nil {
+		return "", "", nil, err
+	}
+	return accessToken, newRefreshToken, &session.User, nil
+}
+
+// RevokeSession ends a single session belonging to userID
+func RevokeSession(userID uint, sessionID uint) error {
+	return db.DB.Model(&models.Session{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", sessionID, userID).
+		Update("revoked_at", time.Now()).Error
+}
+
+// RevokeAllSessions ends every active session for a user ("log out all devices")
+func RevokeAllSessions(userID uint) (int64, error) {
+	result := db.DB.Model(&models.Session{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now())
+	return result.RowsAffected, result.Error
+}
+
+// IsSessionActive reports whether the session referenced by an access token is still valid
+// and its user has not been suspended
+func IsSessionActive(claims *CustomClaims) bool {
+	if claims.SessionID == 0 {
+		return false // Tokens issued before sessions existed
+	}
+	var count int64
+	db.DB.Model(&models.Session{}).
+		Joins("JOIN users ON users.id = sessions.user_id AND users.deleted_at IS NULL").
+		Where("sessions.id = ? AND sessions.user_id = ? AND sessions.revoked_at IS NULL AND sessions.expires_at > ?",
+			claims.SessionID, claims.UserID, time.Now()).
+		Where("users.status = ?", "active").
+		Count(&count)
+	return count > 0
+}
+
+// EnsureUserActive returns ErrUserSuspended for suspended users.
+// A suspension whose end date has passed is lifted here.
+func EnsureUserActive(user *models.User) error {
+	if user.Status != "suspended" {
+		return nil
+	}
+	if user.SuspendedUntil == nil || time.Now().Before(*user.SuspendedUntil) {
+		return ErrUserSuspended
+	}
+
+	if err := db.DB.Model(user).Updates(map[string]interface{}{
+		"status":            "active",
+		"suspension_reason": "",
+		"suspended_at":      nil,
+		"suspended_until":   nil,
+		"suspended_by":      nil,
+	}).Error; err != nil {
+		return err
+	}
+	user.Status = "active"
+	user.SuspensionReason = ""
+	user.SuspendedAt = nil
+	user.SuspendedUntil = nil
+	user.SuspendedBy = nil
+	return nil
+}
+
+// generateRefreshToken returns a random, URL-safe opaque token
+func generateRefreshToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// hashToken stores refresh tokens as SHA-256 so a database leak can't be replayed
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// TrustedProxies returns the reverse proxies whose X-Forwarded-For header is believed
+// (TRUSTED_PROXIES, comma-separated IPs or CIDRs). None by default.
+func TrustedProxies() []*net.IPNet {
+	var proxies []*net.IPNet
+	for _, entry := range strings.Split(os.Getenv("TRUSTED_PROXIES"), ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if !strings.Contains(entry, "/") {
+			if ip := net.ParseIP(entry); ip != nil && ip.To4() != nil {
+				entry += "/32"
+			} else {
+				entry += "/128"
+			}
+		}
+		if _, network, err := net.ParseCIDR(entry); err == nil {
+			proxies = append(proxies, network)
+		}
+	}
+	return proxies
+}
+
+// isTrustedProxy reports whether ip belongs to one of the proxies
+func isTrustedProxy(ip string, proxies []*net.IPNet) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, network := range proxies {
+		if network.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientIP returns the address a request came from. X-Forwarded-For is only used when
+// the connection comes from a trusted proxy, since anyone else can send any value.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	proxies := TrustedProxies()
+	if !isTrustedProxy(host, proxies) {
+		return host
+	}
+
+	// Each proxy appends the address it saw, so walk back from the nearest hop
+	// and take the first one that isn't ours
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if net.ParseIP(hop) == nil {
+			break
+		}
+		if !isTrustedProxy(hop, proxies) {
+			return hop
+		}
+	}
+	return host
+}
@@ -0,0 +1,44 @@
+package utils
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	t.Setenv("TRUSTED_PROXIES", "10.0.0.0/8, 192.168.1.5")
+
+	cases := []struct {
+		name       string
+		remoteAddr string
+		forwarded  string
+		want       string
+	}{
+		{"direct client", "203.0.113.7:5000", "", "203.0.113.7"},
+		{"forged header from an untrusted peer", "203.0.113.7:5000", "1.2.3.4", "203.0.113.7"},
+		{"trusted proxy", "10.1.2.3:443", "198.51.100.20", "198.51.100.20"},
+		{"proxy chain", "192.168.1.5:443", "6.6.6.6, 198.51.100.20, 10.4.4.4", "198.51.100.20"},
+		{"trusted proxy without header", "10.1.2.3:443", "", "10.1.2.3"},
+		{"malformed hop", "10.1.2.3:443", "198.51.100.20, not-an-ip", "10.1.2.3"},
+	}
+	for _, c := range cases {
+		r := httptest.NewRequest("POST", "/api/login", nil)
+		r.RemoteAddr = c.remoteAddr
+		if c.forwarded != "" {
+			r.Header.Set("X-Forwarded-For", c.forwarded)
+		}
+		if got := clientIP(r); got != c.want {
+			t.Errorf("%s: clientIP = %q, want %q", c.name, got, c.want)
+		}
+	}
+}
+
+func TestClientIPWithoutTrustedProxies(t *testing.T) {
+	t.Setenv("TRUSTED_PROXIES", "")
+	r := httptest.NewRequest("POST", "/api/login", nil)
+	r.RemoteAddr = "10.1.2.3:443"
+	r.Header.Set("X-Forwarded-For", "198.51.100.20")
+	if got := clientIP(r); got != "10.1.2.3" {
+		t.Errorf("clientIP = %q, want the peer address", got)
+	}
+}
@@ -19,11 +19,23 @@ type CustomClaims struct {
 	CollegeCode    string `json:"collegeCode"`
 	CollegeLogoURL string `json:"collegeLogoUrl"`
 	Name           string `json:"name"` // *** Already Added in previous step's model update ***
+	SessionID      uint   `json:"sid"`  // Server-side session this token belongs to
 	jwt.RegisteredClaims
 }
 
-// GenerateJWT creates a new JWT token for an authenticated user
-func GenerateJWT(user *models.User) (string, error) {
+// defaultAccessTokenTTL is used when ACCESS_TOKEN_TTL is not set
+const defaultAccessTokenTTL = 15 * time.Minute
+
+// AccessTokenTTL returns how long access tokens stay valid (ACCESS_TOKEN_TTL, e.g. "15m")
+func AccessTokenTTL() time.Duration {
+	if ttl, err := time.ParseDuration(os.Getenv("ACCESS_TOKEN_TTL")); err == nil && ttl > 0 {
+		return ttl
+	}
+	return defaultAccessTokenTTL
+}
+
+// GenerateJWT creates a new short-lived access token tied to a session
+func GenerateJWT(user *models.User, sessionID uint) (string, error) {
 	jwtSecret := os.Getenv("JWT_SECRET")
 	if jwtSecret == "" {
 		return "", fmt.Errorf("JWT_SECRET not set in environment")
@@ -36,10 +48,11 @@ func GenerateJWT(user *models.User) (string, error) {
 		Role:           user.Role,
 		CollegeID:      user.CollegeID,
 		CollegeCode:    user.College.CollegeCode, // Assumes user.College is preloaded
-		CollegeLogoURL: user.College.LogoURL,   // Assumes user.College is preloaded
-		Name:           user.Name,             // *** ADDED user.Name here ***
+		CollegeLogoURL: user.College.LogoURL,     // Assumes user.College is preloaded
+		Name:           user.Name,                // *** ADDED user.Name here ***
+		SessionID:      sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)), // Token valid for 24 hours
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(AccessTokenTTL())), // Short-lived, renewed via refresh token
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			Issuer:    "unilink-backend",
 			Subject:   fmt.Sprintf("%d", user.ID), // Good practice to add Subject
@@ -90,4 +103,4 @@ func ValidateJWT(tokenString string) (*CustomClaims, error) {
 	}
 
 	return nil, fmt.Errorf("invalid token")
-}
\ No newline at end of file
+}
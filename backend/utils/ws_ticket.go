@@ -0,0 +1,67 @@
+package utils
+
+import (
+	"errors"
+	"os"
+	"time"
+
+	"unilink-backend/db"
+	"unilink-backend/models"
+
+	"gorm.io/gorm/clause"
+)
+
+// defaultWebSocketTicketTTL is used when WS_TICKET_TTL is not set
+const defaultWebSocketTicketTTL = 30 * time.Second
+
+// ErrInvalidWebSocketTicket is returned for unknown, expired or already used tickets
+var ErrInvalidWebSocketTicket = errors.New("invalid or expired WebSocket ticket")
+
+// WebSocketTicketTTL returns how long a ticket can wait before being used (WS_TICKET_TTL, e.g. "30s")
+func WebSocketTicketTTL() time.Duration {
+	if ttl, err := time.ParseDuration(os.Getenv("WS_TICKET_TTL")); err == nil && ttl > 0 {
+		return ttl
+	}
+	return defaultWebSocketTicketTTL
+}
+
+// IssueWebSocketTicket creates a one-time ticket for opening a WebSocket under the
+// caller's session
+func IssueWebSocketTicket(claims *CustomClaims) (string, error) {
+	ticket, err := generateRefreshToken()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	// Expired tickets can never be redeemed; clear them out as new ones are issued
+	db.DB.Where("expires_at < ?", now).Delete(&models.WebSocketTicket{})
+
+	record := models.WebSocketTicket{
+		TokenHash: hashToken(ticket),
+		UserID:    claims.UserID,
+		SessionID: claims.SessionID,
+		ExpiresAt: now.Add(WebSocketTicketTTL()),
+	}
+	if err := db.DB.Create(&record).Error; err != nil {
+		return "", err
+	}
+	return ticket, nil
+}
+
+// RedeemWebSocketTicket consumes a ticket and returns the user and session it was
+// issued for. The caller still has to check the session with IsSessionActive.
+func RedeemWebSocketTicket(ticket string) (*CustomClaims, error) {
+	// Deleting on use keeps the ticket single-use even if two connections race for it
+	var redeemed []models.WebSocketTicket
+	result := db.DB.Clauses(clause.Returning{}).
+		Where("token_hash = ? AND expires_at > ?", hashToken(ticket), time.Now()).
+		Delete(&redeemed)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if len(redeemed) == 0 {
+		return nil, ErrInvalidWebSocketTicket
+	}
+	return &CustomClaims{UserID: redeemed[0].UserID, SessionID: redeemed[0].SessionID}, nil
+}
@@ -0,0 +1,80 @@
+// backend/websocket/backplane.go
+package websocket
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+)
+
+// Backplane fans Hub traffic out between backend instances, so users connected to
+// different replicas still see each other's events. Every instance delivers what it
+// receives to its own connections only.
+type Backplane interface {
+	// Publish sends a message to every instance, including (possibly) this one
+	Publish(msg BackplaneMessage) error
+	// Listen passes incoming messages to receive until Close is called
+	Listen(receive func(BackplaneMessage))
+	Close() error
+}
+
+// BackplaneMessage is what instances exchange: an addressed envelope, or a forced
+// disconnect (a logout has to close the user's sockets on every instance)
+type BackplaneMessage struct {
+	Origin     string               `json:"origin"` // Sending instance; it ignores its own messages
+	Envelope   *Envelope            `json:"envelope,omitempty"`
+	Disconnect *BackplaneDisconnect `json:"disconnect,omitempty"`
+}
+
+// BackplaneDisconnect mirrors Hub.Disconnect
+type BackplaneDisconnect struct {
+	UserID    uint `json:"userId"`
+	SessionID uint `json:"sessionId"` // 0 = all of the user's connections
+}
+
+// SetBackplane connects the Hub to the other instances. Call it before Run.
+func (h *Hub) SetBackplane(backplane Backplane) {
+	h.backplane = backplane
+	go backplane.Listen(h.receiveRemote)
+	go h.runPresenceSync()
+	log.Printf("WebSocket Hub %s using backplane %T", h.instanceID, backplane)
+}
+
+// publishRemote sends a message to the other instances, if there are any.
+// Local delivery has already happened, so a failure here only affects other replicas.
+func (h *Hub) publishRemote(msg BackplaneMessage) {
+	if h.backplane == nil {
+		return
+	}
+	msg.Origin = h.instanceID
+	if err := h.backplane.Publish(msg); err != nil {
+		h.metrics.backplaneErrors.Add(1)
+		log.Printf("Error publishing to backplane: %v", err)
+		return
+	}
+	h.metrics.backplanePublished.Add(1)
+}
+
+// receiveRemote delivers a message from another instance to local connections
+func (h *Hub) receiveRemote(msg BackplaneMessage) {
+	if msg.Origin == h.instanceID {
+		return // Already delivered locally
+	}
+	h.metrics.backplaneReceived.Add(1)
+
+	if msg.Envelope != nil {
+		h.enqueue(*msg.Envelope)
+	}
+	if msg.Disconnect != nil {
+		h.disconnect <- disconnectRequest{userID: msg.Disconnect.UserID, sessionID: msg.Disconnect.SessionID}
+	}
+}
+
+// newInstanceID returns a random ID that tells this process's backplane messages apart
+func newInstanceID() string {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		log.Printf("Error generating hub instance ID: %v", err)
+	}
+	return hex.EncodeToString(buf)
+}
@@ -0,0 +1,142 @@
+// backend/websocket/backplane_postgres.go
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"unilink-backend/db"
+	"unilink-backend/models"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	// defaultBackplaneChannel is the LISTEN/NOTIFY channel when none is configured
+	defaultBackplaneChannel = "unilink_hub"
+
+	// Postgres caps NOTIFY payloads at 8000 bytes. Bigger messages are stored in
+	// backplane_events and the notification carries "ref:<id>" instead.
+	maxNotifyPayload   = 7900
+	backplaneRefPrefix = "ref:"
+	// How long stored messages are kept for instances to read them
+	backplaneEventRetention = time.Minute
+
+	// Wait before re-establishing a dropped LISTEN connection
+	backplaneRetryDelay = 5 * time.Second
+)
+
+// PostgresBackplane fans messages out with Postgres LISTEN/NOTIFY. Publishing goes
+// through the shared pool; listening holds one dedicated connection.
+// Messages sent while the listener is reconnecting are missed; clients backfill
+// chats with GET /messages/sync.
+type PostgresBackplane struct {
+	dsn     string
+	channel string
+	ctx     context.Context
+	cancel  context.CancelFunc
+}
+
+// NewPostgresBackplane creates a backplane on the given database and channel
+// (defaultBackplaneChannel when empty). Every instance must use the same channel.
+func NewPostgresBackplane(dsn string, channel string) *PostgresBackplane {
+	if channel == "" {
+		channel = defaultBackplaneChannel
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &PostgresBackplane{dsn: dsn, channel: channel, ctx: ctx, cancel: cancel}
+}
+
+// Publish sends a message with pg_notify
+func (b *PostgresBackplane) Publish(msg BackplaneMessage) error {
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	notification := string(payload)
+	if len(payload) > maxNotifyPayload {
+		event := models.BackplaneEvent{Payload: notification}
+		if err := db.DB.Create(&event).Error; err != nil {
+			return err
+		}
+		notification = backplaneRefPrefix + strconv.FormatUint(uint64(event.ID), 10)
+
+		// Every listener has read older rows by now
+		db.DB.Where("created_at < ?", time.Now().Add(-backplaneEventRetention)).Delete(&models.BackplaneEvent{})
+	}
+
+	return db.DB.Exec("SELECT pg_notify(?, ?)", b.channel, notification).Error
+}
+
+// Listen receives notifications until Close, reconnecting when the connection drops
+func (b *PostgresBackplane) Listen(receive func(BackplaneMessage)) {
+	for b.ctx.Err() == nil {
+		err := b.listen(receive)
+		if b.ctx.Err() != nil {
+			return
+		}
+		log.Printf("Backplane listener stopped, reconnecting in %v: %v", backplaneRetryDelay, err)
+		select {
+		case <-time.After(backplaneRetryDelay):
+		case <-b.ctx.Done():
+		}
+	}
+}
+
+// listen runs one LISTEN session and returns when it fails
+func (b *PostgresBackplane) listen(receive func(BackplaneMessage)) error {
+	conn, err := pgx.Connect(b.ctx, b.dsn)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(context.Background())
+
+	if _, err := conn.Exec(b.ctx, "LISTEN "+pgx.Identifier{b.channel}.Sanitize()); err != nil {
+		return err
+	}
+	log.Printf("📡 Backplane listening on Postgres channel %q", b.channel)
+
+	for {
+		notification, err := conn.WaitForNotification(b.ctx)
+		if err != nil {
+			return err
+		}
+		msg, err := b.decode(notification.Payload)
+		if err != nil {
+			log.Printf("Error decoding backplane message: %v", err)
+			continue
+		}
+		receive(msg)
+	}
+}
+
+// decode parses a notification, loading stored messages referenced by ID
+func (b *PostgresBackplane) decode(payload string) (BackplaneMessage, error) {
+	var msg BackplaneMessage
+	if strings.HasPrefix(payload, backplaneRefPrefix) {
+		id, err := strconv.ParseUint(strings.TrimPrefix(payload, backplaneRefPrefix), 10, 64)
+		if err != nil {
+			return msg, fmt.Errorf("invalid reference %q", payload)
+		}
+		var event models.BackplaneEvent
+		if err := db.DB.First(&event, id).Error; err != nil {
+			return msg, fmt.Errorf("loading backplane event %d: %w", id, err)
+		}
+		payload = event.Payload
+	}
+
+	err := json.Unmarshal([]byte(payload), &msg)
+	return msg, err
+}
+
+// Close stops the listener
+func (b *PostgresBackplane) Close() error {
+	b.cancel()
+	return nil
+}
@@ -0,0 +1,100 @@
+package websocket
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"unilink-backend/db"
+	"unilink-backend/models"
+
+	"github.com/gorilla/websocket"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// startBackplaneHubs starts two Hubs that share a fresh Postgres channel, as two
+// instances of the backend would. Skips without DATABASE_URL.
+func startBackplaneHubs(t *testing.T) (*Hub, *Hub) {
+	t.Helper()
+	dsn := os.Getenv("DATABASE_URL")
+	if dsn == "" {
+		t.Skip("DATABASE_URL not set")
+	}
+
+	conn, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("connecting to database: %v", err)
+	}
+	if err := conn.AutoMigrate(&models.BackplaneEvent{}, &models.HubPresence{}); err != nil {
+		t.Fatalf("migrating: %v", err)
+	}
+	previous := db.DB
+	db.DB = conn
+	t.Cleanup(func() { db.DB = previous })
+
+	channel := "unilink_hub_test_" + newInstanceID()
+	hubs := []*Hub{NewHub(), NewHub()}
+	for _, hub := range hubs {
+		backplane := NewPostgresBackplane(dsn, channel)
+		t.Cleanup(func() { backplane.Close() })
+		hub.SetBackplane(backplane)
+		go hub.Run()
+	}
+	return hubs[0], hubs[1]
+}
+
+func TestPostgresBackplaneBetweenInstances(t *testing.T) {
+	hubA, hubB := startBackplaneHubs(t)
+
+	const userID = 4242
+	conn := newFakeConn()
+	client := &Client{hub: hubB, conn: conn, send: make(chan []byte, 16), userID: userID}
+	hubB.register <- client
+	go client.writePump()
+
+	// Listeners connect asynchronously, so keep delivering until B has one
+	data := []byte(`{"type":"test","v":1,"payload":{}}`)
+	delivered := func() bool {
+		_, _, _, writes := conn.snapshot()
+		for _, w := range writes {
+			if w.messageType == websocket.TextMessage {
+				return true
+			}
+		}
+		return false
+	}
+	deadline := time.Now().Add(10 * time.Second)
+	for !delivered() {
+		if time.Now().After(deadline) {
+			t.Fatal("envelope delivered on instance A never reached the client on instance B")
+		}
+		hubA.Deliver(Envelope{Type: "test", Data: data, UserIDs: []uint{userID}})
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	// Instance A sees the user connected to B as online
+	waitForPresence(t, hubA, userID, PresenceOnline)
+
+	// A logout handled by A closes the user's socket on B
+	hubA.Disconnect(userID, 0)
+	select {
+	case <-conn.closed:
+	case <-time.After(10 * time.Second):
+		t.Fatal("Disconnect on instance A did not close the connection on instance B")
+	}
+	waitForPresence(t, hubA, userID, PresenceOffline)
+}
+
+// waitForPresence polls until hub reports the status; presence is recorded asynchronously
+func waitForPresence(t *testing.T, hub *Hub, userID uint, want string) {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for got := hub.PresenceOf(userID); got != want; got = hub.PresenceOf(userID) {
+		if time.Now().After(deadline) {
+			t.Fatalf("presence of UserID %d = %q, want %q", userID, got, want)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
@@ -8,7 +8,8 @@ import (
 	"github.com/gorilla/websocket" // Import gorilla websocket
 )
 
-const (
+// Keepalive timings (variables so tests can shorten them)
+var (
 	// Time allowed to write a message to the peer.
 	writeWait = 10 * time.Second
 
@@ -17,13 +18,16 @@ const (
 
 	// Send pings to peer with this period. Must be less than pongWait.
 	pingPeriod = (pongWait * 9) / 10
+)
 
-	// Maximum message size allowed from peer.
-	maxMessageSize = 512
+const (
+	// Maximum message size allowed from peer. Chat messages are sent over the socket
+	// too (sendMessage), so this leaves room for a long message.
+	maxMessageSize = 16 * 1024
 )
 
 // readPump pumps messages from the WebSocket connection to the hub.
-// (We might not need incoming messages from clients initially, but it's good practice)
+// Each frame is an InboundMessage handled by handleInbound.
 func (c *Client) readPump() {
 	// --- Add Panic Recovery ---
 	defer func() {
@@ -35,9 +39,11 @@ func (c *Client) readPump() {
 		log.Printf("Exiting readPump for UserID: %d", c.userID) // Added exit log
 	}()
 	// --- End Panic Recovery ---
-	// c.conn.SetReadLimit(maxMessageSize) // If needed
-	// c.conn.SetReadDeadline(time.Now().Add(pongWait)) // If using pings/pongs
-	// c.conn.SetPongHandler(func(string) error { c.conn.SetReadDeadline(time.Now().Add(pongWait)); return nil })
+	// A peer that stops answering pings (e.g. a phone that lost signal) hits the read
+	// deadline, so half-open connections are unregistered instead of lingering
+	c.conn.SetReadLimit(maxMessageSize)
+	c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	c.conn.SetPongHandler(func(string) error { return c.conn.SetReadDeadline(time.Now().Add(pongWait)) })
 
 	for {
 		// ReadMessage is blocking
@@ -48,19 +54,18 @@ func (c *Client) readPump() {
 			} else {
 				log.Printf("WebSocket closed normally (UserID: %d)", c.userID)
 			}
-			break // Exit loop on error or close
+			break // Exit loop on error, close, timeout or oversized frame
 		}
+		c.conn.SetReadDeadline(time.Now().Add(pongWait)) // Any frame shows the peer is alive
 
-		// Process the received message if needed
-		log.Printf("Received message from UserID %d: %s", c.userID, string(message))
-		// Example: If clients could send messages *to the hub*
-		// h.broadcast <- message
+		// Dispatch chat actions (send, typing, mark read, ping) and ack them
+		c.handleInbound(message)
 	}
 }
 
 // writePump pumps messages from the hub to the WebSocket connection.
 func (c *Client) writePump() {
-	// ticker := time.NewTicker(pingPeriod) // If using pings
+	ticker := time.NewTicker(pingPeriod)
 	// --- Add Panic Recovery ---
 	defer func() {
 		if r := recover(); r != nil {
@@ -68,7 +73,7 @@ func (c *Client) writePump() {
 			// Attempt to unregister, although connection might already be broken
 			// c.hub.unregister <- c // Be careful with potential deadlocks if hub is blocked
 		}
-		// ticker.Stop() // Stop ticker if using pings
+		ticker.Stop()
 		c.conn.Close() // Ensure connection is closed on exit
 		log.Printf("Exiting writePump for UserID: %d", c.userID) // Added exit log
 	}()
@@ -76,7 +81,7 @@ func (c *Client) writePump() {
 	for {
 		select {
 		case message, ok := <-c.send:
-			// c.conn.SetWriteDeadline(time.Now().Add(writeWait)) // Reset deadline on write
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait)) // Reset deadline on write
 			if !ok {
 				// The hub closed the channel.
 				log.Printf("Client send channel closed for UserID %d", c.userID)
@@ -94,11 +99,13 @@ func (c *Client) writePump() {
 			// n := len(c.send)
 			// for i := 0; i < n; i++ { ... }
 
-			// case <-ticker.C: // If using pings
-			// 	// c.conn.SetWriteDeadline(time.Now().Add(writeWait))
-			// 	if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
-			// 		return
-			// 	}
+		case <-ticker.C:
+			// Keepalive; the peer's pong extends the read deadline in readPump
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				log.Printf("WebSocket ping failed (UserID: %d): %v", c.userID, err)
+				return
+			}
 		}
 	}
 }
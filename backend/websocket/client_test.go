@@ -0,0 +1,224 @@
+package websocket
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+var errReadTimeout = errors.New("i/o timeout")
+
+// fakeWrite is one frame written to a fakeConn, with the write deadline in force
+type fakeWrite struct {
+	messageType int
+	at          time.Time
+	deadline    time.Time
+}
+
+// fakeConn is a WebSocketConn whose peer never sends anything: reads block until
+// the read deadline passes or the connection is closed
+type fakeConn struct {
+	mu            sync.Mutex
+	readLimit     int64
+	readDeadline  time.Time
+	writeDeadline time.Time
+	pongHandler   func(string) error
+	writes        []fakeWrite
+	closed        chan struct{}
+	closeOnce     sync.Once
+}
+
+func newFakeConn() *fakeConn {
+	return &fakeConn{closed: make(chan struct{})}
+}
+
+func (c *fakeConn) ReadMessage() (int, []byte, error) {
+	for {
+		c.mu.Lock()
+		deadline := c.readDeadline
+		c.mu.Unlock()
+		if !deadline.IsZero() && !time.Now().Before(deadline) {
+			return 0, nil, errReadTimeout
+		}
+
+		wait := time.Hour
+		if !deadline.IsZero() {
+			wait = time.Until(deadline)
+		}
+		select {
+		case <-c.closed:
+			return 0, nil, errors.New("use of closed connection")
+		case <-time.After(wait):
+			// Re-check: the deadline may have been extended meanwhile
+		}
+	}
+}
+
+func (c *fakeConn) WriteMessage(messageType int, data []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.writes = append(c.writes, fakeWrite{messageType: messageType, at: time.Now(), deadline: c.writeDeadline})
+	return nil
+}
+
+func (c *fakeConn) Close() error {
+	c.closeOnce.Do(func() { close(c.closed) })
+	return nil
+}
+
+func (c *fakeConn) SetReadLimit(limit int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.readLimit = limit
+}
+
+func (c *fakeConn) SetReadDeadline(t time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.readDeadline = t
+	return nil
+}
+
+func (c *fakeConn) SetWriteDeadline(t time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.writeDeadline = t
+	return nil
+}
+
+func (c *fakeConn) SetPongHandler(h func(string) error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.pongHandler = h
+}
+
+func (c *fakeConn) snapshot() (limit int64, readDeadline time.Time, pong func(string) error, writes []fakeWrite) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.readLimit, c.readDeadline, c.pongHandler, append([]fakeWrite(nil), c.writes...)
+}
+
+// withKeepalive shortens the keepalive timings for one test
+func withKeepalive(t *testing.T, pong, ping, write time.Duration) {
+	t.Helper()
+	oldPong, oldPing, oldWrite := pongWait, pingPeriod, writeWait
+	pongWait, pingPeriod, writeWait = pong, ping, write
+	t.Cleanup(func() { pongWait, pingPeriod, writeWait = oldPong, oldPing, oldWrite })
+}
+
+func TestReadPumpSetsLimitAndDeadline(t *testing.T) {
+	withKeepalive(t, 5*time.Second, time.Second, time.Second)
+	hub := NewHub()
+	conn := newFakeConn()
+	client := &Client{hub: hub, conn: conn, send: make(chan []byte, 1), userID: 1}
+
+	start := time.Now()
+	go client.readPump()
+	t.Cleanup(func() {
+		conn.Close()
+		<-hub.unregister
+	})
+
+	var limit int64
+	var deadline time.Time
+	var pong func(string) error
+	for i := 0; i < 100 && pong == nil; i++ {
+		time.Sleep(5 * time.Millisecond)
+		limit, deadline, pong, _ = conn.snapshot()
+	}
+	if limit != maxMessageSize {
+		t.Errorf("read limit = %d, want %d", limit, maxMessageSize)
+	}
+	if deadline.Before(start.Add(pongWait)) || deadline.After(time.Now().Add(pongWait)) {
+		t.Errorf("read deadline = %v, want about %v from start", deadline.Sub(start), pongWait)
+	}
+	if pong == nil {
+		t.Fatal("no pong handler installed")
+	}
+
+	// A pong pushes the deadline out again
+	time.Sleep(20 * time.Millisecond)
+	before := time.Now()
+	if err := pong(""); err != nil {
+		t.Fatalf("pong handler: %v", err)
+	}
+	if _, extended, _, _ := conn.snapshot(); extended.Before(before.Add(pongWait)) {
+		t.Errorf("pong did not extend the read deadline (%v)", extended.Sub(before))
+	}
+}
+
+func TestReadPumpUnregistersSilentPeer(t *testing.T) {
+	withKeepalive(t, 50*time.Millisecond, 20*time.Millisecond, time.Second)
+	hub := NewHub()
+	conn := newFakeConn()
+	client := &Client{hub: hub, conn: conn, send: make(chan []byte, 1), userID: 1}
+
+	start := time.Now()
+	go client.readPump()
+
+	select {
+	case got := <-hub.unregister:
+		if got != client {
+			t.Fatal("unregistered a different client")
+		}
+		if elapsed := time.Since(start); elapsed < pongWait {
+			t.Errorf("unregistered after %v, before the %v read deadline", elapsed, pongWait)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("silent peer was never unregistered")
+	}
+
+	select {
+	case <-conn.closed:
+	case <-time.After(time.Second):
+		t.Error("connection was not closed")
+	}
+}
+
+func TestWritePumpPingsWithDeadline(t *testing.T) {
+	withKeepalive(t, time.Second, 20*time.Millisecond, 500*time.Millisecond)
+	hub := NewHub()
+	conn := newFakeConn()
+	client := &Client{hub: hub, conn: conn, send: make(chan []byte, 1), userID: 1}
+
+	done := make(chan struct{})
+	go func() {
+		client.writePump()
+		close(done)
+	}()
+
+	time.Sleep(5*pingPeriod + pingPeriod/2)
+	close(client.send)
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("writePump did not exit after the send channel closed")
+	}
+
+	_, _, _, writes := conn.snapshot()
+	var pings []fakeWrite
+	for _, w := range writes {
+		if w.messageType == websocket.PingMessage {
+			pings = append(pings, w)
+		}
+	}
+	if len(pings) < 3 {
+		t.Fatalf("got %d pings in %v, want one every %v", len(pings), 5*pingPeriod, pingPeriod)
+	}
+	for i, ping := range pings {
+		if ping.deadline.Before(ping.at) || ping.deadline.After(ping.at.Add(writeWait)) {
+			t.Errorf("ping %d written with deadline %v after it, want within %v", i, ping.deadline.Sub(ping.at), writeWait)
+		}
+		if i > 0 {
+			if gap := ping.at.Sub(pings[i-1].at); gap < pingPeriod/2 {
+				t.Errorf("pings %d and %d only %v apart, want about %v", i-1, i, gap, pingPeriod)
+			}
+		}
+	}
+	if last := writes[len(writes)-1]; last.messageType != websocket.CloseMessage {
+		t.Errorf("last frame type = %d, want a close frame", last.messageType)
+	}
+}
@@ -0,0 +1,353 @@
+// backend/websocket/events.go
+package websocket
+
+import (
+	"encoding/json"
+	"reflect"
+
+	"unilink-backend/dto"
+)
+
+// EventsVersion is the version of the event catalogue. Bump it whenever an event is
+// added or removed or a payload changes shape; it is sent with every event as "v"
+// and in the JSON Schema (see schema.go).
+const EventsVersion = 1
+
+// Event is a typed server-to-client message. Every event type is listed in the
+// catalogue below and knows who receives it.
+type Event interface {
+	EventType() string
+	Audience() Audience
+}
+
+// Audience targets
+const (
+	TargetUsers        = "user"
+	TargetConversation = "conversation"
+	TargetCollege      = "college"
+)
+
+// Audience is who an event goes to. Exactly one of the fields is set.
+type Audience struct {
+	UserIDs      []uint
+	Conversation *ConversationAudience
+	College      *CollegeTarget
+}
+
+// ConversationAudience addresses the participants of a conversation, except the
+// user who caused the event unless IncludeActor is set (so their other tabs sync)
+type ConversationAudience struct {
+	ConversationID string
+	ActorID        uint
+	IncludeActor   bool
+}
+
+// target names the Audience kind, matching EventSpec.Target
+func (a Audience) target() string {
+	switch {
+	case a.Conversation != nil:
+		return TargetConversation
+	case a.College != nil:
+		return TargetCollege
+	default:
+		return TargetUsers
+	}
+}
+
+// EventSpec describes one entry of the catalogue
+type EventSpec struct {
+	Type        string
+	Target      string // TargetUsers, TargetConversation or TargetCollege
+	Description string
+	event       reflect.Type // The Go event type
+	payload     reflect.Type // What is sent as "payload" (the event, unless it says otherwise)
+}
+
+// eventCatalogue lists every event the server sends. Publish refuses anything else.
+var eventCatalogue = []EventSpec{
+	eventSpec(NewMessageEvent{}, TargetConversation, "A chat message was sent. The payload is the stored message."),
+	eventSpec(MessageEditedEvent{}, TargetConversation, "A chat message was edited."),
+	eventSpec(MessageDeletedEvent{}, TargetConversation, "A chat message was deleted."),
+	eventSpec(MessageReactionEvent{}, TargetConversation, "An emoji reaction was added to or removed from a message."),
+	eventSpec(MessagesReadEvent{}, TargetConversation, "A participant read the conversation up to a message."),
+	eventSpec(TypingEvent{}, TargetConversation, "A participant started or stopped typing."),
+	eventSpec(NewAnnouncementEvent{}, TargetCollege, "An announcement was posted for the user's college, department or semester."),
+	eventSpec(ListingUpdatedEvent{}, TargetCollege, "A marketplace listing in the user's college changed."),
+	eventSpec(NewFriendRequestEvent{}, TargetUsers, "Someone sent the user a friend request."),
+	eventSpec(FriendRequestUpdateEvent{}, TargetUsers, "A friend request the user sent was accepted or rejected."),
+	eventSpec(FriendRemovedEvent{}, TargetUsers, "Someone removed the user as a friend."),
+	eventSpec(ReservationExpiredEvent{}, TargetUsers, "A reservation the user is part of lapsed and the listing is available again."),
+	eventSpec(ReservationExtendedEvent{}, TargetUsers, "The seller extended the user's reservation."),
+	eventSpec(NewOfferEvent{}, TargetUsers, "A buyer made an offer on the user's listing."),
+	eventSpec(OfferUpdateEvent{}, TargetUsers, "The other party countered, accepted, rejected or withdrew an offer, or it was closed because the listing was reserved, sold or deleted."),
+	eventSpec(NewRatingEvent{}, TargetUsers, "The user received a rating for a transaction."),
+	eventSpec(PresenceEvent{}, TargetUsers, "A friend or group member's visible presence changed."),
+}
+
+// eventSpecs indexes the catalogue by event type
+var eventSpecs = func() map[string]EventSpec {
+	specs := make(map[string]EventSpec, len(eventCatalogue))
+	for _, spec := range eventCatalogue {
+		specs[spec.Type] = spec
+	}
+	return specs
+}()
+
+func eventSpec(event Event, target string, description string) EventSpec {
+	payload := reflect.TypeOf(event)
+	if custom, ok := event.(interface{ payloadType() reflect.Type }); ok {
+		payload = custom.payloadType()
+	}
+	return EventSpec{
+		Type:        event.EventType(),
+		Target:      target,
+		Description: description,
+		event:       reflect.TypeOf(event),
+		payload:     payload,
+	}
+}
+
+// catalogued returns the catalogue entry for an event, if its type is listed
+func catalogued(event Event) (EventSpec, bool) {
+	spec, ok := eventSpecs[event.EventType()]
+	return spec, ok && spec.event == reflect.TypeOf(event)
+}
+
+// EventCatalogue returns the catalogue entries in a stable order
+func EventCatalogue() []EventSpec {
+	return append([]EventSpec(nil), eventCatalogue...)
+}
+
+// --- Conversation events ---
+
+// NewMessageEvent carries a stored chat message. The message is sent as the payload
+// itself, as clients have always received it.
+type NewMessageEvent struct {
+	ConversationID string
+	SenderID       uint
+	Message        dto.MessageResponse
+}
+
+func (e NewMessageEvent) EventType() string { return "newMessage" }
+func (e NewMessageEvent) Audience() Audience {
+	return Audience{Conversation: &ConversationAudience{ConversationID: e.ConversationID, ActorID: e.SenderID}}
+}
+
+// MarshalJSON sends the message without a wrapper
+func (e NewMessageEvent) MarshalJSON() ([]byte, error) {
+	return json.Marshal(e.Message)
+}
+
+// payloadType tells the schema what MarshalJSON sends
+func (e NewMessageEvent) payloadType() reflect.Type { return reflect.TypeOf(e.Message) }
+
+type MessageEditedEvent struct {
+	MessageID      uint                `json:"messageId"`
+	ConversationID string              `json:"conversationId"`
+	SenderID       uint                `json:"senderId"`
+	Message        dto.MessageResponse `json:"message"` // The message after the edit
+}
+
+func (e MessageEditedEvent) EventType() string { return "messageEdited" }
+func (e MessageEditedEvent) Audience() Audience {
+	return Audience{Conversation: &ConversationAudience{ConversationID: e.ConversationID, ActorID: e.SenderID, IncludeActor: true}}
+}
+
+type MessageDeletedEvent struct {
+	MessageID      uint   `json:"messageId"`
+	ConversationID string `json:"conversationId"`
+	SenderID       uint   `json:"senderId"`
+}
+
+func (e MessageDeletedEvent) EventType() string { return "messageDeleted" }
+func (e MessageDeletedEvent) Audience() Audience {
+	return Audience{Conversation: &ConversationAudience{ConversationID: e.ConversationID, ActorID: e.SenderID, IncludeActor: true}}
+}
+
+type MessageReactionEvent struct {
+	MessageID      uint   `json:"messageId"`
+	ConversationID string `json:"conversationId"`
+	UserID         uint   `json:"userId"`
+	Emoji          string `json:"emoji"`
+	Action         string `json:"action"` // "added" or "removed"
+	Count          int64  `json:"count"`  // Reactions with this emoji after the change
+}
+
+func (e MessageReactionEvent) EventType() string { return "messageReaction" }
+func (e MessageReactionEvent) Audience() Audience {
+	return Audience{Conversation: &ConversationAudience{ConversationID: e.ConversationID, ActorID: e.UserID, IncludeActor: true}}
+}
+
+type MessagesReadEvent struct {
+	ConversationID    string `json:"conversationId"`
+	UserID            uint   `json:"userId"`
+	LastReadMessageID uint   `json:"lastReadMessageId"`
+	ReadAt            string `json:"readAt"`
+}
+
+func (e MessagesReadEvent) EventType() string { return "messagesRead" }
+func (e MessagesReadEvent) Audience() Audience {
+	return Audience{Conversation: &ConversationAudience{ConversationID: e.ConversationID, ActorID: e.UserID}}
+}
+
+type TypingEvent struct {
+	ConversationID string `json:"conversationId"`
+	UserID         uint   `json:"userId"`
+	Typing         bool   `json:"typing"`
+	ExpiresIn      int    `json:"expiresIn"` // Seconds; clients clear the indicator if no refresh arrives
+}
+
+func (e TypingEvent) EventType() string { return "typing" }
+func (e TypingEvent) Audience() Audience {
+	return Audience{Conversation: &ConversationAudience{ConversationID: e.ConversationID, ActorID: e.UserID}}
+}
+
+// --- College events ---
+
+type NewAnnouncementEvent struct {
+	ID         uint    `json:"id"`
+	Title      string  `json:"title"`
+	Content    string  `json:"content"`
+	Priority   string  `json:"priority"`
+	Department *string `json:"department"` // nil = all departments
+	Semester   *int    `json:"semester"`   // nil = all semesters
+	AuthorName string  `json:"authorName"`
+	CreatedAt  string  `json:"createdAt"`
+	UpdatedAt  string  `json:"updatedAt"`
+	CollegeID  uint    `json:"collegeId"`
+}
+
+func (e NewAnnouncementEvent) EventType() string { return "newAnnouncement" }
+func (e NewAnnouncementEvent) Audience() Audience {
+	target := &CollegeTarget{CollegeID: e.CollegeID}
+	if e.Department != nil && *e.Department != "" {
+		target.Department = e.Department
+	}
+	if e.Semester != nil && *e.Semester > 0 {
+		target.Semester = e.Semester
+	}
+	return Audience{College: target}
+}
+
+type ListingUpdatedEvent struct {
+	ListingID     uint                `json:"listingId"`
+	CollegeID     uint                `json:"collegeId"`
+	ChangedFields []string            `json:"changedFields"`
+	Listing       dto.ListingResponse `json:"listing"`
+}
+
+func (e ListingUpdatedEvent) EventType() string { return "listingUpdated" }
+func (e ListingUpdatedEvent) Audience() Audience {
+	return Audience{College: &CollegeTarget{CollegeID: e.CollegeID}}
+}
+
+// --- User events ---
+
+type NewFriendRequestEvent struct {
+	ID        uint                  `json:"id"`       // Friendship record ID
+	UserID    uint                  `json:"userId"`   // Who sent the request
+	FriendID  uint                  `json:"friendId"` // Who receives it
+	Sender    dto.FriendProfileData `json:"sender"`   // The sender's friend profile
+	CreatedAt string                `json:"createdAt"`
+}
+
+func (e NewFriendRequestEvent) EventType() string { return "newFriendRequest" }
+func (e NewFriendRequestEvent) Audience() Audience {
+	return Audience{UserIDs: []uint{e.FriendID}}
+}
+
+type FriendRequestUpdateEvent struct {
+	ID         uint                   `json:"id"`
+	UserID     uint                   `json:"userId"`   // Who sent the request (receives this event)
+	FriendID   uint                   `json:"friendId"` // Who answered it
+	Status     string                 `json:"status"`   // "accepted" or "rejected"
+	Accepter   *dto.FriendProfileData `json:"accepter,omitempty"`
+	RejecterID *uint                  `json:"rejecterId,omitempty"`
+	UpdatedAt  string                 `json:"updatedAt"`
+}
+
+func (e FriendRequestUpdateEvent) EventType() string { return "friendRequestUpdate" }
+func (e FriendRequestUpdateEvent) Audience() Audience {
+	return Audience{UserIDs: []uint{e.UserID}}
+}
+
+type FriendRemovedEvent struct {
+	ID          uint   `json:"id"`          // The deleted friendship record
+	RemovedByID uint   `json:"removedById"` // Who removed the friend
+	RemovedUser uint   `json:"removedUser"` // Who was removed (receives this event)
+	RemoverName string `json:"removerName"`
+	UpdatedAt   string `json:"updatedAt"`
+}
+
+func (e FriendRemovedEvent) EventType() string { return "friendRemoved" }
+func (e FriendRemovedEvent) Audience() Audience {
+	return Audience{UserIDs: []uint{e.RemovedUser}}
+}
+
+type ReservationExpiredEvent struct {
+	ListingID uint   `json:"listingId"`
+	Title     string `json:"title"`
+	BuyerID   uint   `json:"buyerId"`
+	SellerID  uint   `json:"sellerId"`
+	Status    string `json:"status"`
+}
+
+func (e ReservationExpiredEvent) EventType() string { return "reservationExpired" }
+func (e ReservationExpiredEvent) Audience() Audience {
+	return Audience{UserIDs: []uint{e.BuyerID, e.SellerID}}
+}
+
+type ReservationExtendedEvent struct {
+	ListingID     uint   `json:"listingId"`
+	Title         string `json:"title"`
+	BuyerID       uint   `json:"buyerId"`
+	SellerID      uint   `json:"sellerId"`
+	ReservedUntil string `json:"reservedUntil"` // RFC 3339
+}
+
+func (e ReservationExtendedEvent) EventType() string { return "reservationExtended" }
+func (e ReservationExtendedEvent) Audience() Audience {
+	return Audience{UserIDs: []uint{e.BuyerID}}
+}
+
+type NewOfferEvent struct {
+	RecipientID uint              `json:"recipientId"` // The seller
+	Offer       dto.OfferResponse `json:"offer"`
+}
+
+func (e NewOfferEvent) EventType() string { return "newOffer" }
+func (e NewOfferEvent) Audience() Audience {
+	return Audience{UserIDs: []uint{e.RecipientID}}
+}
+
+type OfferUpdateEvent struct {
+	RecipientID uint              `json:"recipientId"` // The party who didn't act
+	Action      string            `json:"action"`      // "countered", "accepted", "rejected", "withdrawn", "closed"
+	Offer       dto.OfferResponse `json:"offer"`
+}
+
+func (e OfferUpdateEvent) EventType() string { return "offerUpdate" }
+func (e OfferUpdateEvent) Audience() Audience {
+	return Audience{UserIDs: []uint{e.RecipientID}}
+}
+
+type NewRatingEvent struct {
+	RateeID uint               `json:"rateeId"`
+	Rating  dto.RatingResponse `json:"rating"`
+}
+
+func (e NewRatingEvent) EventType() string { return "newRating" }
+func (e NewRatingEvent) Audience() Audience {
+	return Audience{UserIDs: []uint{e.RateeID}}
+}
+
+// PresenceEvent is someone's visible presence, sent to their presence audience
+type PresenceEvent struct {
+	PresenceInfo
+	Recipients []uint `json:"-"`
+}
+
+func (e PresenceEvent) EventType() string { return "presence" }
+func (e PresenceEvent) Audience() Audience {
+	return Audience{UserIDs: e.Recipients}
+}
@@ -2,8 +2,11 @@
 package websocket
 
 import (
+	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
+	"time"
 	"unilink-backend/db" // For fetching user details
 	"unilink-backend/models"
 	"unilink-backend/utils" // For JWT validation
@@ -11,48 +14,47 @@ import (
 	"github.com/gorilla/websocket"
 )
 
+// authWait is how long a connection opened without a ticket has to send its auth frame
+const authWait = 10 * time.Second
+
 // Configure the upgrader
 var upgrader = websocket.Upgrader{
 	ReadBufferSize:  1024,
 	WriteBufferSize: 1024,
-	// Allow connections from your frontend development/production origins
+	// Only the frontend origins allowed by CORS (ALLOWED_ORIGINS) may connect.
+	// Non-browser clients send no Origin header; they still have to authenticate.
 	CheckOrigin: func(r *http.Request) bool {
-		// TODO: Implement proper origin checking for security
-		// For development, you might allow localhost origins:
-		// origin := r.Header.Get("Origin")
-		// return origin == "http://localhost:3000" || origin == "http://localhost:5173"
-		return true // Allow all for now (less secure)
+		origin := r.Header.Get("Origin")
+		if origin == "" || utils.IsOriginAllowed(origin) {
+			return true
+		}
+		log.Printf("Rejected WebSocket from origin %q", origin)
+		return false
 	},
 }
 
 // ServeWs handles WebSocket requests from clients.
+// Clients authenticate with a one-time ticket from POST /api/ws/ticket (?ticket=...),
+// or connect without one and send {"type": "auth", "payload": {"ticket": ...}} (or
+// {"token": <access token>}) as their first frame. Access tokens are never read from
+// the URL, where proxies would log them.
 func ServeWs(hub *Hub, w http.ResponseWriter, r *http.Request) {
-	// 1. Authenticate the user (e.g., via JWT in query param)
-	tokenString := r.URL.Query().Get("token")
-	if tokenString == "" {
-		http.Error(w, "Missing authentication token", http.StatusUnauthorized)
-		return
-	}
-
-	claims, err := utils.ValidateJWT(tokenString)
-	if err != nil {
-		log.Printf("Invalid WebSocket token: %v", err)
-		http.Error(w, "Invalid authentication token", http.StatusUnauthorized)
-		return
+	// 1. Authenticate the user up front if a ticket was given
+	var claims *utils.CustomClaims
+	var user *models.User
+	if ticket := r.URL.Query().Get("ticket"); ticket != "" {
+		var err error
+		claims, user, err = authenticate(ticket, "")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
 	}
 
-	// 2. Fetch user details needed for targeting (optional but useful)
-	var user models.User
-	if err := db.DB.First(&user, claims.UserID).Error; err != nil {
-		log.Printf("User not found for WebSocket connection (UserID: %d): %v", claims.UserID, err)
-		http.Error(w, "User not found", http.StatusNotFound)
-		return
-	}
-
-	// 3. Upgrade HTTP connection to WebSocket
+	// 2. Upgrade HTTP connection to WebSocket
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
-		log.Printf("Failed to upgrade WebSocket connection (UserID: %d): %v", claims.UserID, err)
+		log.Printf("Failed to upgrade WebSocket connection: %v", err)
 		// Upgrader already sends an error response
 		return
 	}
@@ -60,17 +62,34 @@ func ServeWs(hub *Hub, w http.ResponseWriter, r *http.Request) {
 	// Wrap the gorilla conn to match our interface (for potential abstraction/testing later)
 	wsConn := &GorillaConnWrapper{conn: conn}
 
+	// 3. Without a ticket, the first frame has to authenticate the connection
+	authByFrame, authFrameID := claims == nil, ""
+	if authByFrame {
+		claims, user, authFrameID, err = authenticateFirstFrame(wsConn)
+		if err != nil {
+			wsConn.SetWriteDeadline(time.Now().Add(writeWait))
+			wsConn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.ClosePolicyViolation, err.Error()))
+			wsConn.Close()
+			return
+		}
+	}
+
 	// 4. Create and register the client
 	client := &Client{
 		hub:    hub,
 		conn:   wsConn,
 		send:   make(chan []byte, 256), // Buffered channel
 		userID: claims.UserID,
+		// Session is kept so logout can close this connection
+		sessionID: claims.SessionID,
 		// Store details for announcement targeting
 		collegeID:  user.CollegeID,
 		department: user.Department,
 		semester:   user.Semester,
 	}
+	if authByFrame {
+		client.sendAck(Ack{ID: authFrameID, OK: true}) // Answer the auth frame before any events
+	}
 	client.hub.register <- client
 
 	log.Printf("WebSocket connection established for UserID: %d", claims.UserID)
@@ -82,6 +101,63 @@ func ServeWs(hub *Hub, w http.ResponseWriter, r *http.Request) {
 	go client.readPump() // Call readPump last as it blocks until connection closes
 }
 
+// authenticate checks a ticket or an access token and loads the user.
+// Returned errors are safe to show the client.
+func authenticate(ticket string, token string) (*utils.CustomClaims, *models.User, error) {
+	var claims *utils.CustomClaims
+	var err error
+	switch {
+	case ticket != "":
+		claims, err = utils.RedeemWebSocketTicket(ticket)
+	case token != "":
+		claims, err = utils.ValidateJWT(token)
+	default:
+		return nil, nil, errors.New("Missing authentication ticket or token")
+	}
+	if err != nil {
+		log.Printf("Invalid WebSocket credentials: %v", err)
+		return nil, nil, errors.New("Invalid authentication ticket or token")
+	}
+
+	if !utils.IsSessionActive(claims) {
+		log.Printf("Rejected WebSocket for revoked session (UserID: %d, SessionID: %d)", claims.UserID, claims.SessionID)
+		return nil, nil, errors.New("Session has been revoked")
+	}
+
+	// Fetch user details needed for targeting
+	var user models.User
+	if err := db.DB.First(&user, claims.UserID).Error; err != nil {
+		log.Printf("User not found for WebSocket connection (UserID: %d): %v", claims.UserID, err)
+		return nil, nil, errors.New("User not found")
+	}
+	return claims, &user, nil
+}
+
+// authenticateFirstFrame waits up to authWait for an "auth" frame and checks its
+// credentials. It also returns the frame's ID so the caller can ack it.
+func authenticateFirstFrame(conn WebSocketConn) (*utils.CustomClaims, *models.User, string, error) {
+	conn.SetReadLimit(maxMessageSize)
+	conn.SetReadDeadline(time.Now().Add(authWait))
+	_, raw, err := conn.ReadMessage()
+	if err != nil {
+		log.Printf("WebSocket closed before authenticating: %v", err)
+		return nil, nil, "", errors.New("Authentication timed out")
+	}
+
+	var msg InboundMessage
+	if err := json.Unmarshal(raw, &msg); err != nil || msg.Type != "auth" {
+		return nil, nil, msg.ID, errors.New("First message must be of type 'auth'")
+	}
+	var credentials struct {
+		Ticket string `json:"ticket"`
+		Token  string `json:"token"`
+	}
+	json.Unmarshal(msg.Payload, &credentials) // Missing fields are reported by authenticate
+
+	claims, user, err := authenticate(credentials.Ticket, credentials.Token)
+	return claims, user, msg.ID, err
+}
+
 // GorillaConnWrapper adapts *websocket.Conn to the WebSocketConn interface
 type GorillaConnWrapper struct {
 	conn *websocket.Conn
@@ -99,4 +175,18 @@ func (gcw *GorillaConnWrapper) Close() error {
 	return gcw.conn.Close()
 }
 
-// Add other methods like SetReadDeadline if needed
\ No newline at end of file
+func (gcw *GorillaConnWrapper) SetReadLimit(limit int64) {
+	gcw.conn.SetReadLimit(limit)
+}
+
+func (gcw *GorillaConnWrapper) SetReadDeadline(t time.Time) error {
+	return gcw.conn.SetReadDeadline(t)
+}
+
+func (gcw *GorillaConnWrapper) SetWriteDeadline(t time.Time) error {
+	return gcw.conn.SetWriteDeadline(t)
+}
+
+func (gcw *GorillaConnWrapper) SetPongHandler(h func(appData string) error) {
+	gcw.conn.SetPongHandler(h)
+}
@@ -0,0 +1,95 @@
+// backend/websocket/inbound.go
+package websocket
+
+import (
+	"encoding/json"
+	"log"
+	"time"
+)
+
+// InboundMessage is a frame sent by the client over /ws, e.g.
+// {"type": "sendMessage", "id": "tmp-42", "payload": {...}}
+type InboundMessage struct {
+	Type    string          `json:"type"`    // "sendMessage", "typingStart", "typingStop", "markRead", "setPresence", "ping" ("auth" first, see ServeWs)
+	ID      string          `json:"id"`      // Client-generated, echoed back in the ack
+	Payload json.RawMessage `json:"payload"` // Action-specific body
+}
+
+// Ack answers one InboundMessage. It is sent as {"type": "ack", "payload": Ack}.
+type Ack struct {
+	ID    string      `json:"id"`
+	OK    bool        `json:"ok"`
+	Error string      `json:"error,omitempty"`
+	Data  interface{} `json:"data,omitempty"` // e.g. the stored message for sendMessage
+}
+
+// ClientInfo identifies the connection an inbound action came from
+type ClientInfo struct {
+	UserID    uint
+	CollegeID uint
+	SessionID uint
+}
+
+// ActionHandler processes one inbound action. The returned data goes into the ack;
+// a returned error is reported to the client as-is, so keep it user-facing.
+type ActionHandler func(hub *Hub, client ClientInfo, payload json.RawMessage) (interface{}, error)
+
+// HandleAction registers the handler for an inbound message type.
+// Register all actions before calling Run.
+func (h *Hub) HandleAction(messageType string, handler ActionHandler) {
+	h.actions[messageType] = handler
+}
+
+// handleInbound decodes a client frame, runs its action and acks it.
+// Called from readPump, so actions from one connection run in order.
+func (c *Client) handleInbound(raw []byte) {
+	var msg InboundMessage
+	if err := json.Unmarshal(raw, &msg); err != nil || msg.Type == "" {
+		c.sendAck(Ack{ID: msg.ID, Error: "Invalid message format"})
+		return
+	}
+
+	if msg.Type == "ping" {
+		c.sendAck(Ack{ID: msg.ID, OK: true, Data: map[string]interface{}{
+			"serverTime": time.Now().Format(time.RFC3339),
+		}})
+		return
+	}
+
+	if msg.Type == "setPresence" {
+		var req struct {
+			Status string `json:"status"` // "online" or "away"
+		}
+		if err := json.Unmarshal(msg.Payload, &req); err != nil || (req.Status != PresenceOnline && req.Status != PresenceAway) {
+			c.sendAck(Ack{ID: msg.ID, Error: "Status must be 'online' or 'away'"})
+			return
+		}
+		c.hub.SetAway(c.userID, req.Status == PresenceAway)
+		c.sendAck(Ack{ID: msg.ID, OK: true})
+		return
+	}
+
+	handler, ok := c.hub.actions[msg.Type]
+	if !ok {
+		c.sendAck(Ack{ID: msg.ID, Error: "Unknown message type: " + msg.Type})
+		return
+	}
+
+	info := ClientInfo{UserID: c.userID, CollegeID: c.collegeID, SessionID: c.sessionID}
+	data, err := handler(c.hub, info, msg.Payload)
+	if err != nil {
+		c.sendAck(Ack{ID: msg.ID, Error: err.Error()})
+		return
+	}
+	c.sendAck(Ack{ID: msg.ID, OK: true, Data: data})
+}
+
+// sendAck queues an ack for this connection only
+func (c *Client) sendAck(ack Ack) {
+	bytes, err := json.Marshal(&WSMessage{Type: "ack", Payload: ack})
+	if err != nil {
+		log.Printf("Error marshalling ack for UserID %d: %v", c.userID, err)
+		return
+	}
+	c.sendMessage(bytes)
+}
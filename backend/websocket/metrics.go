@@ -0,0 +1,61 @@
+// backend/websocket/metrics.go
+package websocket
+
+import "sync/atomic"
+
+// hubCounters are updated from the Run loop and from handler goroutines
+type hubCounters struct {
+	enqueued           atomic.Uint64
+	queueFullWaits     atomic.Uint64
+	unroutable         atomic.Uint64
+	delivered          atomic.Uint64
+	droppedMessages    atomic.Uint64
+	slowClientsDropped atomic.Uint64
+	backplanePublished atomic.Uint64
+	backplaneReceived  atomic.Uint64
+	backplaneErrors    atomic.Uint64
+}
+
+// HubMetrics is a snapshot of the Hub's state and counters since startup
+type HubMetrics struct {
+	ConnectedUsers     int    `json:"connectedUsers"`
+	Connections        int    `json:"connections"`
+	QueueLength        int    `json:"queueLength"`
+	QueueCapacity      int    `json:"queueCapacity"`
+	Enqueued           uint64 `json:"enqueued"`           // Envelopes accepted by Deliver
+	QueueFullWaits     uint64 `json:"queueFullWaits"`     // Deliver calls that had to wait for room
+	Unroutable         uint64 `json:"unroutable"`         // Events with no recipients or not in the catalogue
+	Delivered          uint64 `json:"delivered"`          // Messages handed to individual connections
+	DroppedMessages    uint64 `json:"droppedMessages"`    // Messages lost because a connection's buffer was full
+	SlowClientsDropped uint64 `json:"slowClientsDropped"` // Connections closed for falling behind
+	// Traffic to and from other instances (zero without a backplane)
+	BackplanePublished uint64 `json:"backplanePublished"`
+	BackplaneReceived  uint64 `json:"backplaneReceived"`
+	BackplaneErrors    uint64 `json:"backplaneErrors"` // Publishes that failed; other instances missed them
+}
+
+// Metrics returns a snapshot of the Hub's metrics
+func (h *Hub) Metrics() HubMetrics {
+	h.mu.RLock()
+	users, connections := len(h.clients), 0
+	for _, userClients := range h.clients {
+		connections += len(userClients)
+	}
+	h.mu.RUnlock()
+
+	return HubMetrics{
+		ConnectedUsers:     users,
+		Connections:        connections,
+		QueueLength:        len(h.envelopes),
+		QueueCapacity:      cap(h.envelopes),
+		Enqueued:           h.metrics.enqueued.Load(),
+		QueueFullWaits:     h.metrics.queueFullWaits.Load(),
+		Unroutable:         h.metrics.unroutable.Load(),
+		Delivered:          h.metrics.delivered.Load(),
+		DroppedMessages:    h.metrics.droppedMessages.Load(),
+		SlowClientsDropped: h.metrics.slowClientsDropped.Load(),
+		BackplanePublished: h.metrics.backplanePublished.Load(),
+		BackplaneReceived:  h.metrics.backplaneReceived.Load(),
+		BackplaneErrors:    h.metrics.backplaneErrors.Load(),
+	}
+}
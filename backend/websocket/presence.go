@@ -0,0 +1,164 @@
+// backend/websocket/presence.go
+package websocket
+
+import (
+	"log"
+	"time"
+
+	"unilink-backend/db"
+	"unilink-backend/models"
+)
+
+// Presence states
+const (
+	PresenceOnline  = "online"
+	PresenceAway    = "away"
+	PresenceOffline = "offline"
+)
+
+// PresenceInfo is what other users see about someone's presence
+type PresenceInfo struct {
+	UserID   uint    `json:"userId"`
+	Status   string  `json:"status"`
+	LastSeen *string `json:"lastSeen,omitempty"` // Only for visible users who are offline
+}
+
+// presenceStatusLocked returns the live status from connections. h.mu must be held.
+func (h *Hub) presenceStatusLocked(userID uint) string {
+	if len(h.clients[userID]) == 0 {
+		return PresenceOffline
+	}
+	if h.away[userID] {
+		return PresenceAway
+	}
+	return PresenceOnline
+}
+
+// PresenceOf returns a user's live status, ignoring privacy settings
+func (h *Hub) PresenceOf(userID uint) string {
+	return h.PresenceOfUsers([]uint{userID})[userID]
+}
+
+// PresenceOfUsers returns live statuses for several users, ignoring privacy settings.
+// With a backplane, connections on other instances count too (see presence_shared.go).
+func (h *Hub) PresenceOfUsers(userIDs []uint) map[uint]string {
+	statuses := make(map[uint]string, len(userIDs))
+	h.mu.RLock()
+	for _, userID := range userIDs {
+		statuses[userID] = h.presenceStatusLocked(userID)
+	}
+	h.mu.RUnlock()
+
+	if h.backplane != nil && len(statuses) > 0 {
+		h.mergeRemotePresence(statuses)
+	}
+	return statuses
+}
+
+// SetAway marks all of a user's connections away (tab hidden, idle) or back online
+func (h *Hub) SetAway(userID uint, away bool) {
+	h.mu.Lock()
+	before := h.presenceStatusLocked(userID)
+	if away && len(h.clients[userID]) > 0 {
+		h.away[userID] = true
+	} else {
+		delete(h.away, userID)
+	}
+	after := h.presenceStatusLocked(userID)
+	h.mu.Unlock()
+
+	if before != after {
+		go h.publishPresence(userID)
+	}
+}
+
+// RefreshPresence re-announces a user's presence, e.g. after they change privacy settings
+func (h *Hub) RefreshPresence(userID uint) {
+	go func() {
+		h.announcePresence(userID, h.PresenceOf(userID))
+	}()
+}
+
+// VisiblePresence applies the user's privacy settings to their live status:
+// private profiles and users who opted out always appear offline.
+func VisiblePresence(user *models.User, liveStatus string) PresenceInfo {
+	if !user.IsPublic || !user.ShowPresence {
+		return PresenceInfo{UserID: user.ID, Status: PresenceOffline}
+	}
+
+	info := PresenceInfo{UserID: user.ID, Status: liveStatus}
+	if liveStatus == PresenceOffline && user.LastSeenAt != nil {
+		lastSeen := user.LastSeenAt.Format("2006-01-02 15:04:05")
+		info.LastSeen = &lastSeen
+	}
+	return info
+}
+
+// publishPresence announces a change in a user's connections on this instance.
+// With a backplane it is only announced when the status across all instances changed:
+// closing one of two tabs on different instances leaves the user online.
+// Runs in its own goroutine so the database work stays off the Run loop.
+func (h *Hub) publishPresence(userID uint) {
+	if h.backplane == nil {
+		h.announcePresence(userID, h.PresenceOf(userID))
+		return
+	}
+	if status, changed := h.recordPresence(userID); changed {
+		h.announcePresence(userID, status)
+	}
+}
+
+// announcePresence records last-seen when a user goes offline and sends their visible
+// presence to accepted friends and members of shared groups
+func (h *Hub) announcePresence(userID uint, status string) {
+	if status == PresenceOffline {
+		if err := db.DB.Model(&models.User{}).Where("id = ?", userID).Update("last_seen_at", time.Now()).Error; err != nil {
+			log.Printf("Error updating last seen for UserID %d: %v", userID, err)
+		}
+	}
+
+	var user models.User
+	if err := db.DB.First(&user, userID).Error; err != nil {
+		log.Printf("Error loading user %d for presence: %v", userID, err)
+		return
+	}
+
+	audience := PresenceAudience(userID)
+	if len(audience) == 0 {
+		return
+	}
+	h.Publish(PresenceEvent{
+		PresenceInfo: VisiblePresence(&user, status),
+		Recipients:   userIDList(audience),
+	})
+}
+
+// PresenceAudience returns who may see a user's presence: accepted friends and
+// members of groups the user belongs to
+func PresenceAudience(userID uint) map[uint]bool {
+	audience := make(map[uint]bool)
+
+	var friendships []models.Friendship
+	db.DB.Where("(user_id = ? OR friend_id = ?) AND status = ?", userID, userID, "accepted").Find(&friendships)
+	for _, f := range friendships {
+		if f.UserID == userID {
+			audience[f.FriendID] = true
+		} else {
+			audience[f.UserID] = true
+		}
+	}
+
+	var memberIDs []uint
+	db.DB.Model(&models.GroupMember{}).
+		Where("group_id IN (?) AND user_id != ?",
+			db.DB.Model(&models.GroupMember{}).Select("group_id").Where("user_id = ?", userID),
+			userID).
+		Distinct().
+		Pluck("user_id", &memberIDs)
+	for _, id := range memberIDs {
+		audience[id] = true
+	}
+
+	delete(audience, userID)
+	return audience
+}
@@ -0,0 +1,149 @@
+// backend/websocket/presence_shared.go
+package websocket
+
+import (
+	"log"
+	"time"
+
+	"unilink-backend/db"
+	"unilink-backend/models"
+
+	"gorm.io/gorm/clause"
+)
+
+// With a backplane, every instance keeps its connected users in hub_presences so
+// presence reflects all instances, not just the one answering.
+const (
+	// How often an instance rewrites its rows
+	sharedPresenceRefresh = 30 * time.Second
+	// Rows not refreshed for this long belong to an instance that is gone. Its users
+	// read as offline from then on, without an offline event or a last-seen update.
+	sharedPresenceTTL = 90 * time.Second
+)
+
+// combinePresence merges statuses from several instances: online anywhere wins, then away
+func combinePresence(a, b string) string {
+	if a == PresenceOnline || b == PresenceOnline {
+		return PresenceOnline
+	}
+	if a == PresenceAway || b == PresenceAway {
+		return PresenceAway
+	}
+	return PresenceOffline
+}
+
+// rowPresence is the status a hub_presences row stands for
+func rowPresence(row models.HubPresence) string {
+	if row.Away {
+		return PresenceAway
+	}
+	return PresenceOnline
+}
+
+// livePresenceRows loads the unexpired hub_presences rows for the given users
+func livePresenceRows(userIDs []uint) ([]models.HubPresence, error) {
+	var rows []models.HubPresence
+	err := db.DB.Where("user_id IN ? AND updated_at > ?", userIDs, time.Now().Add(-sharedPresenceTTL)).Find(&rows).Error
+	return rows, err
+}
+
+// mergeRemotePresence folds other instances' statuses into local ones. On a database
+// error the local statuses are kept.
+func (h *Hub) mergeRemotePresence(statuses map[uint]string) {
+	userIDs := make([]uint, 0, len(statuses))
+	for userID := range statuses {
+		userIDs = append(userIDs, userID)
+	}
+	rows, err := livePresenceRows(userIDs)
+	if err != nil {
+		log.Printf("Error loading shared presence: %v", err)
+		return
+	}
+	for _, row := range rows {
+		if row.InstanceID != h.instanceID { // This instance's own status is the live one
+			statuses[row.UserID] = combinePresence(statuses[row.UserID], rowPresence(row))
+		}
+	}
+}
+
+// recordPresence stores this instance's status for a user and returns the status
+// across all instances, and whether storing it changed that status
+func (h *Hub) recordPresence(userID uint) (string, bool) {
+	h.mu.RLock()
+	local := h.presenceStatusLocked(userID)
+	h.mu.RUnlock()
+
+	rows, err := livePresenceRows([]uint{userID})
+	if err != nil {
+		log.Printf("Error loading shared presence for UserID %d: %v", userID, err)
+		return local, true
+	}
+	before, others := PresenceOffline, PresenceOffline
+	for _, row := range rows {
+		before = combinePresence(before, rowPresence(row))
+		if row.InstanceID != h.instanceID {
+			others = combinePresence(others, rowPresence(row))
+		}
+	}
+
+	if local == PresenceOffline {
+		err = db.DB.Where("instance_id = ? AND user_id = ?", h.instanceID, userID).Delete(&models.HubPresence{}).Error
+	} else {
+		err = upsertPresence([]models.HubPresence{{InstanceID: h.instanceID, UserID: userID, Away: local == PresenceAway}})
+	}
+	if err != nil {
+		log.Printf("Error recording presence for UserID %d: %v", userID, err)
+	}
+
+	after := combinePresence(local, others)
+	return after, after != before
+}
+
+// upsertPresence writes rows, refreshing updated_at on existing ones
+func upsertPresence(rows []models.HubPresence) error {
+	now := time.Now()
+	for i := range rows {
+		rows[i].UpdatedAt = now
+	}
+	return db.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "instance_id"}, {Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"away", "updated_at"}),
+	}).Create(&rows).Error
+}
+
+// syncPresence rewrites this instance's rows from its connections, then deletes
+// its rows for users who have left and rows of instances that stopped refreshing
+func (h *Hub) syncPresence() {
+	start := time.Now()
+
+	h.mu.RLock()
+	rows := make([]models.HubPresence, 0, len(h.clients))
+	for userID := range h.clients {
+		rows = append(rows, models.HubPresence{InstanceID: h.instanceID, UserID: userID, Away: h.away[userID]})
+	}
+	h.mu.RUnlock()
+
+	if len(rows) > 0 {
+		if err := upsertPresence(rows); err != nil {
+			log.Printf("Error refreshing shared presence: %v", err)
+			return
+		}
+	}
+	// Rows written since start are current, including ones recorded concurrently
+	if err := db.DB.Where("instance_id = ? AND updated_at < ?", h.instanceID, start).Delete(&models.HubPresence{}).Error; err != nil {
+		log.Printf("Error clearing shared presence: %v", err)
+	}
+	if err := db.DB.Where("updated_at < ?", start.Add(-sharedPresenceTTL)).Delete(&models.HubPresence{}).Error; err != nil {
+		log.Printf("Error clearing expired shared presence: %v", err)
+	}
+}
+
+// runPresenceSync keeps this instance's rows fresh. Started by SetBackplane.
+func (h *Hub) runPresenceSync() {
+	ticker := time.NewTicker(sharedPresenceRefresh)
+	defer ticker.Stop()
+	for {
+		h.syncPresence()
+		<-ticker.C
+	}
+}
@@ -0,0 +1,18 @@
+package websocket
+
+import "testing"
+
+func TestCombinePresence(t *testing.T) {
+	cases := []struct{ a, b, want string }{
+		{PresenceOffline, PresenceOffline, PresenceOffline},
+		{PresenceAway, PresenceOffline, PresenceAway},
+		{PresenceOffline, PresenceAway, PresenceAway},
+		{PresenceAway, PresenceOnline, PresenceOnline},
+		{PresenceOnline, PresenceOffline, PresenceOnline},
+	}
+	for _, c := range cases {
+		if got := combinePresence(c.a, c.b); got != c.want {
+			t.Errorf("combinePresence(%q, %q) = %q, want %q", c.a, c.b, got, c.want)
+		}
+	}
+}
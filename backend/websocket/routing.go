@@ -0,0 +1,138 @@
+// backend/websocket/routing.go
+package websocket
+
+import (
+	"encoding/json"
+	"log"
+	"strconv"
+	"strings"
+
+	"unilink-backend/db"
+	"unilink-backend/models"
+)
+
+// Envelope is a message that already knows who gets it. The Hub only delivers
+// envelopes; it never looks anything up, so a slow database can't stall it.
+// Envelopes are JSON-encoded when they travel between instances (see backplane.go).
+type Envelope struct {
+	Type    string          `json:"type"`              // For logs and metrics
+	Data    json.RawMessage `json:"data"`              // Marshalled WSMessage
+	UserIDs []uint          `json:"userIds,omitempty"` // Every connection of these users, or
+	College *CollegeTarget  `json:"college,omitempty"` // every matching connection in a college
+}
+
+// CollegeTarget addresses connections by the attributes stored on each Client
+type CollegeTarget struct {
+	CollegeID  uint    `json:"collegeId"`
+	Department *string `json:"department,omitempty"` // nil = all departments
+	Semester   *int    `json:"semester,omitempty"`   // nil = all semesters
+}
+
+// addressEvent resolves an event's audience to connections. It runs in the caller's
+// goroutine (conversation audiences query group members). ok is false when nobody
+// is addressed.
+func addressEvent(event Event, messageBytes []byte) (Envelope, bool) {
+	env := Envelope{Type: event.EventType(), Data: messageBytes}
+	audience := event.Audience()
+
+	switch {
+	case audience.Conversation != nil:
+		conv := audience.Conversation
+		recipientIDs, ok := conversationRecipients(conv.ConversationID, conv.ActorID)
+		if !ok {
+			return env, false
+		}
+		if conv.IncludeActor {
+			recipientIDs[conv.ActorID] = true
+		}
+		env.UserIDs = userIDList(recipientIDs)
+	case audience.College != nil:
+		if audience.College.CollegeID == 0 {
+			log.Printf("Error: %s has no collegeId to target", env.Type)
+			return env, false
+		}
+		env.College = audience.College
+	default:
+		for _, userID := range audience.UserIDs {
+			if userID == 0 {
+				log.Printf("Error: %s addressed to user ID 0", env.Type)
+				continue
+			}
+			env.UserIDs = append(env.UserIDs, userID)
+		}
+	}
+
+	return env, env.College != nil || len(env.UserIDs) > 0
+}
+
+// conversationRecipients returns every participant of a conversation except excludeID.
+// ok is false when the conversation ID is malformed.
+func conversationRecipients(conversationID string, excludeID uint) (recipientIDs map[uint]bool, ok bool) {
+	recipientIDs = make(map[uint]bool) // Use a map to avoid duplicate sends to the same user ID
+
+	if strings.HasPrefix(conversationID, "dm_") {
+		// Direct Message: dm_{userID1}_{userID2}
+		parts := strings.Split(conversationID, "_")
+		if len(parts) == 3 {
+			id1, _ := strconv.ParseUint(parts[1], 10, 64)
+			id2, _ := strconv.ParseUint(parts[2], 10, 64)
+			// Add the *other* user to recipients
+			if excludeID == uint(id1) {
+				recipientIDs[uint(id2)] = true
+			} else if excludeID == uint(id2) {
+				recipientIDs[uint(id1)] = true
+			} else {
+				log.Printf("Warning: User %d not part of DM conversation %s", excludeID, conversationID)
+			}
+		} else {
+			log.Printf("Error: Invalid DM conversation ID format: %s", conversationID)
+		}
+
+	} else if strings.HasPrefix(conversationID, "group_") {
+		// Group Message: group_{groupID}
+		parts := strings.Split(conversationID, "_")
+		if len(parts) == 2 {
+			groupID, err := strconv.ParseUint(parts[1], 10, 64)
+			if err == nil {
+				// Fetch group members from DB
+				var members []models.GroupMember
+				// Exclude the sender
+				db.DB.Where("group_id = ? AND user_id != ?", uint(groupID), excludeID).Find(&members)
+				for _, member := range members {
+					recipientIDs[member.UserID] = true // Add all *other* members
+				}
+			} else {
+				log.Printf("Error: Invalid Group ID in conversation ID: %s", conversationID)
+			}
+		} else {
+			log.Printf("Error: Invalid Group conversation ID format: %s", conversationID)
+		}
+	} else {
+		log.Printf("Error: Unrecognized conversation ID format: %s", conversationID)
+		return nil, false
+	}
+
+	return recipientIDs, true
+}
+
+// matches reports whether a connection falls within the target
+func (t *CollegeTarget) matches(client *Client) bool {
+	if client.collegeID != t.CollegeID {
+		return false
+	}
+	if t.Department != nil && client.department != *t.Department {
+		return false
+	}
+	if t.Semester != nil && client.semester != *t.Semester {
+		return false
+	}
+	return true
+}
+
+func userIDList(set map[uint]bool) []uint {
+	userIDs := make([]uint, 0, len(set))
+	for userID := range set {
+		userIDs = append(userIDs, userID)
+	}
+	return userIDs
+}
@@ -0,0 +1,125 @@
+// backend/websocket/schema.go
+package websocket
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+var jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+
+// EventSchema describes the messages pushed over /ws as a JSON Schema (draft 2020-12),
+// generated from the event catalogue so it can't drift from the Go types
+func EventSchema() map[string]interface{} {
+	defs := make(map[string]interface{}, len(eventCatalogue))
+	variants := make([]interface{}, 0, len(eventCatalogue))
+	for _, spec := range eventCatalogue {
+		payload := typeSchema(spec.payload)
+		payload["description"] = spec.Description
+		payload["x-audience"] = spec.Target
+		defs[spec.Type] = payload
+
+		variants = append(variants, map[string]interface{}{
+			"properties": map[string]interface{}{
+				"type":    map[string]interface{}{"const": spec.Type},
+				"payload": map[string]interface{}{"$ref": "#/$defs/" + spec.Type},
+			},
+		})
+	}
+
+	return map[string]interface{}{
+		"$schema":     "https://json-schema.org/draft/2020-12/schema",
+		"$id":         fmt.Sprintf("urn:unilink:ws-events:v%d", EventsVersion),
+		"title":       "UniLink WebSocket events",
+		"description": "Events the server pushes over /ws. Acks to client actions ({\"type\": \"ack\"}) are not events and carry no version.",
+		"version":     EventsVersion,
+		"type":        "object",
+		"required":    []string{"type", "payload", "v"},
+		"properties": map[string]interface{}{
+			"type": map[string]interface{}{"type": "string"},
+			"v":    map[string]interface{}{"const": EventsVersion},
+		},
+		"oneOf": variants,
+		"$defs": defs,
+	}
+}
+
+// ServeEventSchema serves EventSchema as JSON
+func ServeEventSchema(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/schema+json")
+	if err := json.NewEncoder(w).Encode(EventSchema()); err != nil {
+		log.Printf("Error writing event schema: %v", err)
+	}
+}
+
+// typeSchema maps a Go type to the schema of its encoding/json output
+func typeSchema(t reflect.Type) map[string]interface{} {
+	if t.Implements(jsonMarshalerType) {
+		// Custom encoding without a declared payload type, only the shape is known
+		return map[string]interface{}{"type": "object"}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		return map[string]interface{}{
+			"anyOf": []interface{}{typeSchema(t.Elem()), map[string]interface{}{"type": "null"}},
+		}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer", "minimum": 0}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": typeSchema(t.Elem())}
+	case reflect.Map, reflect.Interface:
+		// Free-form values
+		return map[string]interface{}{"type": "object"}
+	case reflect.Struct:
+		properties := make(map[string]interface{})
+		required := []string{}
+		addFieldSchemas(t, properties, &required)
+		return map[string]interface{}{
+			"type":       "object",
+			"properties": properties,
+			"required":   required,
+		}
+	}
+	return map[string]interface{}{}
+}
+
+// addFieldSchemas adds a struct's JSON fields, flattening embedded structs the way
+// encoding/json does. Fields without omitempty are required.
+func addFieldSchemas(t reflect.Type, properties map[string]interface{}, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, options, _ := strings.Cut(tag, ",")
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			addFieldSchemas(field.Type, properties, required)
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		properties[name] = typeSchema(field.Type)
+		if !strings.Contains(options, "omitempty") {
+			*required = append(*required, name)
+		}
+	}
+}
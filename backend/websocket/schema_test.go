@@ -0,0 +1,56 @@
+package websocket
+
+import (
+	"reflect"
+	"testing"
+)
+
+// untypedObjects returns the paths in a schema where an object has no properties
+func untypedObjects(schema interface{}, path string) []string {
+	var found []string
+	switch node := schema.(type) {
+	case map[string]interface{}:
+		if node["type"] == "object" && node["properties"] == nil {
+			found = append(found, path)
+		}
+		for key, child := range node {
+			found = append(found, untypedObjects(child, path+"/"+key)...)
+		}
+	case []interface{}:
+		for _, child := range node {
+			found = append(found, untypedObjects(child, path+"[]")...)
+		}
+	}
+	return found
+}
+
+func TestEventSchemaIsTyped(t *testing.T) {
+	defs := EventSchema()["$defs"].(map[string]interface{})
+	if len(defs) != len(eventCatalogue) {
+		t.Fatalf("$defs has %d events, catalogue has %d", len(defs), len(eventCatalogue))
+	}
+	for eventType, def := range defs {
+		for _, path := range untypedObjects(def, eventType) {
+			t.Errorf("%s: object without properties", path)
+		}
+	}
+}
+
+func TestNewMessageSchemaDescribesMessage(t *testing.T) {
+	def := EventSchema()["$defs"].(map[string]interface{})["newMessage"].(map[string]interface{})
+	properties, _ := def["properties"].(map[string]interface{})
+	for _, field := range []string{"id", "content", "conversationId", "sender"} {
+		if properties[field] == nil {
+			t.Errorf("newMessage payload is missing %q", field)
+		}
+	}
+}
+
+func TestCatalogueAcceptsEveryEvent(t *testing.T) {
+	for _, spec := range eventCatalogue {
+		event := reflect.Zero(spec.event).Interface().(Event)
+		if _, ok := catalogued(event); !ok {
+			t.Errorf("%s (%v) is not accepted by Publish", spec.Type, spec.event)
+		}
+	}
+}
@@ -121,7 +121,7 @@ export const fetchCollegeGroups = async () => {
 
 // Create a new public group (club)
 export const createPublicGroup = async (groupData) => {
-    // groupData: { name, description, avatar }
+    // groupData: { name, description, avatarId? } (upload ID)
     try {
         const config = getAuthConfig();
         // Endpoint: POST /api/college-admin/groups
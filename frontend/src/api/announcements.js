@@ -1,14 +1,10 @@
-import axios from 'axios';
+import { apiClient } from './session';
 
 // Re-use or import token logic if needed, or use interceptors
 const getAuthToken = () => {
     return localStorage.getItem('authToken');
 };
 
-const apiClient = axios.create({
-    // baseURL: '/api', // If you prefer
-});
-
 const getAuthConfig = () => {
     const token = getAuthToken();
     if (!token) {
@@ -1,12 +1,10 @@
-import axios from 'axios';
+import { apiClient } from './session';
 
 // Assuming getAuthConfig is defined similarly to listings.js or use interceptors
 const getAuthToken = () => {
     return localStorage.getItem('authToken');
 };
 
-const apiClient = axios.create({});
-
 const getAuthConfig = () => {
     const token = getAuthToken();
     if (!token) {
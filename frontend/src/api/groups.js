@@ -1,8 +1,7 @@
 // frontend/src/api/groups.js
-import axios from 'axios';
+import { apiClient } from './session';
 
 const getAuthToken = () => localStorage.getItem('authToken');
-const apiClient = axios.create({});
 
 const getAuthConfig = () => {
     const token = getAuthToken();
@@ -1,15 +1,10 @@
-import axios from "axios";
+import { apiClient } from "./session";
 
 // Function to get the auth token from localStorage
 const getAuthToken = () => {
   return localStorage.getItem("authToken");
 };
 
-// Create an axios instance
-const apiClient = axios.create({
-  // baseURL: '/api', // Can set base URL if preferred over proxy
-});
-
 // Function to automatically add the auth header
 const getAuthConfig = () => {
   const token = getAuthToken();
@@ -26,12 +21,27 @@ const getAuthConfig = () => {
   };
 };
 
-// Function to fetch all marketplace listings (only 'available' ones)
+// Function to fetch marketplace listings (only 'available' ones by default)
+// params: { q, minPrice, maxPrice, status, sellerId, sort, limit, cursor } - all optional
+export const fetchListingsPage = async (params = {}) => {
+  try {
+    const config = { ...getAuthConfig(), params };
+    const response = await apiClient.get("/api/listings", config);
+    return response.data; // Returns { listings: [...], nextCursor }
+  } catch (error) {
+    console.error("Error fetching listings:", error);
+    throw (
+      error.response?.data?.error || error.message || "Failed to fetch listings"
+    );
+  }
+};
+
+// Function to fetch the first page of available listings
 export const fetchListings = async () => {
   try {
     const config = getAuthConfig(); // Get headers with token
     const response = await apiClient.get("/api/listings", config); // Pass config
-    return response.data; // Returns the array of listings
+    return response.data.listings || []; // Returns the array of listings
   } catch (error) {
     console.error("Error fetching listings:", error);
     throw (
@@ -1,8 +1,7 @@
 // frontend/src/api/messages.js
-import axios from 'axios';
+import { apiClient } from './session';
 
 const getAuthToken = () => localStorage.getItem('authToken');
-const apiClient = axios.create({});
 
 const getAuthConfig = () => {
     const token = getAuthToken();
@@ -22,15 +21,18 @@ export const fetchConversations = async () => {
     }
 };
 
-// Fetch messages for a specific conversation
-export const fetchMessages = async (conversationId, limit = 50, offset = 0) => {
+// Fetch one page of a conversation's messages (oldest first). Without `before` this is
+// the newest page; pass a page's oldestId as `before` to load the one preceding it.
+export const fetchMessages = async (conversationId, { before, limit = 50 } = {}) => {
     try {
         const config = getAuthConfig();
+        const params = { limit };
+        if (before) params.before = before;
         const response = await apiClient.get(
-            `/api/conversations/${conversationId}/messages?limit=${limit}&offset=${offset}`,
-            config
+            `/api/conversations/${conversationId}/messages`,
+            { ...config, params }
         );
-        return response.data.messages || [];
+        return response.data; // { messages, hasMore, oldestId, newestId }
     } catch (error) {
         console.error(`Error fetching messages for ${conversationId}:`, error);
         throw error.response?.data?.error || error.message || 'Failed to fetch messages';
@@ -1,8 +1,7 @@
-import axios from 'axios';
+import { apiClient } from './session';
 
 // Assuming getAuthConfig is defined or use interceptors
 const getAuthToken = () => localStorage.getItem('authToken');
-const apiClient = axios.create({});
 const getAuthConfig = () => {
     const token = getAuthToken();
     if (!token) throw new Error('Authentication token not found.');
@@ -35,7 +34,7 @@ export const fetchUserProfile = async (userId) => {
 
 // Update the logged-in user's profile
 export const updateMyProfile = async (profileData) => {
-    // profileData should be { profilePicture, bio, isPublic }
+    // profileData should be { bio, isPublic, profilePictureId? } (upload ID, 0 removes the picture)
     try {
         const config = getAuthConfig();
         const response = await apiClient.put('/api/profile/me', profileData, config);
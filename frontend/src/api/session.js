@@ -0,0 +1,55 @@
+// frontend/src/api/session.js (New File)
+import axios from 'axios';
+
+// Refresh the short-lived access token when an API call comes back 401.
+// Concurrent failures share a single refresh request.
+let refreshPromise = null;
+
+const refreshAccessToken = async () => {
+  const refreshToken = localStorage.getItem('refreshToken');
+  if (!refreshToken) throw new Error('No refresh token');
+  const response = await axios.post('/api/auth/refresh', { refreshToken }, { _skipRefresh: true });
+  localStorage.setItem('authToken', response.data.token);
+  localStorage.setItem('refreshToken', response.data.refreshToken);
+  return response.data.token;
+};
+
+// Install the refresh-on-401 behaviour on an axios instance (instances from axios.create need their own)
+export const attachRefreshInterceptor = (instance) => {
+  instance.interceptors.response.use(
+    (response) => response,
+    async (error) => {
+      const original = error.config;
+      if (error.response?.status !== 401 || !original || original._skipRefresh || original._retried) {
+        return Promise.reject(error);
+      }
+      try {
+        refreshPromise = refreshPromise || refreshAccessToken().finally(() => { refreshPromise = null; });
+        const newToken = await refreshPromise;
+        original._retried = true;
+        original.headers = { ...original.headers, Authorization: `Bearer ${newToken}` };
+        return instance(original);
+      } catch {
+        return Promise.reject(error);
+      }
+    }
+  );
+};
+
+attachRefreshInterceptor(axios);
+
+// Shared client for the API modules, so every one of them refreshes expired tokens
+export const apiClient = axios.create({});
+attachRefreshInterceptor(apiClient);
+
+// Revoke the current session (or all sessions) on the server
+export const logoutApi = async (allDevices = false) => {
+  const token = localStorage.getItem('authToken');
+  if (!token) return;
+  const path = allDevices ? '/api/auth/logout-all' : '/api/auth/logout';
+  try {
+    await axios.post(path, null, { headers: { Authorization: `Bearer ${token}` }, _skipRefresh: true });
+  } catch (error) {
+    console.error("Error revoking session:", error);
+  }
+};
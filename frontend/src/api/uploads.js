@@ -0,0 +1,24 @@
+import { apiClient } from './session';
+
+const getAuthToken = () => localStorage.getItem('authToken');
+const getAuthConfig = () => {
+    const token = getAuthToken();
+    if (!token) throw new Error('Authentication token not found.');
+    return { headers: { Authorization: `Bearer ${token}` } };
+};
+
+// Upload an image for a listing, profile picture or group avatar
+// purpose: 'listing' | 'profile' | 'group'
+export const uploadImage = async (file, purpose) => {
+    try {
+        const config = getAuthConfig();
+        const formData = new FormData();
+        formData.append('file', file);
+        formData.append('purpose', purpose);
+        const response = await apiClient.post('/api/uploads', formData, config);
+        return response.data; // UploadResponse { id, url, thumbnailUrl, ... }
+    } catch (error) {
+        console.error("Error uploading image:", error);
+        throw error.response?.data?.error || error.message || 'Failed to upload image';
+    }
+};
@@ -7,6 +7,7 @@ import axios from 'axios';
 import { loginAdminApi } from '../api/admin';
 import { fetchConversations } from '../api/messages';
 import { fetchPendingRequests } from '../api/friends'; // Import API to fetch requests count
+import { logoutApi } from '../api/session'; // Also installs the token refresh interceptor
 
 // Create the context AND EXPORT IT
 export const AuthContext = createContext(null);
@@ -130,11 +131,13 @@ export const AuthProvider = ({ children }) => {
         }
     }
 
-    const socket = new WebSocket(`${WS_URL}?token=${authToken}`);
+    // The token goes in the first frame, not the URL, so it never shows up in proxy logs
+    const socket = new WebSocket(WS_URL);
     wsRef.current = socket;
 
     socket.onopen = () => {
       console.log('WebSocket Connected');
+      socket.send(JSON.stringify({ type: 'auth', id: 'auth', payload: { token: authToken } }));
       setWsConnected(true);
       setWs(socket);
       if (reconnectTimeoutRef.current) {
@@ -381,6 +384,7 @@ export const AuthProvider = ({ children }) => {
     setUser(data.user);
     currentUserIdRef.current = data.user?.id;
     localStorage.setItem('authToken', data.token);
+    localStorage.setItem('refreshToken', data.refreshToken);
     localStorage.setItem('userData', JSON.stringify(data.user));
 
     disconnectWebSocket(); // Disconnect any previous WS
@@ -399,6 +403,7 @@ export const AuthProvider = ({ children }) => {
   // Logout function
   const logout = () => {
     // ... (keep existing logout logic) ...
+    logoutApi(); // Revoke the server-side session (fire and forget)
     disconnectWebSocket();
 
     setUser(null);
@@ -409,6 +414,7 @@ export const AuthProvider = ({ children }) => {
     setHasUnreadAnnouncements(false);
     setPendingRequestCount(0); // <-- Reset request count on logout
     localStorage.removeItem('authToken');
+    localStorage.removeItem('refreshToken');
     localStorage.removeItem('userData');
     navigate('/login');
   };
@@ -1,18 +1,20 @@
 // frontend/src/pages/AdminGroupManagementPage.jsx - Refactored for Light Mode
 import React, { useState, useEffect, useCallback } from 'react';
 import { fetchCollegeGroups, createPublicGroup, deleteCollegeGroup } from '../api/admin';
+import { uploadImage } from '../api/uploads';
 import { PlusIcon, TrashIcon, XMarkIcon, UserGroupIcon, LockClosedIcon } from '@heroicons/react/24/outline'; // Icons
 
 // CreateGroupForm Component - Refactored for Light Mode
 const CreateGroupForm = ({ onSave, onCancel, isSubmitting }) => {
     const [name, setName] = useState('');
     const [description, setDescription] = useState('');
-    const [avatar, setAvatar] = useState('');
+    const [avatarFile, setAvatarFile] = useState(null);
     const [formError, setFormError] = useState(null);
 
     // handleSubmit logic remains the same
     const handleSubmit = async (e) => {
         e.preventDefault();
+        const form = e.currentTarget;
         setFormError(null);
         if (!name.trim()) {
             setFormError("Group name is required.");
@@ -21,15 +23,26 @@ const CreateGroupForm = ({ onSave, onCancel, isSubmitting }) => {
         const payload = {
             name: name.trim(),
             description: description.trim(),
-            avatar: avatar.trim() || null,
         };
 
+        // The avatar is uploaded first; the group references it by upload ID
+        if (avatarFile) {
+            try {
+                const upload = await uploadImage(avatarFile, 'group');
+                payload.avatarId = upload.id;
+            } catch (err) {
+                setFormError(err.toString());
+                return;
+            }
+        }
+
         try {
            await onSave(payload, setFormError);
            // Clear form on successful save (handled by parent calling loadGroups)
            setName('');
            setDescription('');
-           setAvatar('');
+           setAvatarFile(null);
+           form.reset();
         } catch(err) {
             // Error is set within onSave via setFormError callback
             console.error("Group creation failed:", err);
@@ -55,10 +68,10 @@ const CreateGroupForm = ({ onSave, onCancel, isSubmitting }) => {
                 <label htmlFor="group-description" className="block text-sm font-medium text-gray-700">Description</label>
                 <textarea id="group-description" value={description} onChange={(e) => setDescription(e.target.value)} rows="3" className={inputBaseClass}></textarea>
             </div>
-            {/* Avatar URL Input */}
+            {/* Avatar Image Input */}
             <div>
-                <label htmlFor="group-avatar" className="block text-sm font-medium text-gray-700">Avatar URL (Optional)</label>
-                <input id="group-avatar" type="url" value={avatar} onChange={(e) => setAvatar(e.target.value)} className={inputBaseClass} placeholder="https://..." />
+                <label htmlFor="group-avatar" className="block text-sm font-medium text-gray-700">Avatar Image (Optional)</label>
+                <input id="group-avatar" type="file" accept="image/jpeg,image/png,image/gif" onChange={(e) => setAvatarFile(e.target.files[0] || null)} className={inputBaseClass} />
             </div>
 
             {/* Form Actions */}
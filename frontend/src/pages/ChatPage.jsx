@@ -245,6 +245,9 @@ function ChatPage() {
   const [loading, setLoading] = useState({ conversations: true, messages: false });
   const [error, setError] = useState({ conversations: null, messages: null });
   const [sending, setSending] = useState(false);
+  // Cursor for loading older history: the oldest loaded message ID and whether more exist
+  const [olderPage, setOlderPage] = useState({ hasMore: false, oldestId: null, loading: false });
+  const skipScrollRef = useRef(false); // Set when older messages are prepended
 
   const messagesEndRef = useRef(null);
   const messageInputRef = useRef(null);
@@ -334,15 +337,17 @@ function ChatPage() {
                  setError((prev) => ({ ...prev, messages: null }));
               }
               try {
-                  const data = await fetchMessages(conversationId);
+                  const page = await fetchMessages(conversationId);
                   if (isMounted) {
-                    setMessages(data);
+                    setMessages(page.messages || []);
+                    setOlderPage({ hasMore: !!page.hasMore, oldestId: page.oldestId ?? null, loading: false });
                     fetchAndUpdateUnreadCount();
                   }
               } catch (err) {
                  if (isMounted) {
                      setError((prev) => ({ ...prev, messages: err.toString() }));
                      setMessages([]);
+                     setOlderPage({ hasMore: false, oldestId: null, loading: false });
                  }
               } finally {
                  if (isMounted) setLoading((prev) => ({ ...prev, messages: false }));
@@ -365,9 +370,28 @@ function ChatPage() {
   // *** Added location.state to dependency array ***
   }, [conversationId, conversations, loading.conversations, navigate, fetchAndUpdateUnreadCount, location.state]);
 
-  // Effect to scroll to bottom (remains the same)
+  // Prepend the page of history before the oldest loaded message
+  const loadOlderMessages = useCallback(async () => {
+    if (!conversationId || !olderPage.hasMore || olderPage.loading) return;
+    setOlderPage((prev) => ({ ...prev, loading: true }));
+    try {
+      const page = await fetchMessages(conversationId, { before: olderPage.oldestId });
+      skipScrollRef.current = true;
+      setMessages((prev) => [...(page.messages || []), ...prev]);
+      setOlderPage({ hasMore: !!page.hasMore, oldestId: page.oldestId ?? olderPage.oldestId, loading: false });
+    } catch (err) {
+      console.error("Error loading older messages:", err);
+      setOlderPage((prev) => ({ ...prev, loading: false }));
+    }
+  }, [conversationId, olderPage]);
+
+  // Effect to scroll to bottom (not when older history was prepended)
   useEffect(() => {
     if (!loading.messages) {
+      if (skipScrollRef.current) {
+        skipScrollRef.current = false;
+        return;
+      }
       const timer = setTimeout(() => { scrollToBottom(); }, 100);
       return () => clearTimeout(timer);
     }
@@ -591,6 +615,18 @@ function ChatPage() {
                      <EmptyState icon={ChatBubbleBottomCenterTextIcon} title="Start Chatting!" description={`Send the first message to ${currentConversation?.name || 'this chat'}.`} />
                    ) : (
                      <>
+                       {olderPage.hasMore && (
+                         <div className="flex justify-center py-2">
+                           <button
+                             type="button"
+                             onClick={loadOlderMessages}
+                             disabled={olderPage.loading}
+                             className="text-xs text-blue-600 hover:underline disabled:text-gray-400"
+                           >
+                             {olderPage.loading ? "Loading..." : "Load older messages"}
+                           </button>
+                         </div>
+                       )}
                        {groupedDisplayMessages.map((msg) => (
                          <MessageBubble
                            key={msg.id && typeof msg.id === 'string' && msg.id.startsWith('temp-') ? msg.id : `${msg.id}-${msg.createdAt}`}
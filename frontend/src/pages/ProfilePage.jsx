@@ -2,6 +2,7 @@
 import React, { useState, useEffect, useCallback } from 'react';
 import { useParams, useNavigate, Link } from 'react-router-dom'; // *** Import Link ***
 import { fetchMyProfile, fetchUserProfile, updateMyProfile } from '../api/profile'; // *** Correct Imports ***
+import { uploadImage } from '../api/uploads';
 import { useAuth } from '../hooks/useAuth';
 import { 
     PencilSquareIcon, 
@@ -57,7 +58,7 @@ function ProfilePage() {
 
     // Form state
     const [editBio, setEditBio] = useState('');
-    const [editPicUrl, setEditPicUrl] = useState('');
+    const [editPicFile, setEditPicFile] = useState(null);
     const [editIsPublic, setEditIsPublic] = useState(true);
     const [isSaving, setIsSaving] = useState(false);
     const [editError, setEditError] = useState(null);
@@ -83,7 +84,7 @@ function ProfilePage() {
             setProfileData(data);
             if (isOwnProfile && data) {
                 setEditBio(data.bio || '');
-                setEditPicUrl(data.profilePicture || '');
+                setEditPicFile(null);
                 setEditIsPublic(data.isPublic);
             }
         } catch (err) {
@@ -111,10 +112,14 @@ function ProfilePage() {
         setEditError(null);
         try {
             const updatedData = {
-                profilePicture: editPicUrl.trim() || null,
                 bio: editBio.trim(),
                 isPublic: editIsPublic
             };
+            // A new picture is uploaded first and referenced by upload ID
+            if (editPicFile) {
+                const upload = await uploadImage(editPicFile, 'profile');
+                updatedData.profilePictureId = upload.id;
+            }
             // *** Use correct function name ***
             const result = await updateMyProfile(updatedData); 
             setProfileData(result.profile);
@@ -222,8 +227,8 @@ function ProfilePage() {
                     <h2 className="text-lg font-semibold mb-4 text-gray-800">Edit Profile</h2>
                     <form onSubmit={handleSave} className="space-y-4">
                          <div>
-                            <label htmlFor="editPicUrl" className="block text-sm font-medium text-gray-700 mb-1">Profile Picture URL</label>
-                            <input id="editPicUrl" type="url" value={editPicUrl} onChange={(e) => setEditPicUrl(e.target.value)} className="w-full px-3 py-2 border border-gray-300 rounded-md shadow-sm text-sm focus:ring-1 focus:ring-indigo-500 focus:border-indigo-500 bg-white placeholder-gray-400" placeholder="https://example.com/image.jpg"/>
+                            <label htmlFor="editPicFile" className="block text-sm font-medium text-gray-700 mb-1">Profile Picture</label>
+                            <input id="editPicFile" type="file" accept="image/jpeg,image/png,image/gif" onChange={(e) => setEditPicFile(e.target.files[0] || null)} className="w-full px-3 py-2 border border-gray-300 rounded-md shadow-sm text-sm focus:ring-1 focus:ring-indigo-500 focus:border-indigo-500 bg-white placeholder-gray-400"/>
                         </div>
                         <div>
                             <label htmlFor="editBio" className="block text-sm font-medium text-gray-700 mb-1">Bio</label>